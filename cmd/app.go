@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"file-mod-tracker/internal/adapters/config"
+	"file-mod-tracker/internal/adapters/eventstore"
+	"file-mod-tracker/internal/adapters/hasher"
+	"file-mod-tracker/internal/adapters/http"
+	"file-mod-tracker/internal/adapters/jobstore"
+	"file-mod-tracker/internal/adapters/osquery"
+	"file-mod-tracker/internal/adapters/reporter"
+	"file-mod-tracker/internal/adapters/watcher"
+	"file-mod-tracker/internal/adapters/worker"
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/core/policy"
+	"file-mod-tracker/internal/core/service"
+	"file-mod-tracker/internal/ports"
+	"file-mod-tracker/pkg/ignore"
+	"file-mod-tracker/pkg/logger"
+	"fmt"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// shutdownTimeout bounds how long requests in flight may take to finish
+// once the HTTP server is asked to stop.
+const shutdownTimeout = 10 * time.Second
+
+// app holds the tracker's services, wired together from the configuration.
+type app struct {
+	log        logger.Logger
+	cfg        *config.Config
+	eventStore ports.EventStore
+	jobStore   *jobstore.WAL
+	reporter   *reporter.Reporter
+	worker     *worker.WorkerAdapter
+	service    ports.FileMonitorService
+	server     *http.Server
+	config     *config.Watcher
+	// serverErr receives the error the HTTP server stopped with.
+	serverErr chan error
+}
+
+// newApp creates the services for cfg without starting them.
+func newApp(log logger.Logger, cfg *config.Config) (*app, error) {
+	// Open the event store
+	eventStore, err := newEventStore(cfg.EventStore)
+	if err != nil {
+		return nil, fmt.Errorf("open event store: %w", err)
+	}
+	a := &app{log: log, cfg: cfg, eventStore: eventStore}
+
+	// Open the job store
+	if cfg.JobStore.Directory != "" {
+		a.jobStore, err = jobstore.Open(cfg.JobStore.Directory)
+		if err != nil {
+			a.close()
+			return nil, fmt.Errorf("open job store: %w", err)
+		}
+	}
+
+	// Report changes to the API endpoint
+	if cfg.APIEndpoint != "" {
+		a.reporter, err = reporter.New(cfg.APIEndpoint, log, reporterOptions(cfg.Reporter)...)
+		if err != nil {
+			a.close()
+			return nil, fmt.Errorf("create reporter: %w", err)
+		}
+	}
+
+	// Initialize adapters
+	osqueryAdapter := osquery.NewAdapter(log, osqueryOptions(cfg.Osquery)...)
+	commandPolicy, err := policy.New(cfg.CommandPolicy.PolicyRules())
+	if err != nil {
+		a.close()
+		return nil, fmt.Errorf("command policy: %w", err)
+	}
+
+	roots := cfg.Roots()
+	workerOpts := []worker.Option{worker.WithEventStore(eventStore), worker.WithRoots(roots), worker.WithPolicy(commandPolicy)}
+	if cfg.CommandTimeout > 0 {
+		workerOpts = append(workerOpts, worker.WithCommandTimeout(time.Duration(cfg.CommandTimeout)*time.Second))
+	}
+	workerOpts = append(workerOpts, workerPoolOptions(cfg.WorkerPool)...)
+	var serverOpts []http.Option
+	if a.reporter != nil {
+		workerOpts = append(workerOpts, worker.WithReporter(a.reporter))
+		serverOpts = append(serverOpts, http.WithReporter(a.reporter))
+	}
+	if a.jobStore != nil {
+		workerOpts = append(workerOpts, worker.WithJobStore(a.jobStore), worker.WithRequeuePolicy(worker.RequeuePolicy{
+			Requeue:     cfg.JobStore.RequeueInterrupted,
+			MaxAttempts: cfg.JobStore.MaxAttempts,
+		}))
+	}
+	// Hashing can be turned on for a root by a config reload, so the hasher
+	// is always there.
+	workerOpts = append(workerOpts, worker.WithHasher(hasher.New(log, cfg.Hashing.Workers)))
+	workerOpts = append(workerOpts, worker.WithWatcherFactory(func(root domain.WatchRoot) (ports.FileWatcher, error) {
+		return watcher.NewFsnotifyWatcher(log, watcher.WithSkipDir(func(path string) bool {
+			rel, err := filepath.Rel(root.Path, path)
+			if err != nil {
+				return false
+			}
+			return ignore.NewFilter(root.Path, ignore.Options{MaxDepth: root.MaxDepth, Include: root.Include, Exclude: root.Exclude}).SkipDir(filepath.ToSlash(rel))
+		}))
+	}))
+	a.worker = worker.NewAdapter(log, osqueryAdapter, cfg.MonitoredDir, cfg.CheckFrequency, workerOpts...)
+
+	// Initialize core service
+	a.service = service.NewFileMonitorService(osqueryAdapter, a.worker, log)
+
+	// Initialize HTTP server
+	a.server = http.NewServer(a.service, log, a.worker, serverOpts...)
+	return a, nil
+}
+
+// start starts the services and the HTTP server and begins watching the
+// configuration for changes.
+func (a *app) start() {
+	if a.reporter != nil {
+		a.reporter.Start()
+	}
+	if err := a.service.Start(); err != nil {
+		a.log.Error("Failed to start service", "error", err)
+	}
+
+	// Apply changes to config.yaml without a restart
+	reload := &reloader{worker: a.worker, reporter: a.reporter}
+	watcher, err := config.Watch("", a.cfg, a.log, reload.apply)
+	if err != nil {
+		a.log.Error("Failed to watch config for changes", "error", err)
+	}
+	a.config = watcher
+
+	a.serverErr = make(chan error, 1)
+	go func() {
+		if err := a.server.Start(a.cfg.ServerPort); !errors.Is(err, nethttp.ErrServerClosed) {
+			a.serverErr <- err
+		}
+	}()
+}
+
+// reloadConfig reads the configuration file again, as if it had changed.
+func (a *app) reloadConfig() {
+	if a.config != nil {
+		a.config.Reload()
+	}
+}
+
+// shutdown stops the HTTP server, lets the workers drain, stops the
+// reporter and closes the stores, in that order.
+func (a *app) shutdown() {
+	a.log.Info("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := a.server.Shutdown(ctx); err != nil {
+		a.log.Error("Failed to stop HTTP server gracefully", "error", err)
+	}
+
+	// Stop worker threads, unless they were stopped through the UI or the
+	// HTTP control endpoint already
+	if err := a.service.Stop(); err != nil && !errors.Is(err, ports.ErrInvalidTransition) {
+		a.log.Error("Failed to stop service", "error", err)
+	}
+	if a.reporter != nil {
+		a.reporter.Stop()
+	}
+	a.close()
+	a.log.Info("Shutdown complete")
+}
+
+// close closes the stores.
+func (a *app) close() {
+	if a.config != nil {
+		if err := a.config.Close(); err != nil {
+			a.log.Error("Failed to stop watching config", "error", err)
+		}
+	}
+	if err := a.eventStore.Close(); err != nil {
+		a.log.Error("Failed to close event store", "error", err)
+	}
+	if a.jobStore != nil {
+		if err := a.jobStore.Close(); err != nil {
+			a.log.Error("Failed to close job store", "error", err)
+		}
+	}
+}
+
+func osqueryOptions(cfg config.OsqueryConfig) []osquery.Option {
+	var opts []osquery.Option
+	if cfg.Binary != "" {
+		opts = append(opts, osquery.WithBinary(cfg.Binary))
+	}
+	if cfg.Socket != "" {
+		opts = append(opts, osquery.WithSocket(cfg.Socket))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, osquery.WithTimeout(time.Duration(cfg.Timeout)*time.Second))
+	}
+	return opts
+}
+
+func workerPoolOptions(cfg config.WorkerPoolConfig) []worker.Option {
+	var opts []worker.Option
+	if cfg.Size > 0 {
+		opts = append(opts, worker.WithPoolSize(cfg.Size))
+	}
+	if cfg.DrainTimeout != nil {
+		opts = append(opts, worker.WithDrainTimeout(time.Duration(*cfg.DrainTimeout)*time.Second))
+	}
+	var limits []worker.QueueLimit
+	for _, queue := range cfg.Queues {
+		limits = append(limits, worker.QueueLimit{Pattern: queue.Name, Concurrency: queue.Concurrency})
+	}
+	if len(limits) > 0 {
+		opts = append(opts, worker.WithQueueLimits(limits))
+	}
+	return opts
+}
+
+func reporterOptions(cfg config.ReporterConfig) []reporter.Option {
+	var opts []reporter.Option
+	secret := cfg.Secret
+	if cfg.SecretEnv != "" {
+		secret = os.Getenv(cfg.SecretEnv)
+	}
+	if secret != "" {
+		opts = append(opts, reporter.WithSecret([]byte(secret)))
+	}
+	if cfg.BatchSize > 0 {
+		opts = append(opts, reporter.WithBatchSize(cfg.BatchSize))
+	}
+	if cfg.FlushInterval > 0 {
+		opts = append(opts, reporter.WithFlushInterval(time.Duration(cfg.FlushInterval)*time.Second))
+	}
+	if cfg.MaxBackoff > 0 {
+		opts = append(opts, reporter.WithBackoff(reporter.DefaultMinBackoff, time.Duration(cfg.MaxBackoff)*time.Second))
+	}
+	if cfg.SpoolDirectory != "" || cfg.SpoolMaxBytes > 0 {
+		opts = append(opts, reporter.WithSpool(cfg.SpoolDirectory, cfg.SpoolMaxBytes))
+	}
+	return opts
+}
+
+func newEventStore(cfg config.EventStoreConfig) (ports.EventStore, error) {
+	switch cfg.Type {
+	case "", "memory":
+		return eventstore.NewMemoryStore(cfg.MaxEvents), nil
+	case "file":
+		return eventstore.OpenFileStore(cfg.Directory, eventstore.FileStoreOptions{
+			SegmentSize:   cfg.SegmentSize,
+			Fsync:         eventstore.FsyncPolicy(cfg.Fsync),
+			FsyncInterval: time.Duration(cfg.FsyncInterval) * time.Second,
+		})
+	default:
+		return nil, fmt.Errorf("unknown event store type %q", cfg.Type)
+	}
+}
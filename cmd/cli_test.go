@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports/api"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotDiff_ReportsChangesSinceSave(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kept"), []byte("a"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "removed"), []byte("a"), 0o644))
+	snapshot := filepath.Join(t.TempDir(), "snapshot.json")
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, exitOK, runSnapshot([]string{"save", dir, snapshot}, &stdout, &stderr), stderr.String())
+
+	stdout.Reset()
+	assert.Equal(t, exitOK, runSnapshotDiff([]string{snapshot, dir}, &stdout, &stderr), stderr.String())
+	assert.JSONEq(t, "[]", stdout.String())
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "removed")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "added"), []byte("a"), 0o644))
+	stdout.Reset()
+	assert.Equal(t, exitDifferent, runSnapshotDiff([]string{"-format", "ndjson", snapshot, dir}, &stdout, &stderr), stderr.String())
+
+	var types []domain.ChangeType
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		var event domain.ChangeEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		types = append(types, event.Type)
+	}
+	assert.ElementsMatch(t, []domain.ChangeType{domain.ChangeCreated, domain.ChangeDeleted}, types)
+}
+
+func TestScan_RejectsUnknownFormat(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitError, runScan([]string{"-format", "xml", t.TempDir()}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown format "xml"`)
+	assert.Empty(t, stdout.String())
+}
+
+func TestJobsSubmit_WaitsAndExitsWithJobExitCode(t *testing.T) {
+	exitCode := 3
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		var commands []string
+		json.NewDecoder(r.Body).Decode(&commands)
+		assert.Equal(t, []string{"make test"}, commands)
+		assert.Equal(t, "build", r.URL.Query().Get("queue"))
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(api.Response{Status: api.StatusOK, Data: []api.Job{{ID: "job-1", Command: "make test", State: domain.JobQueued}}})
+	})
+	mux.HandleFunc("GET /api/v1/jobs/job-1", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(api.Response{Status: api.StatusOK, Data: api.Job{ID: "job-1", State: domain.JobFailed, ExitCode: &exitCode}})
+	})
+	mux.HandleFunc("GET /api/v1/jobs/job-1/output", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(api.Response{Status: api.StatusOK, Data: api.JobOutput{Stdout: "FAIL\n", Stderr: "boom\n"}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := runJobs([]string{"submit", "-server", server.URL, "-queue", "build", "-wait", "make test"}, &stdout, &stderr)
+
+	assert.Equal(t, 3, code)
+	assert.Equal(t, "FAIL\n", stdout.String())
+	assert.Equal(t, "boom\n", stderr.String())
+}
+
+func TestJobsCancel_ReportsServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(api.Response{Status: api.StatusError, Error: &api.Error{Code: api.CodeJobNotFound, Message: "Job not found"}})
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitError, runJobs([]string{"cancel", "-server", server.URL, "missing"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "Job not found")
+}
+
+func TestTailLog_PrintsLastCompleteLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.log")
+	require.NoError(t, os.WriteFile(path, []byte("one\ntwo\nthree\npart"), 0o644))
+
+	var out bytes.Buffer
+	offset, err := tailLog(path, 2, &out)
+
+	require.NoError(t, err)
+	assert.Equal(t, "two\nthree\n", out.String())
+	assert.Equal(t, int64(len("one\ntwo\nthree\n")), offset)
+}
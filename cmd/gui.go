@@ -0,0 +1,39 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"file-mod-tracker/internal/adapters/ui"
+)
+
+// defaultCommand runs the desktop UI unless built with the nogui tag.
+const defaultCommand = "gui"
+
+// showGUI shows the desktop UI and blocks until its window is closed. If the
+// HTTP server fails, the window is closed and the error returned, as serve
+// does.
+func showGUI(a *app) error {
+	window := ui.NewMacOSUI(a.service, a.worker)
+	failed := make(chan error, 1)
+	closed := make(chan struct{})
+	go func() {
+		select {
+		case err := <-a.serverErr:
+			a.log.Error("HTTP server failed, closing the window", "error", err)
+			failed <- err
+			window.Quit()
+		case <-closed:
+		}
+	}()
+	window.Show()
+	close(closed)
+
+	select {
+	case err := <-failed:
+		return fmt.Errorf("HTTP server: %w", err)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,12 @@
+//go:build nogui
+
+package main
+
+import "errors"
+
+// defaultCommand runs headless, as there is no UI to show.
+const defaultCommand = "serve"
+
+func showGUI(a *app) error {
+	return errors.New("built without the desktop UI (nogui tag); use serve")
+}
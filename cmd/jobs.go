@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/pkg/client"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultServer is the instance the jobs command talks to unless -server or
+// TRACKER_SERVER say otherwise.
+const defaultServer = "http://localhost:8080"
+
+// jobPollInterval is how often jobs submit -wait asks for the job state.
+const jobPollInterval = 500 * time.Millisecond
+
+// addServerFlag registers -server on fs.
+func addServerFlag(fs *flag.FlagSet) *string {
+	server := os.Getenv("TRACKER_SERVER")
+	if server == "" {
+		server = defaultServer
+	}
+	return fs.String("server", server, "base URL of the running instance, also read from TRACKER_SERVER")
+}
+
+// runJobs implements "jobs list", "jobs submit" and "jobs cancel".
+func runJobs(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return runJobsList(args[1:], stdout, stderr)
+		case "submit":
+			return runJobsSubmit(args[1:], stdout, stderr)
+		case "cancel":
+			return runJobsCancel(args[1:], stdout, stderr)
+		}
+	}
+	fmt.Fprint(stderr, "Usage: tracker jobs list [flags]\n       tracker jobs submit [flags] <command>...\n       tracker jobs cancel [flags] <id>...\n")
+	return exitError
+}
+
+func runJobsList(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("jobs list", "[flags]", stderr)
+	server := addServerFlag(fs)
+	state := fs.String("state", "", "only list jobs in this state, such as queued or failed")
+	format := fs.String("format", formatTable, "output format: json, ndjson or table")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	c, err := client.New(*server)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	jobs, err := c.Jobs(context.Background(), domain.JobState(*state))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if err := writeJobs(stdout, *format, jobs); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// runJobsSubmit enqueues each argument as a command line. With -wait it
+// prints the output of the jobs and exits with the first non-zero exit
+// code, or 1 when a job failed without one.
+func runJobsSubmit(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("jobs submit", "[flags] <command>...", stderr)
+	server := addServerFlag(fs)
+	timeout := fs.Duration("timeout", 0, "how long each command may run, 0 for the server's default")
+	queue := fs.String("queue", "", "queue whose concurrency limit the commands count against")
+	wait := fs.Bool("wait", false, "wait for the commands to finish and print their output")
+	format := fs.String("format", formatTable, "output format: json, ndjson or table")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	c, err := client.New(*server)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	ctx := context.Background()
+	// When the queue fills up, the jobs queued before still run.
+	jobs, err := c.EnqueueCommands(ctx, fs.Args(), domain.EnqueueOptions{Timeout: *timeout, Queue: *queue})
+	if err != nil && !errors.Is(err, client.ErrQueueFull) {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if !*wait {
+		if writeErr := writeJobs(stdout, *format, jobs); writeErr != nil {
+			fmt.Fprintln(stderr, writeErr)
+			return exitError
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+	}
+
+	code := exitOK
+	for _, job := range jobs {
+		finished, err := c.WaitJob(ctx, job.ID, jobPollInterval)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		output, err := c.JobOutput(ctx, job.ID)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		io.WriteString(stdout, output.Stdout)
+		io.WriteString(stderr, output.Stderr)
+		if output.Truncated {
+			fmt.Fprintf(stderr, "job %s: output truncated\n", job.ID)
+		}
+		if finished.Error != "" {
+			fmt.Fprintf(stderr, "job %s: %s\n", job.ID, finished.Error)
+		}
+		if code == exitOK && finished.State != domain.JobSucceeded {
+			code = 1
+			if finished.ExitCode != nil && *finished.ExitCode != 0 {
+				code = *finished.ExitCode
+			}
+		}
+	}
+	return code
+}
+
+func runJobsCancel(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("jobs cancel", "[flags] <id>...", stderr)
+	server := addServerFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	c, err := client.New(*server)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	code := exitOK
+	for _, id := range fs.Args() {
+		job, err := c.CancelJob(context.Background(), id)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = exitError
+			continue
+		}
+		fmt.Fprintf(stdout, "%s %s\n", job.ID, job.State)
+	}
+	return code
+}
+
+func writeJobs(w io.Writer, format string, jobs []client.Job) error {
+	switch format {
+	case formatJSON:
+		if jobs == nil {
+			jobs = []client.Job{}
+		}
+		return writeJSON(w, jobs)
+	case formatNDJSON:
+		return writeNDJSON(w, jobs)
+	case formatTable:
+	default:
+		return errors.New("unknown format " + format + ", use json, ndjson or table")
+	}
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tSTATE\tEXIT\tQUEUE\tENQUEUED\tCOMMAND")
+	for _, job := range jobs {
+		exit := ""
+		if job.ExitCode != nil {
+			exit = fmt.Sprint(*job.ExitCode)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", job.ID, job.State, exit, job.Queue, job.EnqueuedAt.Format(time.RFC3339), job.Command)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"file-mod-tracker/pkg/logger"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// logPollInterval is how often logs tail -f looks for new lines.
+const logPollInterval = 250 * time.Millisecond
+
+// runLogs implements "logs tail".
+func runLogs(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "tail" {
+		fmt.Fprint(stderr, "Usage: tracker logs tail [flags]\n")
+		return exitError
+	}
+	fs := newFlagSet("logs tail", "[flags]", stderr)
+	lines := fs.Int("n", 10, "number of lines to print from the end of the log")
+	follow := fs.Bool("f", false, "keep printing lines as they are written")
+	file := fs.String("file", logger.File, "log file to read")
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 0 || *lines < 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	offset, err := tailLog(*file, *lines, stdout)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if !*follow {
+		return exitOK
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := followLog(ctx, *file, offset, stdout); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// tailLog prints the last n lines of path and returns the offset it read up
+// to.
+func tailLog(path string, n int, w io.Writer) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	// Only complete lines are printed; followLog picks up the rest.
+	end := bytes.LastIndexByte(data, '\n') + 1
+	start := end
+	for i := 0; i < n && start > 0; i++ {
+		start = bytes.LastIndexByte(data[:start-1], '\n') + 1
+	}
+	_, err = w.Write(data[start:end])
+	return int64(end), err
+}
+
+// followLog prints the lines appended to path after offset until ctx is
+// done. When the file is rotated or truncated it starts again from the
+// beginning of the new file.
+func followLog(ctx context.Context, path string, offset int64, w io.Writer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { file.Close() }()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	var partial []byte
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			// Hold back an incomplete last line until its newline arrives.
+			partial = append(partial, buf[:n]...)
+			if end := bytes.LastIndexByte(partial, '\n') + 1; end > 0 {
+				if _, err := w.Write(partial[:end]); err != nil {
+					return err
+				}
+				partial = append(partial[:0], partial[end:]...)
+			}
+			offset += int64(n)
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(logPollInterval):
+		}
+
+		current, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		latest, err := os.Stat(path)
+		switch {
+		case err != nil:
+			// Between the rename and the creation of the new file
+			continue
+		case !os.SameFile(current, latest):
+			reopened, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			file.Close()
+			file, offset, partial = reopened, 0, partial[:0]
+		case latest.Size() < offset:
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			offset, partial = 0, partial[:0]
+		}
+	}
+}
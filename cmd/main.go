@@ -2,62 +2,126 @@ package main
 
 import (
 	"file-mod-tracker/internal/adapters/config"
-	"file-mod-tracker/internal/adapters/http"
-	"file-mod-tracker/internal/adapters/osquery"
-	"file-mod-tracker/internal/adapters/ui"
-	"file-mod-tracker/internal/adapters/worker"
-	"file-mod-tracker/internal/core/service"
 	"file-mod-tracker/pkg/logger"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
+const usage = `Usage: tracker [command]
+
+Commands:
+  gui                       Run the tracker with its desktop UI (the default)
+  serve                     Run the tracker headless, until SIGINT or SIGTERM
+  validate-config [file]    Check a configuration file and print every problem
+  scan <dir>                Print the stats of every file below dir once
+  snapshot save <dir> <file>
+                            Save the stats of every file below dir
+  snapshot diff <old> <new> Print the changes between two snapshots or
+                            directories; diff is short for it
+  jobs list|submit|cancel   Manage the command queue of a running instance
+  logs tail                 Print the end of the service log
+
+Run "tracker <command> -h" for the flags of a command.
+`
+
 func main() {
+	command := defaultCommand
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	switch command {
+	case "validate-config":
+		path := ""
+		if len(os.Args) > 2 {
+			path = os.Args[2]
+		}
+		os.Exit(validateConfig(path, os.Stdout))
+	case "scan":
+		os.Exit(runScan(os.Args[2:], os.Stdout, os.Stderr))
+	case "snapshot":
+		os.Exit(runSnapshot(os.Args[2:], os.Stdout, os.Stderr))
+	case "diff":
+		os.Exit(runSnapshotDiff(os.Args[2:], os.Stdout, os.Stderr))
+	case "jobs":
+		os.Exit(runJobs(os.Args[2:], os.Stdout, os.Stderr))
+	case "logs":
+		os.Exit(runLogs(os.Args[2:], os.Stdout, os.Stderr))
+	case "serve":
+		run(serve)
+	case "gui":
+		run(showGUI)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
+
+// run sets up logging and the services, hands them to frontend and shuts
+// them down once it returns.
+func run(frontend func(a *app) error) {
 	// Initialize logger
 	log, err := logger.NewLogger()
 	if err != nil {
 		fmt.Printf("Failed to initialize logger: %v\n", err)
-		log.Fatal("Failed to initialize logger")
 		os.Exit(1)
 	}
+	defer logger.Sync(log)
 
 	log.Info("logger initialized")
 	log.Info("Current working directory: ", "dir", getCurrentDirectory())
 
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, problems, err := config.Load("")
 	if err != nil {
 		log.Fatal("Failed to load config", "error", err)
 	}
+	if err := config.Errors(problems); err != nil {
+		log.Fatal("Invalid config", "error", err)
+	}
+	for _, problem := range problems {
+		log.Info("Config warning", "key", problem.Key, "problem", problem.Message)
+	}
 
-	// Initialize adapters
-	osqueryAdapter := osquery.NewAdapter(log)
-	workerAdapter := worker.NewAdapter(log, osqueryAdapter, cfg.MonitoredDir, cfg.CheckFrequency)
-
-	// Initialize core service
-	fileMonitorService := service.NewFileMonitorService(osqueryAdapter, workerAdapter, log)
-
-	// Initialize HTTP server
-	server := http.NewServer(fileMonitorService, log, workerAdapter)
-
-	// Initialize UI
-	ui := ui.NewMacOSUI(fileMonitorService, workerAdapter)
+	a, err := newApp(log, cfg)
+	if err != nil {
+		log.Fatal("Failed to start", "error", err)
+	}
+	a.start()
+	err = frontend(a)
+	a.shutdown()
+	if err != nil {
+		log.Error("Stopped with an error", "error", err)
+		logger.Sync(log)
+		os.Exit(1)
+	}
+}
 
-	// Start worker threads
-	workerAdapter.Start()
+// serve runs headless until SIGINT or SIGTERM arrives or the HTTP server
+// fails. SIGHUP reloads the configuration.
+func serve(a *app) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
 
-	// Start HTTP server in a goroutine
-	go func() {
-		if err := server.Start(cfg.ServerPort); err != nil {
-			log.Fatal("Failed to start HTTP server", "error", err)
+	a.log.Info("Running headless", "port", a.cfg.ServerPort)
+	for {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				a.log.Info("Received signal, reloading config", "signal", sig.String())
+				a.reloadConfig()
+				continue
+			}
+			a.log.Info("Received signal, shutting down", "signal", sig.String())
+			return nil
+		case err := <-a.serverErr:
+			return fmt.Errorf("HTTP server: %w", err)
 		}
-	}()
-
-	// Show UI (this will block until the UI is closed)
-	ui.Show()
-
-	// Stop worker threads when the application exits
-	workerAdapter.Stop()
+	}
 }
 
 func getCurrentDirectory() string {
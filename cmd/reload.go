@@ -0,0 +1,58 @@
+package main
+
+import (
+	"file-mod-tracker/internal/adapters/config"
+	"file-mod-tracker/internal/adapters/reporter"
+	"file-mod-tracker/internal/adapters/worker"
+	"file-mod-tracker/internal/core/policy"
+	"fmt"
+	"time"
+)
+
+// restartKeys are the settings a reload cannot apply to running services.
+var restartKeys = []string{"server_port", "event_store", "job_store", "worker_pool", "osquery", "hashing.workers", "reporter.spool_directory"}
+
+// reloader applies configuration changes to the running services.
+type reloader struct {
+	worker   *worker.WorkerAdapter
+	reporter *reporter.Reporter
+}
+
+// apply implements config.ApplyFunc. Everything that can fail is prepared
+// before anything is changed, so a rejected configuration leaves the
+// services as they were.
+func (r *reloader) apply(current, next *config.Config) ([]string, error) {
+	changed := config.Changed(current, next)
+
+	commandPolicy, err := policy.New(next.CommandPolicy.PolicyRules())
+	if err != nil {
+		return nil, fmt.Errorf("command_policy: %w", err)
+	}
+
+	keys := restartKeys
+	// The reporter is only created or removed on startup, so none of its
+	// settings apply before then.
+	reporting := r.reporter != nil && next.APIEndpoint != ""
+	if !reporting {
+		keys = append([]string{"api_endpoint", "reporter"}, keys...)
+	}
+	var restart []string
+	for _, key := range changed {
+		if config.HasChanged([]string{key}, keys...) {
+			restart = append(restart, key)
+		}
+	}
+	if reporting && config.HasChanged(changed, "api_endpoint", "reporter") {
+		// The spool keeps its directory until the next restart.
+		settings := next.Reporter
+		settings.SpoolDirectory = current.Reporter.SpoolDirectory
+		if err := r.reporter.Reconfigure(next.APIEndpoint, reporterOptions(settings)...); err != nil {
+			return nil, fmt.Errorf("reporter: %w", err)
+		}
+	}
+
+	r.worker.SetRoots(next.Roots())
+	r.worker.SetPolicy(commandPolicy)
+	r.worker.SetCommandTimeout(time.Duration(next.CommandTimeout) * time.Second)
+	return restart, nil
+}
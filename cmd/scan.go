@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"file-mod-tracker/internal/adapters/hasher"
+	"file-mod-tracker/internal/adapters/osquery"
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/pkg/logger"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats of the one-shot commands.
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatTable  = "table"
+)
+
+// Exit codes of the one-shot commands. snapshot diff exits with
+// exitDifferent when it finds changes, like diff(1).
+const (
+	exitOK        = 0
+	exitDifferent = 1
+	exitError     = 2
+)
+
+// stringList is a flag that may be given more than once.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// scanFlags are the flags of the commands that scan a directory.
+type scanFlags struct {
+	format   string
+	maxDepth int
+	include  stringList
+	exclude  stringList
+	hashes   stringList
+	verbose  bool
+}
+
+func (f *scanFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.format, "format", formatJSON, "output format: json, ndjson or table")
+	fs.IntVar(&f.maxDepth, "max-depth", 0, "number of directory levels to scan, 0 for unlimited")
+	fs.Var(&f.include, "include", "only report files matching this glob (repeatable)")
+	fs.Var(&f.exclude, "exclude", "skip paths matching this gitignore-style rule (repeatable)")
+	fs.Var(&f.hashes, "hash", "compute this content hash: sha256, blake2b or xxhash (repeatable)")
+	fs.BoolVar(&f.verbose, "v", false, "log progress to standard error")
+}
+
+func (f *scanFlags) validate() error {
+	switch f.format {
+	case formatJSON, formatNDJSON, formatTable:
+	default:
+		return fmt.Errorf("unknown format %q, use json, ndjson or table", f.format)
+	}
+	return hasher.ValidateAlgorithms(f.hashes)
+}
+
+// scan walks dir once, reporting unreadable paths to stderr.
+func (f *scanFlags) scan(dir string, stderr io.Writer) (domain.ScanResult, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return domain.ScanResult{}, err
+	}
+	log := logger.NewConsoleLogger(stderr, f.verbose)
+	result, err := osquery.NewAdapter(log).ScanFiles(dir, domain.ScanOptions{MaxDepth: f.maxDepth, Include: f.include, Exclude: f.exclude})
+	if err != nil {
+		return domain.ScanResult{}, err
+	}
+	if len(f.hashes) > 0 {
+		result.Files = hasher.New(log, 0).HashFiles(result.Files, f.hashes)
+	}
+	if result.Report.ErrorCount > 0 {
+		fmt.Fprintf(stderr, "%d paths could not be read\n", result.Report.ErrorCount)
+		for _, scanErr := range result.Report.Errors {
+			fmt.Fprintf(stderr, "  %s: %s\n", scanErr.Path, scanErr.Kind)
+		}
+	}
+	return result, nil
+}
+
+// newFlagSet returns a flag set that reports errors instead of exiting.
+func newFlagSet(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: tracker %s %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runScan implements "scan [flags] <dir>": it prints the stats of every
+// file below dir.
+func runScan(args []string, stdout, stderr io.Writer) int {
+	var flags scanFlags
+	fs := newFlagSet("scan", "[flags] <dir>", stderr)
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	if err := flags.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	result, err := flags.scan(fs.Arg(0), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "scan failed: %v\n", err)
+		return exitError
+	}
+	if flags.format == formatJSON {
+		err = writeJSON(stdout, result)
+	} else {
+		err = writeFiles(stdout, flags.format, result.Files)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// snapshotFile is what snapshot save writes.
+type snapshotFile struct {
+	Root    string
+	TakenAt time.Time
+	Files   []domain.FileInfo
+}
+
+// runSnapshot implements "snapshot save" and "snapshot diff".
+func runSnapshot(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "save":
+			return runSnapshotSave(args[1:], stdout, stderr)
+		case "diff":
+			return runSnapshotDiff(args[1:], stdout, stderr)
+		}
+	}
+	fmt.Fprint(stderr, "Usage: tracker snapshot save [flags] <dir> <file>\n       tracker snapshot diff [flags] <old> <new>\n")
+	return exitError
+}
+
+func runSnapshotSave(args []string, stdout, stderr io.Writer) int {
+	var flags scanFlags
+	fs := newFlagSet("snapshot save", "[flags] <dir> <file>", stderr)
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+	if err := flags.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	snapshot, err := takeSnapshot(&flags, fs.Arg(0), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "scan failed: %v\n", err)
+		return exitError
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if err := os.WriteFile(fs.Arg(1), append(data, '\n'), 0o644); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	fmt.Fprintf(stdout, "saved %d files below %s to %s\n", len(snapshot.Files), snapshot.Root, fs.Arg(1))
+	return exitOK
+}
+
+func takeSnapshot(flags *scanFlags, dir string, stderr io.Writer) (snapshotFile, error) {
+	takenAt := time.Now()
+	result, err := flags.scan(dir, stderr)
+	if err != nil {
+		return snapshotFile{}, err
+	}
+	return snapshotFile{Root: result.Report.Root, TakenAt: takenAt, Files: result.Files}, nil
+}
+
+// runSnapshotDiff compares two points in time. Each side is either a file
+// written by snapshot save or a directory, which is scanned now.
+func runSnapshotDiff(args []string, stdout, stderr io.Writer) int {
+	var flags scanFlags
+	fs := newFlagSet("snapshot diff", "[flags] <old> <new>", stderr)
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+	if err := flags.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	var sides [2]snapshotFile
+	for i := range sides {
+		var err error
+		sides[i], err = loadSnapshot(&flags, fs.Arg(i), stderr)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	events := domain.DiffSnapshots(sides[0].Files, sides[1].Files, sides[1].TakenAt)
+	if err := writeEvents(stdout, flags.format, events); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if len(events) > 0 {
+		return exitDifferent
+	}
+	return exitOK
+}
+
+func loadSnapshot(flags *scanFlags, path string, stderr io.Writer) (snapshotFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return snapshotFile{}, err
+	}
+	if info.IsDir() {
+		snapshot, err := takeSnapshot(flags, path, stderr)
+		if err != nil {
+			return snapshotFile{}, fmt.Errorf("scan %s: %w", path, err)
+		}
+		return snapshot, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshotFile{}, err
+	}
+	var snapshot snapshotFile
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshotFile{}, fmt.Errorf("%s is not a snapshot: %w", path, err)
+	}
+	if snapshot.Root == "" {
+		return snapshotFile{}, errors.New(path + " is not a snapshot: no Root")
+	}
+	return snapshot, nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeNDJSON writes each element of items on a line of its own.
+func writeNDJSON[T any](w io.Writer, items []T) error {
+	encoder := json.NewEncoder(w)
+	for _, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFiles(w io.Writer, format string, files []domain.FileInfo) error {
+	switch format {
+	case formatJSON:
+		return writeJSON(w, files)
+	case formatNDJSON:
+		return writeNDJSON(w, files)
+	}
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "MODE\tSIZE\tMODIFIED\tOWNER\tHASHES\tPATH")
+	for _, file := range files {
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\t%s\n", file.Mode, file.Size, file.LastModified.Format(time.RFC3339), file.Owner, hashList(file.Hashes), file.Path)
+	}
+	return table.Flush()
+}
+
+func writeEvents(w io.Writer, format string, events []domain.ChangeEvent) error {
+	switch format {
+	case formatJSON:
+		if events == nil {
+			events = []domain.ChangeEvent{}
+		}
+		return writeJSON(w, events)
+	case formatNDJSON:
+		return writeNDJSON(w, events)
+	}
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "TYPE\tPATH\tDETAIL")
+	for _, event := range events {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", event.Type, event.Path, changeDetail(event))
+	}
+	return table.Flush()
+}
+
+// changeDetail describes what changed in a table row.
+func changeDetail(event domain.ChangeEvent) string {
+	if event.Old == nil || event.New == nil {
+		return ""
+	}
+	switch event.Type {
+	case domain.ChangePermissions:
+		return event.Old.Mode + " -> " + event.New.Mode
+	case domain.ChangeOwnership:
+		return fmt.Sprintf("%s:%s -> %s:%s", event.Old.Owner, event.Old.Group, event.New.Owner, event.New.Group)
+	}
+	return fmt.Sprintf("%d -> %d bytes", event.Old.Size, event.New.Size)
+}
+
+func hashList(hashes map[string]string) string {
+	var parts []string
+	for _, algorithm := range []string{domain.HashSHA256, domain.HashBLAKE2b, domain.HashXXHash} {
+		if digest, ok := hashes[algorithm]; ok {
+			parts = append(parts, algorithm+":"+digest)
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"file-mod-tracker/internal/adapters/config"
+	"fmt"
+	"io"
+)
+
+// validateConfig loads the configuration at path, or config.yaml in the
+// usual locations when path is empty, and prints every problem it finds. It
+// returns the process exit code: 1 when the configuration cannot be used.
+func validateConfig(path string, out io.Writer) int {
+	_, problems, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+		return 1
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(out, problem)
+	}
+	if config.Errors(problems) != nil {
+		return 1
+	}
+	fmt.Fprintln(out, "configuration is valid")
+	return 0
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"file-mod-tracker/internal/adapters/hasher"
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/core/policy"
+	"file-mod-tracker/pkg/ignore"
+
+	"github.com/spf13/viper"
+)
+
+// Defaults filled in for keys the configuration leaves out.
+const (
+	DefaultServerPort     = "8080"
+	DefaultCheckFrequency = 60
+	DefaultSpoolDirectory = "data/spool"
+)
+
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server_port", DefaultServerPort)
+	v.SetDefault("check_frequency", DefaultCheckFrequency)
+	v.SetDefault("watch_mode", domain.WatchModePoll)
+	v.SetDefault("event_store.type", "memory")
+	v.SetDefault("reporter.spool_directory", DefaultSpoolDirectory)
+}
+
+// Problem is one thing wrong with a configuration. Warnings do not stop the
+// configuration from being used.
+type Problem struct {
+	Key     string
+	Message string
+	Warning bool
+}
+
+func (p Problem) String() string {
+	level := "error"
+	if p.Warning {
+		level = "warning"
+	}
+	return fmt.Sprintf("%s: %s: %s", level, p.Key, p.Message)
+}
+
+// ValidationError lists every error found in a configuration.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		lines[i] = problem.Key + ": " + problem.Message
+	}
+	return "invalid configuration: " + strings.Join(lines, "; ")
+}
+
+// Errors returns a *ValidationError holding the problems that are not
+// warnings, or nil if there are none.
+func Errors(problems []Problem) error {
+	var errs []Problem
+	for _, problem := range problems {
+		if !problem.Warning {
+			errs = append(errs, problem)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: errs}
+}
+
+// validator collects problems.
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) errorf(key, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(key, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+func (v *validator) nonNegative(key string, value int64) {
+	if value < 0 {
+		v.errorf(key, "must not be negative, got %d", value)
+	}
+}
+
+func (v *validator) directory(key, path string) {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		v.errorf(key, "%v", err)
+	case !info.IsDir():
+		v.errorf(key, "%s is not a directory", path)
+	}
+}
+
+// Validate reports every problem with the configuration at once.
+func (c *Config) Validate() []Problem {
+	v := &validator{}
+
+	if port, err := strconv.Atoi(c.ServerPort); err != nil || port < 1 || port > 65535 {
+		v.errorf("server_port", "must be a port number between 1 and 65535, got %q", c.ServerPort)
+	}
+	if c.CheckFrequency <= 0 {
+		v.errorf("check_frequency", "must be at least 1 second, got %d", c.CheckFrequency)
+	}
+	validateWatchMode(v, "watch_mode", c.WatchMode)
+
+	if len(c.WatchRoots) == 0 {
+		if c.MonitoredDir == "" {
+			v.errorf("monitored_directory", "is required unless watch_roots is set")
+		} else {
+			v.directory("monitored_directory", c.MonitoredDir)
+		}
+	} else if c.MonitoredDir != "" {
+		v.warnf("monitored_directory", "ignored because watch_roots is set")
+	}
+	for i, rc := range c.WatchRoots {
+		key := fmt.Sprintf("watch_roots[%d]", i)
+		if rc.Path == "" {
+			v.errorf(key+".path", "is required")
+		} else {
+			v.directory(key+".path", rc.Path)
+		}
+		v.nonNegative(key+".check_frequency", int64(rc.CheckFrequency))
+		v.nonNegative(key+".max_depth", int64(rc.MaxDepth))
+		if rc.WatchMode != "" {
+			validateWatchMode(v, key+".watch_mode", rc.WatchMode)
+		}
+		if rc.Hashing != nil {
+			validateHashing(v, key+".hashing", *rc.Hashing)
+		}
+		if err := ignore.Validate(ignore.Options{Include: rc.Include, Exclude: rc.Exclude}); err != nil {
+			v.errorf(key, "%v", err)
+		}
+	}
+	if err := ignore.Validate(ignore.Options{Exclude: c.Ignore}); err != nil {
+		v.errorf("ignore", "%v", err)
+	}
+	validateHashing(v, "hashing", c.Hashing)
+
+	switch c.EventStore.Type {
+	case "memory":
+	case "file":
+		if c.EventStore.Directory == "" {
+			v.errorf("event_store.directory", "is required when event_store.type is file")
+		}
+	default:
+		v.errorf("event_store.type", "must be memory or file, got %q", c.EventStore.Type)
+	}
+	switch c.EventStore.Fsync {
+	case "", "always", "interval", "never":
+	default:
+		v.errorf("event_store.fsync", "must be always, interval or never, got %q", c.EventStore.Fsync)
+	}
+	v.nonNegative("event_store.fsync_interval", int64(c.EventStore.FsyncInterval))
+	v.nonNegative("event_store.segment_size", c.EventStore.SegmentSize)
+	v.nonNegative("event_store.max_events", int64(c.EventStore.MaxEvents))
+
+	v.nonNegative("osquery.timeout", int64(c.Osquery.Timeout))
+	if c.Osquery.Binary != "" {
+		if _, err := exec.LookPath(c.Osquery.Binary); err != nil {
+			v.warnf("osquery.binary", "%v; directories will be walked directly", err)
+		}
+	}
+
+	if c.APIEndpoint != "" {
+		if u, err := url.Parse(c.APIEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.errorf("api_endpoint", "must be an http or https URL, got %q", c.APIEndpoint)
+		}
+	}
+	v.nonNegative("reporter.batch_size", int64(c.Reporter.BatchSize))
+	v.nonNegative("reporter.flush_interval", int64(c.Reporter.FlushInterval))
+	v.nonNegative("reporter.max_backoff", int64(c.Reporter.MaxBackoff))
+	v.nonNegative("reporter.spool_max_bytes", c.Reporter.SpoolMaxBytes)
+	if c.Reporter.SecretEnv != "" && os.Getenv(c.Reporter.SecretEnv) == "" {
+		v.warnf("reporter.secret_env", "environment variable %s is not set; requests will not be signed", c.Reporter.SecretEnv)
+	}
+
+	if _, err := policy.New(c.CommandPolicy.PolicyRules()); err != nil {
+		for _, err := range unwrapJoined(err) {
+			v.errorf("command_policy", "%v", err)
+		}
+	}
+	v.nonNegative("command_timeout", int64(c.CommandTimeout))
+	v.nonNegative("worker_pool.size", int64(c.WorkerPool.Size))
+	if c.WorkerPool.DrainTimeout != nil {
+		v.nonNegative("worker_pool.drain_timeout", int64(*c.WorkerPool.DrainTimeout))
+	}
+	for i, queue := range c.WorkerPool.Queues {
+		key := fmt.Sprintf("worker_pool.queues[%d]", i)
+		if queue.Name == "" {
+			v.errorf(key+".name", "is required")
+		}
+		if queue.Concurrency < 1 {
+			v.errorf(key+".concurrency", "must be at least 1, got %d", queue.Concurrency)
+		}
+	}
+	v.nonNegative("job_store.max_attempts", int64(c.JobStore.MaxAttempts))
+	if c.JobStore.Directory == "" && (c.JobStore.RequeueInterrupted || c.JobStore.MaxAttempts > 0) {
+		v.warnf("job_store", "requeue settings have no effect without job_store.directory")
+	}
+
+	return v.problems
+}
+
+func validateWatchMode(v *validator, key, mode string) {
+	switch mode {
+	case domain.WatchModePoll, domain.WatchModeFsnotify:
+	default:
+		v.errorf(key, "must be %s or %s, got %q", domain.WatchModePoll, domain.WatchModeFsnotify, mode)
+	}
+}
+
+func validateHashing(v *validator, key string, hashing HashingConfig) {
+	if err := hasher.ValidateAlgorithms(hashing.Algorithms); err != nil {
+		v.errorf(key+".algorithms", "%v", err)
+	}
+	v.nonNegative(key+".workers", int64(hashing.Workers))
+}
+
+// unwrapJoined splits an errors.Join result into its parts.
+func unwrapJoined(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,92 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"file-mod-tracker/internal/adapters/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	return path
+}
+
+func TestLoad_FillsDefaults(t *testing.T) {
+	dir := t.TempDir()
+	cfg, problems, err := config.Load(writeConfig(t, "monitored_directory: "+dir+"\n"))
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+	assert.Equal(t, config.DefaultServerPort, cfg.ServerPort)
+	assert.Equal(t, config.DefaultCheckFrequency, cfg.CheckFrequency)
+	assert.Equal(t, "poll", cfg.WatchMode)
+	assert.Equal(t, "memory", cfg.EventStore.Type)
+	assert.Equal(t, config.DefaultSpoolDirectory, cfg.Reporter.SpoolDirectory)
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+
+	_, problems, err := config.Load(writeConfig(t, `
+directory: /somewhere
+server_port: 70000
+check_frequency: 0
+monitored_directory: `+filepath.Join(dir, "missing")+`
+api_endpoint: example.com/report
+watch_roots:
+  - path: `+file+`
+    watch_mode: inotify
+    max_depth: -1
+worker_pool:
+  queues:
+    - name: builds
+reporter:
+  batch_size: 10
+  bogus: true
+`))
+	require.NoError(t, err)
+
+	var errs, warnings []string
+	for _, problem := range problems {
+		if problem.Warning {
+			warnings = append(warnings, problem.Key)
+		} else {
+			errs = append(errs, problem.Key)
+		}
+	}
+	assert.ElementsMatch(t, []string{"directory", "reporter.bogus", "monitored_directory"}, warnings)
+	assert.ElementsMatch(t, []string{
+		"server_port",
+		"check_frequency",
+		"watch_roots[0].path",
+		"watch_roots[0].watch_mode",
+		"watch_roots[0].max_depth",
+		"api_endpoint",
+		"worker_pool.queues[0].concurrency",
+	}, errs)
+
+	var validationErr *config.ValidationError
+	require.ErrorAs(t, config.Errors(problems), &validationErr)
+	assert.Len(t, validationErr.Problems, len(errs))
+}
+
+func TestLoad_RequiresAnExistingDirectory(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	_, problems, err := config.Load(writeConfig(t, "monitored_directory: "+missing+"\n"))
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.Equal(t, "monitored_directory", problems[0].Key)
+	assert.False(t, problems[0].Warning)
+
+	_, problems, err = config.Load(writeConfig(t, "check_frequency: 5\n"))
+	require.NoError(t, err)
+	assert.Error(t, config.Errors(problems))
+}
@@ -1,31 +1,246 @@
 package config
 
 import (
+	"sort"
+	"strings"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/core/policy"
+
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	ServerPort     string `mapstructure:"server_port"`
-	MonitoredDir   string `mapstructure:"monitored_directory"`
-	CheckFrequency int    `mapstructure:"check_frequency"`
-	APIEndpoint    string `mapstructure:"api_endpoint"`
+	ServerPort     string            `mapstructure:"server_port"`
+	MonitoredDir   string            `mapstructure:"monitored_directory"`
+	CheckFrequency int               `mapstructure:"check_frequency"`
+	WatchMode      string            `mapstructure:"watch_mode"`
+	APIEndpoint    string            `mapstructure:"api_endpoint"`
+	EventStore     EventStoreConfig  `mapstructure:"event_store"`
+	Osquery        OsqueryConfig     `mapstructure:"osquery"`
+	Hashing        HashingConfig     `mapstructure:"hashing"`
+	WatchRoots     []WatchRootConfig `mapstructure:"watch_roots"`
+	// Ignore holds gitignore-style rules applied to every watch root
+	// before the root's own exclude rules.
+	Ignore        []string            `mapstructure:"ignore"`
+	CommandPolicy CommandPolicyConfig `mapstructure:"command_policy"`
+	// CommandTimeout is how many seconds a command may run by default.
+	CommandTimeout int              `mapstructure:"command_timeout"`
+	WorkerPool     WorkerPoolConfig `mapstructure:"worker_pool"`
+	JobStore       JobStoreConfig   `mapstructure:"job_store"`
+	Reporter       ReporterConfig   `mapstructure:"reporter"`
 }
 
-func LoadConfig() (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("/etc/file-mod-tracker/")
-	viper.AddConfigPath("$HOME/.file-mod-tracker")
+// ReporterConfig tunes the delivery of change events to api_endpoint.
+type ReporterConfig struct {
+	// Secret signs every request with HMAC-SHA256. SecretEnv names an
+	// environment variable to read the secret from instead.
+	Secret    string `mapstructure:"secret"`
+	SecretEnv string `mapstructure:"secret_env"`
+	BatchSize int    `mapstructure:"batch_size"`
+	// FlushInterval and MaxBackoff are in seconds.
+	FlushInterval  int    `mapstructure:"flush_interval"`
+	MaxBackoff     int    `mapstructure:"max_backoff"`
+	SpoolDirectory string `mapstructure:"spool_directory"`
+	SpoolMaxBytes  int64  `mapstructure:"spool_max_bytes"`
+}
+
+// JobStoreConfig keeps the command queue on disk when Directory is set.
+type JobStoreConfig struct {
+	Directory string `mapstructure:"directory"`
+	// RequeueInterrupted runs jobs that were interrupted by a crash or
+	// shutdown again after a restart, up to MaxAttempts starts in total.
+	RequeueInterrupted bool `mapstructure:"requeue_interrupted"`
+	MaxAttempts        int  `mapstructure:"max_attempts"`
+}
+
+// WorkerPoolConfig sizes the pool that runs enqueued commands.
+type WorkerPoolConfig struct {
+	Size int `mapstructure:"size"`
+	// DrainTimeout is how many seconds shutdown waits for queued and
+	// running commands before killing them.
+	DrainTimeout *int               `mapstructure:"drain_timeout"`
+	Queues       []QueueLimitConfig `mapstructure:"queues"`
+}
+
+// QueueLimitConfig caps the concurrency of the queues matching Name, which
+// may end in "*" to limit every queue with that prefix separately.
+type QueueLimitConfig struct {
+	Name        string `mapstructure:"name"`
+	Concurrency int    `mapstructure:"concurrency"`
+}
+
+// CommandPolicyConfig restricts what /enqueue-commands may run. Only
+// AllowedExecutables may run, so nothing does while it is empty. The
+// executables in policy.DefaultDeniedExecutables are always refused, those in
+// policy.DefaultDeniedWrappers unless AllowedExecutables names them.
+type CommandPolicyConfig struct {
+	AllowedExecutables []string             `mapstructure:"allowed_executables"`
+	DeniedExecutables  []string             `mapstructure:"denied_executables"`
+	ArgumentRules      []ArgumentRuleConfig `mapstructure:"argument_rules"`
+	DeniedPaths        []string             `mapstructure:"denied_paths"`
+}
+
+type ArgumentRuleConfig struct {
+	Executable string   `mapstructure:"executable"`
+	Allow      []string `mapstructure:"allow"`
+	Deny       []string `mapstructure:"deny"`
+}
+
+// PolicyRules converts the command policy section for policy.New.
+func (c CommandPolicyConfig) PolicyRules() policy.Rules {
+	rules := policy.Rules{
+		AllowedExecutables: c.AllowedExecutables,
+		DeniedExecutables:  c.DeniedExecutables,
+		DeniedPaths:        c.DeniedPaths,
+	}
+	for _, rule := range c.ArgumentRules {
+		rules.ArgumentRules = append(rules.ArgumentRules, policy.ArgumentRule(rule))
+	}
+	return rules
+}
+
+// WatchRootConfig describes one monitored tree. Zero values inherit the
+// top-level check_frequency, watch_mode and hashing settings.
+type WatchRootConfig struct {
+	Path           string         `mapstructure:"path"`
+	CheckFrequency int            `mapstructure:"check_frequency"`
+	WatchMode      string         `mapstructure:"watch_mode"`
+	MaxDepth       int            `mapstructure:"max_depth"`
+	Hashing        *HashingConfig `mapstructure:"hashing"`
+	Include        []string       `mapstructure:"include"`
+	Exclude        []string       `mapstructure:"exclude"`
+}
+
+// HashingConfig enables content hashing of monitored files. Algorithms may
+// contain "sha256", "blake2b" and "xxhash".
+type HashingConfig struct {
+	Enabled    bool     `mapstructure:"enabled"`
+	Algorithms []string `mapstructure:"algorithms"`
+	Workers    int      `mapstructure:"workers"`
+}
+
+// OsqueryConfig points the file stats adapter at an osqueryi binary. When
+// Binary is empty the adapter walks the filesystem itself.
+type OsqueryConfig struct {
+	Binary  string `mapstructure:"binary"`
+	Socket  string `mapstructure:"socket"`
+	Timeout int    `mapstructure:"timeout"`
+}
 
-	if err := viper.ReadInConfig(); err != nil {
+// EventStoreConfig selects where detected change events are kept. Type is
+// either "memory" (the default) or "file". MaxEvents caps the memory store.
+type EventStoreConfig struct {
+	Type          string `mapstructure:"type"`
+	MaxEvents     int    `mapstructure:"max_events"`
+	Directory     string `mapstructure:"directory"`
+	Fsync         string `mapstructure:"fsync"`
+	FsyncInterval int    `mapstructure:"fsync_interval"`
+	SegmentSize   int64  `mapstructure:"segment_size"`
+}
+
+// Roots returns the configured watch roots. Configurations without a
+// watch_roots section monitor monitored_directory alone.
+func (c *Config) Roots() []domain.WatchRoot {
+	rootConfigs := c.WatchRoots
+	if len(rootConfigs) == 0 {
+		rootConfigs = []WatchRootConfig{{Path: c.MonitoredDir}}
+	}
+
+	roots := make([]domain.WatchRoot, 0, len(rootConfigs))
+	for _, rc := range rootConfigs {
+		root := domain.WatchRoot{
+			Path:           rc.Path,
+			CheckFrequency: time.Duration(c.CheckFrequency) * time.Second,
+			WatchMode:      c.WatchMode,
+			MaxDepth:       rc.MaxDepth,
+			Include:        rc.Include,
+			Exclude:        append(c.Ignore[:len(c.Ignore):len(c.Ignore)], rc.Exclude...),
+		}
+		if rc.CheckFrequency > 0 {
+			root.CheckFrequency = time.Duration(rc.CheckFrequency) * time.Second
+		}
+		if rc.WatchMode != "" {
+			root.WatchMode = rc.WatchMode
+		}
+		hashing := c.Hashing
+		if rc.Hashing != nil {
+			hashing = *rc.Hashing
+		}
+		root.HashAlgorithms = hashing.algorithms()
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// algorithms returns the hashes to compute, defaulting to SHA-256 when
+// hashing is enabled without naming any.
+func (h HashingConfig) algorithms() []string {
+	if !h.Enabled {
+		return nil
+	}
+	if len(h.Algorithms) == 0 {
+		return []string{domain.HashSHA256}
+	}
+	return h.Algorithms
+}
+
+// LoadConfig finds and loads config.yaml and fails if Validate reports any
+// errors. Use Load to see warnings as well.
+func LoadConfig() (*Config, error) {
+	config, problems, err := Load("")
+	if err != nil {
 		return nil, err
 	}
+	if err := Errors(problems); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Load reads the configuration from path, or from config.yaml in the usual
+// locations when path is empty, fills in defaults and validates it. Keys the
+// configuration does not know are reported as warnings. The error is only
+// set when the file cannot be read or parsed.
+func Load(path string) (*Config, []Problem, error) {
+	v := newViper(path)
+	setDefaults(v)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, nil, err
+	}
 
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
-		return nil, err
+	var metadata mapstructure.Metadata
+	if err := v.Unmarshal(&config, func(dc *mapstructure.DecoderConfig) {
+		dc.Metadata = &metadata
+	}); err != nil {
+		return nil, nil, err
 	}
 
-	return &config, nil
+	var problems []Problem
+	sort.Strings(metadata.Unused)
+	for _, key := range metadata.Unused {
+		problems = append(problems, Problem{Key: strings.ToLower(key), Message: "unknown key, ignored", Warning: true})
+	}
+	problems = append(problems, config.Validate()...)
+	return &config, problems, nil
+}
+
+// newViper returns a viper instance reading path, or config.yaml in the
+// usual locations when path is empty.
+func newViper(path string) *viper.Viper {
+	v := viper.New()
+	if path != "" {
+		v.SetConfigFile(path)
+		return v
+	}
+	v.SetConfigName("config")
+	v.SetConfigType("yaml")
+	v.AddConfigPath(".")
+	v.AddConfigPath("/etc/file-mod-tracker/")
+	v.AddConfigPath("$HOME/.file-mod-tracker")
+	return v
 }
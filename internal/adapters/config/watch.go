@@ -0,0 +1,235 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"file-mod-tracker/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Reload results recorded in the audit log.
+const (
+	ReloadApplied   = "applied"
+	ReloadRejected  = "rejected"
+	ReloadUnchanged = "unchanged"
+	// ReloadPendingRestart is a change to settings that only take effect
+	// after a restart, and to nothing else.
+	ReloadPendingRestart = "pending_restart"
+)
+
+// ApplyFunc applies a new configuration to the running services. It must
+// either apply all of next or, when it returns an error, none of it. It
+// returns the changed keys that only take effect after a restart.
+type ApplyFunc func(current, next *Config) (restart []string, err error)
+
+// Watcher reloads the configuration file whenever it changes. A new
+// configuration that cannot be read, fails validation or cannot be applied
+// is rejected as a whole and the current one stays in effect. Settings that
+// need a restart keep their running values in the current configuration
+// until then. Every reload is logged as an audit event.
+type Watcher struct {
+	file    string
+	logger  logger.Logger
+	apply   ApplyFunc
+	mu      sync.Mutex
+	current *Config
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// Watch watches the configuration file at path, or config.yaml in the usual
+// locations when path is empty, and hands every valid change to apply.
+// current is the configuration the services run with now.
+func Watch(path string, current *Config, logger logger.Logger, apply ApplyFunc) (*Watcher, error) {
+	v := newViper(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	w := &Watcher{file: v.ConfigFileUsed(), logger: logger, apply: apply, current: current, done: make(chan struct{})}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// Editors that save by renaming a new file over the old one are only
+	// seen in the directory.
+	if err := watcher.Add(filepath.Dir(w.file)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	w.watcher = watcher
+	go w.watch()
+	logger.Info("Watching config for changes", "file", w.file)
+	return w, nil
+}
+
+// watch reloads the file whenever it is written or replaced, or a symlink
+// to it starts pointing elsewhere, as when a Kubernetes ConfigMap changes.
+func (w *Watcher) watch() {
+	defer close(w.done)
+	file := filepath.Clean(w.file)
+	target, _ := filepath.EvalSymlinks(file)
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			current, _ := filepath.EvalSymlinks(file)
+			written := filepath.Clean(event.Name) == file && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+			if written || current != "" && current != target {
+				target = current
+				w.Reload()
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Error("Failed to watch config for changes", "file", w.file, "error", err)
+		}
+	}
+}
+
+// Close stops watching the file. It waits for a reload in progress.
+func (w *Watcher) Close() error {
+	err := w.watcher.Close()
+	<-w.done
+	return err
+}
+
+// Current returns the configuration in effect.
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Reload reads the configuration file again and applies it if it is valid.
+func (w *Watcher) Reload() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next, problems, err := Load(w.file)
+	if err == nil {
+		err = Errors(problems)
+	}
+	if err != nil {
+		w.audit(ReloadRejected, nil, err)
+		return
+	}
+	for _, problem := range problems {
+		w.logger.Info("Config warning", "key", problem.Key, "problem", problem.Message)
+	}
+
+	changed := Changed(w.current, next)
+	if len(changed) == 0 {
+		w.audit(ReloadUnchanged, nil, nil)
+		return
+	}
+	restart, err := w.apply(w.current, next)
+	if err != nil {
+		w.audit(ReloadRejected, changed, err)
+		return
+	}
+	// The services still run with the old values of these keys.
+	w.current = keep(next, w.current, restart)
+
+	var applied []string
+	for _, key := range changed {
+		if !HasChanged([]string{key}, restart...) {
+			applied = append(applied, key)
+		}
+	}
+	if len(applied) == 0 {
+		w.audit(ReloadPendingRestart, nil, nil, restart...)
+		return
+	}
+	w.audit(ReloadApplied, applied, nil, restart...)
+}
+
+func (w *Watcher) audit(result string, changed []string, err error, restart ...string) {
+	keysAndValues := []interface{}{"audit", "config_reload", "file", w.file, "result", result}
+	if len(changed) > 0 {
+		keysAndValues = append(keysAndValues, "changed", strings.Join(changed, ","))
+	}
+	if len(restart) > 0 {
+		keysAndValues = append(keysAndValues, "pending_restart", strings.Join(restart, ","))
+	}
+	if err != nil {
+		w.logger.Error("Config reload rejected, keeping the current config", append(keysAndValues, "error", err)...)
+		return
+	}
+	w.logger.Info("Config reloaded", keysAndValues...)
+}
+
+// Changed lists the keys whose values differ between a and b, descending
+// into sections such as reporter but not into lists such as watch_roots.
+func Changed(a, b *Config) []string {
+	return changedFields("", reflect.ValueOf(*a), reflect.ValueOf(*b))
+}
+
+func changedFields(prefix string, a, b reflect.Value) []string {
+	var changed []string
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+		x, y := a.Field(i), b.Field(i)
+		if x.Kind() == reflect.Struct {
+			changed = append(changed, changedFields(key+".", x, y)...)
+			continue
+		}
+		if !reflect.DeepEqual(x.Interface(), y.Interface()) {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
+
+// keep returns a copy of next with the values src has for keys.
+func keep(next, src *Config, keys []string) *Config {
+	kept := *next
+	for _, key := range keys {
+		to, from := field(reflect.ValueOf(&kept).Elem(), key), field(reflect.ValueOf(src).Elem(), key)
+		if to.IsValid() && from.IsValid() {
+			to.Set(from)
+		}
+	}
+	return &kept
+}
+
+// field finds the field of v that key names, such as "reporter.batch_size".
+func field(v reflect.Value, key string) reflect.Value {
+	for _, name := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+		found := reflect.Value{}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Tag.Get("mapstructure") == name {
+				found = v.Field(i)
+				break
+			}
+		}
+		if !found.IsValid() {
+			return found
+		}
+		v = found
+	}
+	return v
+}
+
+// HasChanged reports whether any of keys, or a key below one of them, is
+// listed in changed.
+func HasChanged(changed []string, keys ...string) bool {
+	for _, c := range changed {
+		for _, key := range keys {
+			if c == key || strings.HasPrefix(c, key+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"file-mod-tracker/internal/adapters/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockLogger struct {
+	mock.Mock
+}
+
+func (l *mockLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.Called(msg, keysAndValues)
+}
+
+func (l *mockLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.Called(msg, keysAndValues)
+}
+
+func (l *mockLogger) Fatal(msg string, keysAndValues ...interface{}) {
+	l.Called(msg, keysAndValues)
+}
+
+// auditLog collects the results of the reloads the watcher logs.
+type auditLog struct {
+	mu      sync.Mutex
+	results []string
+}
+
+func (a *auditLog) logger() *mockLogger {
+	l := new(mockLogger)
+	record := func(args mock.Arguments) {
+		keysAndValues := args.Get(1).([]interface{})
+		for i := 0; i+1 < len(keysAndValues); i += 2 {
+			if keysAndValues[i] == "result" {
+				a.mu.Lock()
+				a.results = append(a.results, keysAndValues[i+1].(string))
+				a.mu.Unlock()
+			}
+		}
+	}
+	l.On("Info", mock.Anything, mock.Anything).Run(record).Maybe()
+	l.On("Error", mock.Anything, mock.Anything).Run(record).Maybe()
+	return l
+}
+
+func (a *auditLog) last() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.results) == 0 {
+		return ""
+	}
+	return a.results[len(a.results)-1]
+}
+
+func TestWatcher_AppliesValidChangesOnly(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, "monitored_directory: "+dir+"\ncheck_frequency: 60\n")
+	current, _, err := config.Load(path)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var applied []*config.Config
+	applyErr := error(nil)
+	audit := &auditLog{}
+	watcher, err := config.Watch(path, current, audit.logger(), func(current, next *config.Config) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if applyErr != nil {
+			return nil, applyErr
+		}
+		applied = append(applied, next)
+		return nil, nil
+	})
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	require.NoError(t, os.WriteFile(path, []byte("monitored_directory: "+dir+"\ncheck_frequency: 0\n"), 0o644))
+	watcher.Reload()
+	assert.Equal(t, config.ReloadRejected, audit.last())
+	assert.Same(t, current, watcher.Current())
+
+	require.NoError(t, os.WriteFile(path, []byte("monitored_directory: "+dir+"\ncheck_frequency: 5\n"), 0o644))
+	assert.Eventually(t, func() bool { return watcher.Current().CheckFrequency == 5 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, config.ReloadApplied, audit.last())
+	mu.Lock()
+	require.NotEmpty(t, applied)
+	assert.Equal(t, []string{"check_frequency"}, config.Changed(current, applied[0]))
+	applyErr = errors.New("cannot apply")
+	mu.Unlock()
+
+	applyingFive := watcher.Current()
+	require.NoError(t, os.WriteFile(path, []byte("monitored_directory: "+dir+"\ncheck_frequency: 7\n"), 0o644))
+	watcher.Reload()
+	assert.Equal(t, config.ReloadRejected, audit.last())
+	assert.Same(t, applyingFive, watcher.Current())
+}
+
+func TestWatcher_KeepsRestartOnlySettings(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, "monitored_directory: "+dir+"\nserver_port: 8080\ncheck_frequency: 60\n")
+	current, _, err := config.Load(path)
+	require.NoError(t, err)
+
+	audit := &auditLog{}
+	watcher, err := config.Watch(path, current, audit.logger(), func(current, next *config.Config) ([]string, error) {
+		var restart []string
+		for _, key := range config.Changed(current, next) {
+			if key == "server_port" {
+				restart = append(restart, key)
+			}
+		}
+		return restart, nil
+	})
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	require.NoError(t, os.WriteFile(path, []byte("monitored_directory: "+dir+"\nserver_port: 9090\ncheck_frequency: 5\n"), 0o644))
+	watcher.Reload()
+	assert.Equal(t, 5, watcher.Current().CheckFrequency)
+	assert.Equal(t, "8080", watcher.Current().ServerPort, "the server still listens on the old port")
+
+	watcher.Reload()
+	assert.Equal(t, config.ReloadPendingRestart, audit.last())
+	assert.Equal(t, "8080", watcher.Current().ServerPort)
+}
+
+func TestChanged_DescendsIntoSections(t *testing.T) {
+	a := &config.Config{ServerPort: "8080", Reporter: config.ReporterConfig{BatchSize: 10}}
+	b := &config.Config{ServerPort: "8080", Reporter: config.ReporterConfig{BatchSize: 20}, WatchRoots: []config.WatchRootConfig{{Path: "/srv"}}}
+
+	changed := config.Changed(a, b)
+	assert.Equal(t, []string{"watch_roots", "reporter.batch_size"}, changed)
+	assert.True(t, config.HasChanged(changed, "reporter"))
+	assert.False(t, config.HasChanged(changed, "server_port", "report"))
+}
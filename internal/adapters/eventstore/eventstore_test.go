@@ -0,0 +1,182 @@
+package eventstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEvents(n int) []domain.ChangeEvent {
+	events := make([]domain.ChangeEvent, n)
+	for i := range events {
+		events[i] = domain.ChangeEvent{
+			Type:       domain.ChangeCreated,
+			Path:       fmt.Sprintf("/test/file%d.txt", i),
+			New:        &domain.FileInfo{Path: fmt.Sprintf("/test/file%d.txt", i), Size: int64(i)},
+			DetectedAt: time.Date(2024, 9, 23, 12, 0, i, 0, time.UTC),
+		}
+	}
+	return events
+}
+
+func TestMemoryStore_AppendAndQuery(t *testing.T) {
+	store := NewMemoryStore(0)
+
+	events := newEvents(3)
+	require.NoError(t, store.Append(events))
+	assert.Equal(t, uint64(3), events[2].Seq)
+
+	all, err := store.Query(domain.EventFilter{})
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	tail, err := store.Query(domain.EventFilter{AfterSeq: 1, Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, tail, 1)
+	assert.Equal(t, "/test/file1.txt", tail[0].Path)
+
+	window, err := store.Query(domain.EventFilter{TimeRange: domain.TimeRange{
+		Since: time.Date(2024, 9, 23, 12, 0, 1, 0, time.UTC),
+		Until: time.Date(2024, 9, 23, 12, 0, 2, 0, time.UTC),
+	}})
+	require.NoError(t, err)
+	assert.Len(t, window, 2)
+	assert.Equal(t, uint64(2), window[0].Seq)
+}
+
+func TestMemoryStore_DropsOldestEventsWhenFull(t *testing.T) {
+	store := NewMemoryStore(3)
+	require.NoError(t, store.Append(newEvents(2)))
+	require.NoError(t, store.Append(newEvents(3)))
+
+	all, err := store.Query(domain.EventFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, []uint64{3, 4, 5}, []uint64{all[0].Seq, all[1].Seq, all[2].Seq})
+
+	tail, err := store.Query(domain.EventFilter{AfterSeq: 3, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, tail, 1)
+	assert.Equal(t, uint64(4), tail[0].Seq)
+}
+
+func TestFileStore_SurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, FileStoreOptions{})
+	require.NoError(t, err)
+	require.NoError(t, store.Append(newEvents(5)))
+	require.NoError(t, store.Close())
+
+	store, err = OpenFileStore(dir, FileStoreOptions{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	more := newEvents(1)
+	require.NoError(t, store.Append(more))
+	assert.Equal(t, uint64(6), more[0].Seq)
+
+	events, err := store.Query(domain.EventFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 6)
+	assert.Equal(t, "/test/file4.txt", events[4].Path)
+	assert.Equal(t, time.Date(2024, 9, 23, 12, 0, 4, 0, time.UTC), events[4].DetectedAt.UTC())
+}
+
+func TestFileStore_RollsSegments(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, FileStoreOptions{SegmentSize: 512, Fsync: FsyncNever})
+	require.NoError(t, err)
+	require.NoError(t, store.Append(newEvents(20)))
+	require.NoError(t, store.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "segment-*.log"))
+	require.NoError(t, err)
+	assert.Greater(t, len(segments), 1)
+
+	store, err = OpenFileStore(dir, FileStoreOptions{SegmentSize: 512, Fsync: FsyncNever})
+	require.NoError(t, err)
+	defer store.Close()
+
+	events, err := store.Query(domain.EventFilter{AfterSeq: 12, Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, uint64(13), events[0].Seq)
+	assert.Equal(t, "/test/file12.txt", events[0].Path)
+	assert.Equal(t, uint64(15), events[2].Seq)
+}
+
+func TestFileStore_TruncatesTornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, FileStoreOptions{})
+	require.NoError(t, err)
+	require.NoError(t, store.Append(newEvents(3)))
+	require.NoError(t, store.Close())
+
+	// Simulate a crash halfway through writing a fourth record.
+	segment := filepath.Join(dir, fmt.Sprintf("segment-%020d.log", 1))
+	f, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 50, 1, 2, 3, 4, '{', '"'})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	store, err = OpenFileStore(dir, FileStoreOptions{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	events, err := store.Query(domain.EventFilter{})
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+
+	next := newEvents(1)
+	require.NoError(t, store.Append(next))
+	assert.Equal(t, uint64(4), next[0].Seq)
+
+	events, err = store.Query(domain.EventFilter{AfterSeq: 3})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint64(4), events[0].Seq)
+}
+
+func TestFileStore_RefusesCorruptionBeforeTail(t *testing.T) {
+	for name, corrupt := range map[string]func(record []byte){
+		"checksum": func(record []byte) { record[recordHeaderSize+2] ^= 0xff },
+		"length":   func(record []byte) { record[0] = 0xff },
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := OpenFileStore(dir, FileStoreOptions{})
+			require.NoError(t, err)
+			require.NoError(t, store.Append(newEvents(3)))
+			require.NoError(t, store.Close())
+
+			segment := filepath.Join(dir, fmt.Sprintf("segment-%020d.log", 1))
+			data, err := os.ReadFile(segment)
+			require.NoError(t, err)
+			first := recordHeaderSize + int(binary.BigEndian.Uint32(data[headerSize:]))
+			corrupt(data[headerSize+first:])
+			require.NoError(t, os.WriteFile(segment, data, 0o644))
+
+			_, err = OpenFileStore(dir, FileStoreOptions{})
+			var corruptErr *CorruptError
+			require.ErrorAs(t, err, &corruptErr)
+			assert.Equal(t, int64(headerSize+first), corruptErr.Offset)
+
+			// The events after the damage are still on disk.
+			after, err := os.ReadFile(segment)
+			require.NoError(t, err)
+			assert.Equal(t, data, after)
+		})
+	}
+}
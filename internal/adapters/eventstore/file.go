@@ -0,0 +1,597 @@
+package eventstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+)
+
+// FsyncPolicy controls when appended events are flushed to stable storage.
+type FsyncPolicy string
+
+const (
+	// FsyncAlways syncs the active segment before Append returns.
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncInterval syncs the active segment periodically in the background.
+	FsyncInterval FsyncPolicy = "interval"
+	// FsyncNever leaves flushing to the operating system.
+	FsyncNever FsyncPolicy = "never"
+)
+
+const (
+	DefaultSegmentSize   = 64 << 20
+	DefaultFsyncInterval = time.Second
+
+	segmentPrefix    = "segment-"
+	segmentExt       = ".log"
+	indexExt         = ".idx"
+	segmentMagic     = "FMTEVT"
+	segmentVersion   = 1
+	headerSize       = 8
+	recordHeaderSize = 8
+	indexEntrySize   = 8
+	maxRecordSize    = 16 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errCorruptRecord = errors.New("corrupt record")
+
+// CorruptError is returned by OpenFileStore when a record before the end of
+// a segment is damaged. Cutting the segment off there would delete every
+// event recorded after it, so the file is left untouched for the operator
+// to inspect.
+type CorruptError struct {
+	Path string
+	// Offset is where the damaged record starts.
+	Offset int64
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("%s: corrupt record at byte %d; move the segment aside to open the store without it", e.Path, e.Offset)
+}
+
+// FileStoreOptions tunes the on-disk store. Zero values select the defaults.
+type FileStoreOptions struct {
+	SegmentSize   int64
+	Fsync         FsyncPolicy
+	FsyncInterval time.Duration
+}
+
+// segment is one append-only data file plus its index. Every record in the
+// data file carries a length and a CRC so a torn write at the tail can be
+// detected and cut off. The index holds the data file offset of each record,
+// so entry i belongs to sequence number firstSeq+i.
+type segment struct {
+	firstSeq uint64
+	count    uint64
+	size     int64
+	path     string
+	index    string
+}
+
+// FileStore is a durable, append-only event store made of numbered segment
+// files in a single directory.
+type FileStore struct {
+	mu          sync.Mutex
+	dir         string
+	opts        FileStoreOptions
+	segments    []*segment
+	active      *os.File
+	activeIndex *os.File
+	lastSeq     uint64
+	dirty       bool
+	closed      bool
+	stopSync    chan struct{}
+	syncDone    chan struct{}
+}
+
+// OpenFileStore opens or creates a store in dir. The newest segment is
+// validated record by record. A last record cut short by a crash is
+// truncated, so a crash in the middle of an append loses at most that
+// append; damage anywhere else fails with a *CorruptError.
+func OpenFileStore(dir string, opts FileStoreOptions) (*FileStore, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+	if opts.Fsync == "" {
+		opts.Fsync = FsyncAlways
+	}
+	if opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = DefaultFsyncInterval
+	}
+	switch opts.Fsync {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("unknown fsync policy %q", opts.Fsync)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{dir: dir, opts: opts}
+	if err := s.load(); err != nil {
+		s.closeFiles()
+		return nil, err
+	}
+
+	if opts.Fsync == FsyncInterval {
+		s.stopSync = make(chan struct{})
+		s.syncDone = make(chan struct{})
+		go s.syncLoop()
+	}
+	return s, nil
+}
+
+func (s *FileStore) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		firstSeq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, s.newSegment(firstSeq))
+	}
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].firstSeq < s.segments[j].firstSeq
+	})
+
+	for i, seg := range s.segments {
+		if i == len(s.segments)-1 {
+			if err := s.recover(seg); err != nil {
+				return err
+			}
+			break
+		}
+		if err := s.loadSealed(seg); err != nil {
+			return err
+		}
+		if next := s.segments[i+1]; seg.firstSeq+seg.count != next.firstSeq {
+			return fmt.Errorf("segment %s: expected next sequence %d, found segment starting at %d", seg.path, seg.firstSeq+seg.count, next.firstSeq)
+		}
+	}
+
+	if len(s.segments) == 0 {
+		return nil
+	}
+	last := s.segments[len(s.segments)-1]
+	s.lastSeq = last.firstSeq + last.count - 1
+	return s.openActive(last)
+}
+
+func (s *FileStore) newSegment(firstSeq uint64) *segment {
+	base := fmt.Sprintf("%s%020d", segmentPrefix, firstSeq)
+	return &segment{
+		firstSeq: firstSeq,
+		path:     filepath.Join(s.dir, base+segmentExt),
+		index:    filepath.Join(s.dir, base+indexExt),
+	}
+}
+
+// loadSealed trusts the index of a segment that was closed cleanly during
+// rollover and only rebuilds it when it is missing or inconsistent.
+func (s *FileStore) loadSealed(seg *segment) error {
+	dataInfo, err := os.Stat(seg.path)
+	if err != nil {
+		return err
+	}
+	seg.size = dataInfo.Size()
+
+	indexInfo, err := os.Stat(seg.index)
+	if err == nil && indexInfo.Size()%indexEntrySize == 0 && indexInfo.Size() > 0 {
+		seg.count = uint64(indexInfo.Size() / indexEntrySize)
+		return nil
+	}
+	return s.recover(seg)
+}
+
+// recover scans a segment, truncates a torn last record and rewrites its
+// index from the records that survived. Any other damaged record is
+// returned as a *CorruptError.
+func (s *FileStore) recover(seg *segment) error {
+	f, err := os.OpenFile(seg.path, os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < headerSize {
+		// The segment was created but its header never made it to disk.
+		if err := writeHeader(f); err != nil {
+			return err
+		}
+		seg.size = headerSize
+		seg.count = 0
+		return os.WriteFile(seg.index, nil, 0o644)
+	}
+	if err := checkHeader(f, seg.path); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(f, headerSize, info.Size()-headerSize))
+	offset := int64(headerSize)
+	var index []byte
+	var count uint64
+	for {
+		_, n, err := readRecord(reader)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// A clean end, or a last record cut short by a crash
+			break
+		}
+		if errors.Is(err, errCorruptRecord) && offset+n >= info.Size() {
+			// The last record was written in full but not flushed
+			break
+		}
+		if err != nil {
+			return &CorruptError{Path: seg.path, Offset: offset}
+		}
+		index = binary.BigEndian.AppendUint64(index, uint64(offset))
+		offset += n
+		count++
+	}
+
+	if offset < info.Size() {
+		if err := f.Truncate(offset); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	seg.size = offset
+	seg.count = count
+	return os.WriteFile(seg.index, index, 0o644)
+}
+
+func (s *FileStore) openActive(seg *segment) error {
+	active, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	activeIndex, err := os.OpenFile(seg.index, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		active.Close()
+		return err
+	}
+	s.active = active
+	s.activeIndex = activeIndex
+	return nil
+}
+
+// roll seals the active segment and starts a new one at firstSeq.
+func (s *FileStore) roll(firstSeq uint64) error {
+	if s.active != nil {
+		if err := s.active.Sync(); err != nil {
+			return err
+		}
+		if err := s.activeIndex.Sync(); err != nil {
+			return err
+		}
+		s.closeFiles()
+	}
+
+	seg := s.newSegment(firstSeq)
+	f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := writeHeader(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+	if err := os.WriteFile(seg.index, nil, 0o644); err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	seg.size = headerSize
+	s.segments = append(s.segments, seg)
+	return s.openActive(seg)
+}
+
+func (s *FileStore) Append(events []domain.ChangeEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("event store is closed")
+	}
+
+	var data, index []byte
+	flush := func() error {
+		if len(data) == 0 {
+			return nil
+		}
+		seg := s.segments[len(s.segments)-1]
+		if _, err := s.active.Write(data); err != nil {
+			s.active.Truncate(seg.size)
+			return err
+		}
+		if _, err := s.activeIndex.Write(index); err != nil {
+			s.active.Truncate(seg.size)
+			s.activeIndex.Truncate(int64(seg.count * indexEntrySize))
+			return err
+		}
+		seg.size += int64(len(data))
+		seg.count += uint64(len(index) / indexEntrySize)
+		s.lastSeq = seg.firstSeq + seg.count - 1
+		data, index = data[:0], index[:0]
+		return nil
+	}
+
+	for i := range events {
+		event := events[i]
+		event.Seq = s.lastSeq + uint64(len(index)/indexEntrySize) + 1
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		recordSize := int64(recordHeaderSize + len(payload))
+
+		if len(s.segments) == 0 {
+			if err := s.roll(event.Seq); err != nil {
+				return err
+			}
+		} else if seg := s.segments[len(s.segments)-1]; seg.count+uint64(len(index)/indexEntrySize) > 0 && seg.size+int64(len(data))+recordSize > s.opts.SegmentSize {
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := s.roll(event.Seq); err != nil {
+				return err
+			}
+		}
+
+		seg := s.segments[len(s.segments)-1]
+		index = binary.BigEndian.AppendUint64(index, uint64(seg.size+int64(len(data))))
+		data = appendRecord(data, payload)
+		events[i].Seq = event.Seq
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	switch s.opts.Fsync {
+	case FsyncAlways:
+		return s.active.Sync()
+	case FsyncInterval:
+		s.dirty = true
+	}
+	return nil
+}
+
+func (s *FileStore) Query(filter domain.EventFilter) ([]domain.ChangeEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, errors.New("event store is closed")
+	}
+
+	var result []domain.ChangeEvent
+	startSeq := filter.AfterSeq + 1
+	for _, seg := range s.segments {
+		if seg.count == 0 || seg.firstSeq+seg.count <= startSeq {
+			continue
+		}
+		first := uint64(0)
+		if startSeq > seg.firstSeq {
+			first = startSeq - seg.firstSeq
+		}
+		done, err := s.readSegment(seg, first, filter, &result)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			break
+		}
+	}
+	return result, nil
+}
+
+// readSegment decodes the records of seg starting at record number first. It
+// reports true once the filter limit has been reached.
+func (s *FileStore) readSegment(seg *segment, first uint64, filter domain.EventFilter, result *[]domain.ChangeEvent) (bool, error) {
+	offset, err := readIndexEntry(seg.index, first)
+	if err != nil {
+		return false, err
+	}
+
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(io.NewSectionReader(f, offset, seg.size-offset))
+	for i := first; i < seg.count; i++ {
+		payload, _, err := readRecord(reader)
+		if err != nil {
+			return false, fmt.Errorf("segment %s: record %d: %w", seg.path, seg.firstSeq+i, err)
+		}
+		var event domain.ChangeEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return false, fmt.Errorf("segment %s: record %d: %w", seg.path, seg.firstSeq+i, err)
+		}
+		if !filter.Matches(event) {
+			continue
+		}
+		*result = append(*result, event)
+		if filter.Limit > 0 && len(*result) == filter.Limit {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Sync flushes the active segment to stable storage.
+func (s *FileStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.syncLocked()
+}
+
+func (s *FileStore) syncLocked() error {
+	s.dirty = false
+	if s.active == nil {
+		return nil
+	}
+	return s.active.Sync()
+}
+
+func (s *FileStore) syncLoop() {
+	defer close(s.syncDone)
+	ticker := time.NewTicker(s.opts.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.dirty && !s.closed {
+				s.syncLocked()
+			}
+			s.mu.Unlock()
+		case <-s.stopSync:
+			return
+		}
+	}
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.syncLocked()
+	s.closeFiles()
+	s.mu.Unlock()
+
+	if s.stopSync != nil {
+		close(s.stopSync)
+		<-s.syncDone
+	}
+	return err
+}
+
+func (s *FileStore) closeFiles() {
+	if s.active != nil {
+		s.active.Close()
+		s.active = nil
+	}
+	if s.activeIndex != nil {
+		s.activeIndex.Close()
+		s.activeIndex = nil
+	}
+}
+
+func writeHeader(f *os.File) error {
+	header := make([]byte, headerSize)
+	copy(header, segmentMagic)
+	header[len(segmentMagic)] = segmentVersion
+	_, err := f.WriteAt(header, 0)
+	return err
+}
+
+func checkHeader(f *os.File, path string) error {
+	header := make([]byte, headerSize)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return err
+	}
+	if string(header[:len(segmentMagic)]) != segmentMagic {
+		return fmt.Errorf("segment %s: not an event segment", path)
+	}
+	if version := header[len(segmentMagic)]; version != segmentVersion {
+		return fmt.Errorf("segment %s: unsupported format version %d", path, version)
+	}
+	return nil
+}
+
+func appendRecord(buf, payload []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(payload, crcTable))
+	return append(buf, payload...)
+}
+
+// readRecord returns the payload of the next record and the number of bytes
+// it occupies on disk. On errors the size is what the record claims to
+// take up, or just the header when its length is out of range.
+func readRecord(r io.Reader) ([]byte, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, recordHeaderSize, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length > maxRecordSize {
+		return nil, recordHeaderSize, errCorruptRecord
+	}
+	n := int64(recordHeaderSize) + int64(length)
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, n, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, n, errCorruptRecord
+	}
+	return payload, n, nil
+}
+
+func readIndexEntry(path string, entry uint64) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, indexEntrySize)
+	if _, err := f.ReadAt(buf, int64(entry*indexEntrySize)); err != nil {
+		return 0, fmt.Errorf("index %s: entry %d: %w", path, entry, err)
+	}
+	return int64(binary.BigEndian.Uint64(buf)), nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// Some platforms do not support syncing directories; the data files are
+	// still synced individually.
+	d.Sync()
+	return nil
+}
@@ -0,0 +1,69 @@
+package eventstore
+
+import (
+	"sync"
+
+	"file-mod-tracker/internal/core/domain"
+)
+
+// DefaultMaxEvents is how many events a MemoryStore keeps unless told
+// otherwise.
+const DefaultMaxEvents = 100000
+
+// MemoryStore keeps the most recent events in memory, in a ring that drops
+// the oldest event once it is full. It is the default store and loses its
+// contents when the process exits.
+type MemoryStore struct {
+	mu      sync.Mutex
+	events  []domain.ChangeEvent
+	start   int
+	lastSeq uint64
+}
+
+// NewMemoryStore returns a store keeping at most maxEvents events, or
+// DefaultMaxEvents when maxEvents is not positive.
+func NewMemoryStore(maxEvents int) *MemoryStore {
+	if maxEvents <= 0 {
+		maxEvents = DefaultMaxEvents
+	}
+	return &MemoryStore{events: make([]domain.ChangeEvent, 0, maxEvents)}
+}
+
+func (s *MemoryStore) Append(events []domain.ChangeEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range events {
+		s.lastSeq++
+		events[i].Seq = s.lastSeq
+		if len(s.events) < cap(s.events) {
+			s.events = append(s.events, events[i])
+			continue
+		}
+		s.events[s.start] = events[i]
+		s.start = (s.start + 1) % len(s.events)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Query(filter domain.EventFilter) ([]domain.ChangeEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []domain.ChangeEvent
+	for i := range s.events {
+		event := s.events[(s.start+i)%len(s.events)]
+		if !filter.Matches(event) {
+			continue
+		}
+		result = append(result, event)
+		if filter.Limit > 0 && len(result) == filter.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
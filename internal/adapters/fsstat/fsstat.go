@@ -0,0 +1,54 @@
+// Package fsstat extracts the platform specific parts of os.FileInfo.
+package fsstat
+
+import (
+	"os/user"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stat holds the fields of the underlying stat structure that os.FileInfo
+// does not expose portably. Fields the platform does not provide are zero.
+type Stat struct {
+	Inode      uint64
+	Device     uint64
+	LinkCount  uint64
+	UID        uint32
+	GID        uint32
+	AccessTime time.Time
+	ChangeTime time.Time
+}
+
+var (
+	userNames  sync.Map
+	groupNames sync.Map
+)
+
+// UserName resolves a numeric user ID, returning "" when it has no name.
+// Results are cached for the lifetime of the process.
+func UserName(uid uint32) string {
+	if name, ok := userNames.Load(uid); ok {
+		return name.(string)
+	}
+	name := ""
+	if u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10)); err == nil {
+		name = u.Username
+	}
+	userNames.Store(uid, name)
+	return name
+}
+
+// GroupName resolves a numeric group ID, returning "" when it has no name.
+// Results are cached for the lifetime of the process.
+func GroupName(gid uint32) string {
+	if name, ok := groupNames.Load(gid); ok {
+		return name.(string)
+	}
+	name := ""
+	if g, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10)); err == nil {
+		name = g.Name
+	}
+	groupNames.Store(gid, name)
+	return name
+}
@@ -0,0 +1,25 @@
+package fsstat
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// FromFileInfo reads the stat structure behind info. It reports false when
+// info was not produced by os.Stat or os.Lstat.
+func FromFileInfo(info os.FileInfo) (Stat, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return Stat{}, false
+	}
+	return Stat{
+		Inode:      stat.Ino,
+		Device:     uint64(stat.Dev),
+		LinkCount:  uint64(stat.Nlink),
+		UID:        stat.Uid,
+		GID:        stat.Gid,
+		AccessTime: time.Unix(stat.Atim.Unix()),
+		ChangeTime: time.Unix(stat.Ctim.Unix()),
+	}, true
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package fsstat
+
+import "os"
+
+// FromFileInfo always reports false on platforms whose stat structure is not
+// mapped yet, such as Windows.
+func FromFileInfo(info os.FileInfo) (Stat, bool) {
+	return Stat{}, false
+}
@@ -0,0 +1,233 @@
+package hasher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"file-mod-tracker/internal/adapters/fsstat"
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/pkg/logger"
+
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/crypto/blake2b"
+)
+
+const DefaultWorkers = 4
+
+// cacheEntry remembers the digests of a file together with the metadata it
+// had when it was read. ctime is part of the key because, unlike mtime, it
+// cannot be set back by the file's owner.
+type cacheEntry struct {
+	inode  uint64
+	size   int64
+	mtime  int64
+	ctime  int64
+	hashes map[string]string
+}
+
+// Hasher computes content hashes with a bounded pool of workers and skips
+// files whose inode, size and timestamps are unchanged since they were last
+// read.
+type Hasher struct {
+	logger  logger.Logger
+	workers int
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func New(logger logger.Logger, workers int) *Hasher {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	return &Hasher{
+		logger:  logger,
+		workers: workers,
+		cache:   make(map[string]cacheEntry),
+	}
+}
+
+// ValidateAlgorithms reports the first algorithm HashFiles cannot compute.
+func ValidateAlgorithms(algorithms []string) error {
+	for _, algorithm := range algorithms {
+		if _, err := newHash(algorithm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case domain.HashSHA256:
+		return sha256.New(), nil
+	case domain.HashBLAKE2b:
+		return blake2b.New256(nil)
+	case domain.HashXXHash:
+		return xxhash.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %q", algorithm)
+	}
+}
+
+// HashFiles returns a copy of files with the given algorithms filled in for
+// every regular file that could be read. Files that cannot be read keep the
+// hashes they already had.
+func (h *Hasher) HashFiles(files []domain.FileInfo, algorithms []string) []domain.FileInfo {
+	result := make([]domain.FileInfo, len(files))
+	copy(result, files)
+	if len(algorithms) == 0 {
+		return result
+	}
+	if err := ValidateAlgorithms(algorithms); err != nil {
+		h.logger.Error("Failed to hash files", "error", err)
+		return result
+	}
+
+	// Counted per call, as roots are hashed concurrently.
+	var hits, misses atomic.Uint64
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < h.workers && i < len(files); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				hashes, cached, err := h.hashFile(result[index].Path, algorithms)
+				if err != nil {
+					h.logger.Error("Failed to hash file", "path", result[index].Path, "error", err)
+					continue
+				}
+				if cached {
+					hits.Add(1)
+				} else if hashes != nil {
+					misses.Add(1)
+				}
+				if hashes != nil {
+					result[index].Hashes = hashes
+				}
+			}
+		}()
+	}
+	for index := range result {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	h.logger.Info("Hashed files", "files", len(files), "cached", hits.Load(), "read", misses.Load())
+	return result
+}
+
+// Prune drops the cached digests of the files at or below root that are not
+// in files, so that the cache only holds files the latest scan found.
+func (h *Hasher) Prune(root string, files []domain.FileInfo) {
+	keep := make(map[string]bool, len(files))
+	for _, file := range files {
+		keep[file.Path] = true
+	}
+	prefix := root
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for path := range h.cache {
+		if (path == root || strings.HasPrefix(path, prefix)) && !keep[path] {
+			delete(h.cache, path)
+		}
+	}
+}
+
+// hashFile returns the digests of path, and whether they all came from the
+// cache. Files that are not regular have none.
+func (h *Hasher) hashFile(path string, algorithms []string) (map[string]string, bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, false, nil
+	}
+
+	key := cacheEntry{size: info.Size(), mtime: info.ModTime().UnixNano()}
+	if stat, ok := fsstat.FromFileInfo(info); ok {
+		key.inode = stat.Inode
+		key.ctime = stat.ChangeTime.UnixNano()
+	}
+
+	h.mu.Lock()
+	cached, ok := h.cache[path]
+	h.mu.Unlock()
+	valid := ok && cached.inode == key.inode && cached.size == key.size && cached.mtime == key.mtime && cached.ctime == key.ctime
+
+	var missing []string
+	for _, algorithm := range algorithms {
+		if _, ok := cached.hashes[algorithm]; !valid || !ok {
+			missing = append(missing, algorithm)
+		}
+	}
+	if len(missing) == 0 {
+		return selectHashes(cached.hashes, algorithms), true, nil
+	}
+
+	hashes, err := readHashes(path, missing)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if valid {
+		for algorithm, digest := range cached.hashes {
+			if _, ok := hashes[algorithm]; !ok {
+				hashes[algorithm] = digest
+			}
+		}
+	}
+	key.hashes = hashes
+	h.mu.Lock()
+	h.cache[path] = key
+	h.mu.Unlock()
+	return selectHashes(hashes, algorithms), false, nil
+}
+
+func selectHashes(hashes map[string]string, algorithms []string) map[string]string {
+	selected := make(map[string]string, len(algorithms))
+	for _, algorithm := range algorithms {
+		selected[algorithm] = hashes[algorithm]
+	}
+	return selected
+}
+
+// readHashes reads the file once and feeds every requested algorithm.
+func readHashes(path string, algorithms []string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hashers := make([]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, len(algorithms))
+	for i, algorithm := range algorithms {
+		hashers[i], _ = newHash(algorithm)
+		writers[i] = hashers[i]
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]string, len(algorithms))
+	for i, algorithm := range algorithms {
+		hashes[algorithm] = hex.EncodeToString(hashers[i].Sum(nil))
+	}
+	return hashes, nil
+}
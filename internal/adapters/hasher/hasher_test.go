@@ -0,0 +1,147 @@
+package hasher
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (nopLogger) Error(msg string, keysAndValues ...interface{}) {}
+func (nopLogger) Fatal(msg string, keysAndValues ...interface{}) {}
+
+// recordingLogger keeps the key/value pairs of every Info call
+type recordingLogger struct {
+	nopLogger
+	mu   sync.Mutex
+	all  [][]interface{}
+	last []interface{}
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.all = append(l.all, keysAndValues)
+	l.last = keysAndValues
+}
+
+func TestHashFiles_ComputesDigests(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hello.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o644))
+
+	h := New(nopLogger{}, 2)
+
+	files := h.HashFiles([]domain.FileInfo{{Path: path, Size: 5}}, []string{domain.HashSHA256, domain.HashBLAKE2b, domain.HashXXHash})
+
+	require.Len(t, files, 1)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", files[0].Hashes[domain.HashSHA256])
+	assert.Equal(t, "324dcf027dd4a30a932c441f365a25e86b173defa4b8e58948253471b81b72cf", files[0].Hashes[domain.HashBLAKE2b])
+	assert.Equal(t, "26c7827d889f6da3", files[0].Hashes[domain.HashXXHash])
+}
+
+func TestHashFiles_UsesCacheUntilFileChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.txt")
+	require.NoError(t, os.WriteFile(path, []byte("aaaa"), 0o644))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	log := &recordingLogger{}
+	h := New(log, 1)
+	sha256Only := []string{domain.HashSHA256}
+
+	first := h.HashFiles([]domain.FileInfo{{Path: path}}, sha256Only)
+	h.HashFiles([]domain.FileInfo{{Path: path}}, sha256Only)
+	assert.Equal(t, []interface{}{"files", 1, "cached", uint64(1), "read", uint64(0)}, log.last)
+
+	// Rewrite the content with the same size and restore the mtime.
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte("bbbb"), 0o644))
+	require.NoError(t, os.Chtimes(path, info.ModTime(), info.ModTime()))
+
+	second := h.HashFiles([]domain.FileInfo{{Path: path}}, sha256Only)
+	assert.NotEqual(t, first[0].Hashes[domain.HashSHA256], second[0].Hashes[domain.HashSHA256])
+}
+
+func TestHashFiles_CountsCacheHitsPerCall(t *testing.T) {
+	var cachedFiles, newFiles []domain.FileInfo
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, []byte(name), 0o644))
+		newFiles = append(newFiles, domain.FileInfo{Path: path})
+	}
+	for _, name := range []string{"d.txt", "e.txt"} {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, []byte(name), 0o644))
+		cachedFiles = append(cachedFiles, domain.FileInfo{Path: path})
+	}
+
+	log := &recordingLogger{}
+	h := New(log, 2)
+	sha256Only := []string{domain.HashSHA256}
+	h.HashFiles(cachedFiles, sha256Only)
+	log.all = nil
+
+	// Roots are hashed concurrently; each call reports its own files.
+	var wg sync.WaitGroup
+	for _, files := range [][]domain.FileInfo{cachedFiles, newFiles} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.HashFiles(files, sha256Only)
+		}()
+	}
+	wg.Wait()
+
+	assert.ElementsMatch(t, [][]interface{}{
+		{"files", 2, "cached", uint64(2), "read", uint64(0)},
+		{"files", 3, "cached", uint64(0), "read", uint64(3)},
+	}, log.all)
+}
+
+func TestPrune_DropsFilesMissingFromScan(t *testing.T) {
+	dir := t.TempDir()
+	var files []domain.FileInfo
+	for _, name := range []string{"kept.txt", "gone.txt"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(name), 0o644))
+		files = append(files, domain.FileInfo{Path: path})
+	}
+	other := filepath.Join(t.TempDir(), "other.txt")
+	require.NoError(t, os.WriteFile(other, []byte("other"), 0o644))
+
+	h := New(nopLogger{}, 1)
+	h.HashFiles(append(files, domain.FileInfo{Path: other}), []string{domain.HashSHA256})
+	require.Len(t, h.cache, 3)
+
+	h.Prune(dir, files[:1])
+	assert.Contains(t, h.cache, files[0].Path)
+	assert.NotContains(t, h.cache, files[1].Path)
+	assert.Contains(t, h.cache, other)
+
+	h.Prune(dir, nil)
+	assert.Len(t, h.cache, 1)
+}
+
+func TestHashFiles_SkipsUnreadableFiles(t *testing.T) {
+	h := New(nopLogger{}, 4)
+
+	files := h.HashFiles([]domain.FileInfo{{Path: "/does/not/exist", Hashes: map[string]string{domain.HashSHA256: "kept"}}}, []string{domain.HashSHA256})
+
+	assert.Equal(t, "kept", files[0].Hashes[domain.HashSHA256])
+}
+
+func TestValidateAlgorithms(t *testing.T) {
+	assert.NoError(t, ValidateAlgorithms([]string{domain.HashSHA256, domain.HashBLAKE2b, domain.HashXXHash}))
+	assert.EqualError(t, ValidateAlgorithms([]string{"md5"}), `unsupported hash algorithm "md5"`)
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports/api"
+	"file-mod-tracker/pkg/shellwords"
+)
+
+// decodeCommands reads an /enqueue-commands body. The body is a list of
+// commands, an object holding that list as "command", or a single structured
+// command. Each command is either a command line, split with POSIX shell
+// quoting rules, or a structured command.
+func decodeCommands(body io.Reader) ([]domain.CommandSpec, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	raw = bytes.TrimSpace(raw)
+
+	var items []json.RawMessage
+	switch {
+	case bytes.HasPrefix(raw, []byte("[")):
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+	case bytes.HasPrefix(raw, []byte("{")):
+		var wrapper struct {
+			Command []json.RawMessage `json:"command"`
+		}
+		if err := json.Unmarshal(raw, &wrapper); err != nil {
+			return nil, err
+		}
+		if wrapper.Command != nil {
+			items = wrapper.Command
+		} else {
+			items = []json.RawMessage{raw}
+		}
+	default:
+		return nil, errors.New("expected a list of commands or an object")
+	}
+
+	specs := make([]domain.CommandSpec, 0, len(items))
+	for _, item := range items {
+		spec, err := decodeCommand(item)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+func decodeCommand(item json.RawMessage) (domain.CommandSpec, error) {
+	var line string
+	if err := json.Unmarshal(item, &line); err == nil {
+		argv, err := shellwords.Split(line)
+		if err != nil {
+			return domain.CommandSpec{}, fmt.Errorf("command %q: %w", line, err)
+		}
+		return domain.CommandSpec{Argv: argv}, nil
+	}
+
+	var spec api.CommandSpec
+	decoder := json.NewDecoder(bytes.NewReader(item))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return domain.CommandSpec{}, fmt.Errorf("command %s: %w", item, err)
+	}
+	if len(spec.Argv) == 0 {
+		return domain.CommandSpec{}, fmt.Errorf("command %s: argv is empty", item)
+	}
+	return domain.CommandSpec(spec), nil
+}
+
+// redactJobs copies jobs for a response of the older routes, hiding the
+// values of their environment variables like api.NewJob does. Stdin is never
+// encoded at all.
+func redactJobs(jobs []domain.Job) []domain.Job {
+	if jobs == nil {
+		return nil
+	}
+	redacted := make([]domain.Job, len(jobs))
+	for i, job := range jobs {
+		redacted[i] = redactJob(job)
+	}
+	return redacted
+}
+
+func redactJob(job domain.Job) domain.Job {
+	if len(job.Env) == 0 {
+		return job
+	}
+	env := make(map[string]string, len(job.Env))
+	for name := range job.Env {
+		env[name] = api.RedactedValue
+	}
+	job.Env = env
+	return job
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"strings"
+	"testing"
+
+	"file-mod-tracker/internal/core/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []domain.CommandSpec
+	}{
+		{
+			name: "command lines",
+			body: `["ls -l", "grep 'search term' file"]`,
+			want: []domain.CommandSpec{{Argv: []string{"ls", "-l"}}, {Argv: []string{"grep", "search term", "file"}}},
+		},
+		{
+			name: "command object",
+			body: `{"command": ["ls -l", {"argv": ["cat"], "stdin": "hello"}]}`,
+			want: []domain.CommandSpec{{Argv: []string{"ls", "-l"}}, {Argv: []string{"cat"}, Stdin: "hello"}},
+		},
+		{
+			name: "single structured command",
+			body: `{"argv": ["make", "build"], "env": {"GOOS": "linux"}, "cwd": "/srv/app"}`,
+			want: []domain.CommandSpec{{Argv: []string{"make", "build"}, Env: map[string]string{"GOOS": "linux"}, Cwd: "/srv/app"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCommands(strings.NewReader(tt.body))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDecodeCommands_Errors(t *testing.T) {
+	for body, want := range map[string]string{
+		`"ls -l"`:                      "expected a list of commands",
+		`["echo 'open"]`:               "unterminated quote",
+		`[{"args": ["ls"]}]`:           `unknown field "args"`,
+		`[{"argv": []}]`:               "argv is empty",
+		`{"command": "ls -l"}`:         "cannot unmarshal",
+		`[{"argv": ["ls"], "env": 1}]`: "cannot unmarshal",
+	} {
+		_, err := decodeCommands(strings.NewReader(body))
+		assert.ErrorContains(t, err, want, body)
+	}
+}
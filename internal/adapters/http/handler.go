@@ -1,33 +1,315 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"file-mod-tracker/internal/core/domain"
 	"file-mod-tracker/internal/ports"
+	"file-mod-tracker/internal/ports/api"
+	"file-mod-tracker/pkg/logger"
 )
 
+// apiPrefix is where version 1 of the API lives.
+const apiPrefix = "/api/v1"
+
+// httpHandler implements version 1 of the API. Unlike the older routes it
+// answers every request, failed or not, with a JSON api.Response.
 type httpHandler struct {
 	fileMonitorService ports.FileMonitorService
+	workerAdapter      ports.WorkerAdapter
+	reporter           ports.ChangeReporter
+	logger             logger.Logger
 }
 
-func NewHandler(fileMonitorService ports.FileMonitorService) *httpHandler {
+// NewHandler returns the version 1 API handlers. reporter may be nil when
+// changes are not reported to an endpoint.
+func NewHandler(fileMonitorService ports.FileMonitorService, workerAdapter ports.WorkerAdapter, reporter ports.ChangeReporter, logger logger.Logger) api.HTTPHandler {
 	return &httpHandler{
 		fileMonitorService: fileMonitorService,
+		workerAdapter:      workerAdapter,
+		reporter:           reporter,
+		logger:             logger,
 	}
 }
 
-func (h *httpHandler) GetFileStats(w http.ResponseWriter, r *http.Request) {
-	// Implementation
+// routes maps the API paths below apiPrefix to the handlers serving them.
+// Every path must be described in openapi.json.
+func routes(h api.HTTPHandler) map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"/health":     h.HealthCheck,
+		"/file-stats": h.GetFileStats,
+		"/logs":       h.GetLogs,
+		"/roots":      h.GetRoots,
+		"/jobs": func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				h.EnqueueCommands(w, r)
+				return
+			}
+			h.GetJobs(w, r)
+		},
+		"/jobs/{id}":        h.Job,
+		"/jobs/{id}/output": h.GetJobOutput,
+		"/service":          h.GetService,
+		"/service/{action}": h.ServiceAction,
+	}
 }
 
-func (h *httpHandler) EnqueueCommands(w http.ResponseWriter, r *http.Request) {
-	// Implementation
+// register adds the routes of h to mux. Requests are validated against
+// openapi.json before they reach h.
+func register(mux *http.ServeMux, h api.HTTPHandler) {
+	v1 := http.NewServeMux()
+	v1.HandleFunc(apiPrefix+"/", h.NotFound)
+	for path, handler := range routes(h) {
+		v1.HandleFunc(apiPrefix+path, handler)
+	}
+	mux.Handle(apiPrefix+"/", validateRequests(apiSpec, v1))
+	mux.HandleFunc("GET /openapi.json", handleOpenAPI)
 }
 
 func (h *httpHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	// Implementation
+	if !h.allow(w, r, http.MethodGet) {
+		return
+	}
+	response := api.Health{Status: "OK", Service: api.NewServiceStatus(h.fileMonitorService.Status())}
+	if h.reporter != nil {
+		status := api.NewReporterStatus(h.reporter.Status())
+		response.Reporter = &status
+	}
+	h.respond(w, r, http.StatusOK, response)
+}
+
+func (h *httpHandler) GetFileStats(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, http.MethodGet) {
+		return
+	}
+	directory := r.URL.Query().Get("directory")
+	if directory == "" {
+		h.fail(w, r, http.StatusBadRequest, api.CodeInvalidRequest, "Directory parameter is required")
+		return
+	}
+	timeRange, err := parseTimeRange(r)
+	if err != nil {
+		h.fail(w, r, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid time range: "+err.Error())
+		return
+	}
+
+	result, err := h.fileMonitorService.ScanDirectory(directory)
+	if err != nil {
+		h.internalError(w, r, "Failed to get file stats", err)
+		return
+	}
+	result.Files = domain.FilterByModTime(result.Files, timeRange)
+	h.respond(w, r, http.StatusOK, api.NewScanResult(result))
 }
 
 func (h *httpHandler) GetLogs(w http.ResponseWriter, r *http.Request) {
-	// Implementation
+	if !h.allow(w, r, http.MethodGet) {
+		return
+	}
+	timeRange, err := parseTimeRange(r)
+	if err != nil {
+		h.fail(w, r, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid time range: "+err.Error())
+		return
+	}
+	filter := domain.EventFilter{TimeRange: timeRange}
+	if after := r.URL.Query().Get("after"); after != "" {
+		filter.AfterSeq, err = strconv.ParseUint(after, 10, 64)
+		if err != nil {
+			h.fail(w, r, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid after: must be an event sequence number")
+			return
+		}
+	}
+
+	h.respond(w, r, http.StatusOK, api.NewChangeEvents(h.workerAdapter.GetFileChanges(filter)))
+}
+
+func (h *httpHandler) GetRoots(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, http.MethodGet) {
+		return
+	}
+	h.respond(w, r, http.StatusOK, api.NewRootStatuses(h.workerAdapter.GetRootStatuses()))
+}
+
+// EnqueueCommands queues the commands in the body. When the queue fills up
+// part way, the response is a queue_full error whose data lists the jobs
+// that were queued and still run.
+func (h *httpHandler) EnqueueCommands(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, http.MethodPost) {
+		return
+	}
+	commands, err := decodeCommands(r.Body)
+	if err != nil {
+		h.fail(w, r, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	timeout, err := parseTimeout(r)
+	if err != nil {
+		h.fail(w, r, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid timeout: "+err.Error())
+		return
+	}
+
+	jobs, err := h.fileMonitorService.EnqueueJobs(commands, domain.EnqueueOptions{
+		Timeout: timeout,
+		Queue:   r.URL.Query().Get("queue"),
+	})
+	var rejected *ports.CommandRejectedError
+	switch {
+	case errors.As(err, &rejected):
+		h.fail(w, r, http.StatusForbidden, api.CodeCommandRejected, fmt.Sprintf("Command %q rejected: %s", rejected.Command, rejected.Reason))
+	case errors.Is(err, ports.ErrQueueFull):
+		writeResponse(w, r, http.StatusServiceUnavailable, api.Response{
+			Status: api.StatusError,
+			Data:   api.NewJobs(jobs),
+			Error:  &api.Error{Code: api.CodeQueueFull, Message: "Command queue is full"},
+		})
+	case errors.Is(err, ports.ErrWorkerStopped):
+		h.fail(w, r, http.StatusServiceUnavailable, api.CodeWorkerStopped, "Worker is stopped")
+	case err != nil:
+		h.internalError(w, r, "Failed to enqueue commands", err)
+	default:
+		h.respond(w, r, http.StatusAccepted, api.NewJobs(jobs))
+	}
+}
+
+func (h *httpHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
+	// POST to the same path enqueues commands
+	if !h.allow(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	state := domain.JobState(r.URL.Query().Get("state"))
+	jobs := []api.Job{}
+	for _, job := range h.workerAdapter.GetJobs() {
+		if state == "" || job.State == state {
+			jobs = append(jobs, api.NewJob(job))
+		}
+	}
+	h.respond(w, r, http.StatusOK, jobs)
+}
+
+// Job returns the job on GET and cancels it on DELETE. A running job is
+// only marked cancelled once its process has exited.
+func (h *httpHandler) Job(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, http.MethodGet, http.MethodDelete) {
+		return
+	}
+	id := r.PathValue("id")
+	if r.Method == http.MethodDelete {
+		switch err := h.workerAdapter.CancelJob(id); {
+		case errors.Is(err, ports.ErrJobNotFound):
+			h.fail(w, r, http.StatusNotFound, api.CodeJobNotFound, "Job not found")
+			return
+		case errors.Is(err, ports.ErrJobFinished):
+			h.fail(w, r, http.StatusConflict, api.CodeJobFinished, "Job already finished")
+			return
+		case err != nil:
+			h.internalError(w, r, "Failed to cancel job", err)
+			return
+		}
+	}
+
+	job, err := h.workerAdapter.GetJob(id)
+	if err != nil {
+		h.fail(w, r, http.StatusNotFound, api.CodeJobNotFound, "Job not found")
+		return
+	}
+	status := http.StatusOK
+	if r.Method == http.MethodDelete {
+		status = http.StatusAccepted
+	}
+	h.respond(w, r, status, api.NewJob(job))
+}
+
+func (h *httpHandler) GetJobOutput(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, http.MethodGet) {
+		return
+	}
+	output, err := h.workerAdapter.GetJobOutput(r.PathValue("id"))
+	if err != nil {
+		h.fail(w, r, http.StatusNotFound, api.CodeJobNotFound, "Job not found")
+		return
+	}
+	h.respond(w, r, http.StatusOK, api.NewJobOutput(output))
+}
+
+func (h *httpHandler) GetService(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, http.MethodGet) {
+		return
+	}
+	h.respond(w, r, http.StatusOK, api.NewServiceStatus(h.fileMonitorService.Status()))
+}
+
+// ServiceAction starts, stops, pauses or resumes the monitoring service and
+// answers with its new status.
+func (h *httpHandler) ServiceAction(w http.ResponseWriter, r *http.Request) {
+	actions := map[string]func() error{
+		"start":  h.fileMonitorService.Start,
+		"stop":   h.fileMonitorService.Stop,
+		"pause":  h.fileMonitorService.Pause,
+		"resume": h.fileMonitorService.Resume,
+	}
+	action, ok := actions[r.PathValue("action")]
+	if !ok {
+		h.fail(w, r, http.StatusNotFound, api.CodeNotFound, "Unknown action, use start, stop, pause or resume")
+		return
+	}
+	if !h.allow(w, r, http.MethodPost) {
+		return
+	}
+	switch err := action(); {
+	case errors.Is(err, ports.ErrInvalidTransition):
+		h.fail(w, r, http.StatusConflict, api.CodeInvalidTransition, "Service is "+string(h.fileMonitorService.Status().State))
+		return
+	case err != nil:
+		h.internalError(w, r, "Failed to change service state", err)
+		return
+	}
+	h.respond(w, r, http.StatusOK, api.NewServiceStatus(h.fileMonitorService.Status()))
+}
+
+func (h *httpHandler) NotFound(w http.ResponseWriter, r *http.Request) {
+	h.fail(w, r, http.StatusNotFound, api.CodeNotFound, "No such endpoint: "+r.URL.Path)
+}
+
+// allow answers 405 Method Not Allowed unless r uses one of methods.
+func (h *httpHandler) allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	h.fail(w, r, http.StatusMethodNotAllowed, api.CodeMethodNotAllowed, "Method not allowed")
+	return false
+}
+
+func (h *httpHandler) respond(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	writeResponse(w, r, status, api.Response{Status: api.StatusOK, Data: data})
+}
+
+func (h *httpHandler) fail(w http.ResponseWriter, r *http.Request, status int, code api.ErrorCode, message string) {
+	writeError(w, r, status, code, message)
+}
+
+// internalError logs err, which may reveal details the client should not
+// see, with the request ID the client gets.
+func (h *httpHandler) internalError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	h.logger.Error(message, "request_id", requestID(r), "error", err)
+	h.fail(w, r, http.StatusInternalServerError, api.CodeInternal, message)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code api.ErrorCode, message string) {
+	writeResponse(w, r, status, api.Response{Status: api.StatusError, Error: &api.Error{Code: code, Message: message}})
+}
+
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, response api.Response) {
+	response.RequestID = requestID(r)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,164 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+	"file-mod-tracker/internal/ports/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jobsWorker implements the job part of WorkerAdapter.
+type jobsWorker struct {
+	ports.WorkerAdapter
+	jobs map[string]domain.Job
+}
+
+func (w *jobsWorker) GetJobs() []domain.Job {
+	var jobs []domain.Job
+	for _, job := range w.jobs {
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func (w *jobsWorker) GetJob(id string) (domain.Job, error) {
+	job, ok := w.jobs[id]
+	if !ok {
+		return domain.Job{}, ports.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (w *jobsWorker) CancelJob(id string) error {
+	job, ok := w.jobs[id]
+	switch {
+	case !ok:
+		return ports.ErrJobNotFound
+	case job.State.Finished():
+		return ports.ErrJobFinished
+	}
+	job.State = domain.JobCancelled
+	w.jobs[id] = job
+	return nil
+}
+
+// fullQueueService accepts the first command of every request only.
+type fullQueueService struct {
+	lifecycleService
+}
+
+func (s *fullQueueService) EnqueueJobs(specs []domain.CommandSpec, opts domain.EnqueueOptions) ([]domain.Job, error) {
+	return []domain.Job{{ID: "first", Command: strings.Join(specs[0].Argv, " "), State: domain.JobQueued}}, ports.ErrQueueFull
+}
+
+type envelope struct {
+	api.Response
+	Data json.RawMessage `json:"data"`
+}
+
+func serve(t *testing.T, handler http.Handler, method, path, body string, header http.Header) (*httptest.ResponseRecorder, envelope) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	for key, values := range header {
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
+	}
+	handler.ServeHTTP(recorder, request)
+
+	var decoded envelope
+	if strings.HasPrefix(path, apiPrefix) {
+		assert.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-Type"))
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &decoded), recorder.Body.String())
+		assert.Equal(t, recorder.Header().Get(api.RequestIDHeader), decoded.RequestID)
+	}
+	return recorder, decoded
+}
+
+func TestHandler_Envelope(t *testing.T) {
+	worker := &jobsWorker{jobs: map[string]domain.Job{
+		"running": {ID: "running", State: domain.JobRunning},
+		"done":    {ID: "done", State: domain.JobSucceeded},
+	}}
+	handler := NewServer(&fullQueueService{lifecycleService{state: domain.ServiceRunning}}, nopLogger{}, worker).Handler()
+
+	recorder, got := serve(t, handler, http.MethodGet, "/api/v1/jobs/done", "", http.Header{api.RequestIDHeader: {"req-42"}})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, api.StatusOK, got.Status)
+	assert.Equal(t, "req-42", got.RequestID)
+	assert.Nil(t, got.Error)
+	var job api.Job
+	require.NoError(t, json.Unmarshal(got.Data, &job))
+	assert.Equal(t, "done", job.ID)
+
+	recorder, got = serve(t, handler, http.MethodDelete, "/api/v1/jobs/running", "", nil)
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Contains(t, string(got.Data), `"state":"cancelled"`)
+	assert.Len(t, got.RequestID, 16, "a request ID is assigned when the client sent none")
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		code               api.ErrorCode
+	}{
+		{http.MethodDelete, "/api/v1/jobs/done", "", http.StatusConflict, api.CodeJobFinished},
+		{http.MethodGet, "/api/v1/jobs/missing", "", http.StatusNotFound, api.CodeJobNotFound},
+		{http.MethodPut, "/api/v1/jobs/done", "", http.StatusMethodNotAllowed, api.CodeMethodNotAllowed},
+		{http.MethodGet, "/api/v1/file-stats", "", http.StatusBadRequest, api.CodeInvalidRequest},
+		{http.MethodGet, "/api/v1/nothing", "", http.StatusNotFound, api.CodeNotFound},
+		{http.MethodPost, "/api/v1/jobs", "{", http.StatusBadRequest, api.CodeInvalidRequest},
+		{http.MethodPost, "/api/v1/service/start", "", http.StatusConflict, api.CodeInvalidTransition},
+	} {
+		recorder, got := serve(t, handler, tc.method, tc.path, tc.body, nil)
+		assert.Equal(t, tc.status, recorder.Code, tc.path)
+		assert.Equal(t, api.StatusError, got.Status, tc.path)
+		require.NotNil(t, got.Error, tc.path)
+		assert.Equal(t, tc.code, got.Error.Code, tc.path)
+	}
+
+	// A full queue still reports the jobs it accepted
+	recorder, got = serve(t, handler, http.MethodPost, "/api/v1/jobs", `["echo one", "echo two"]`, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, api.CodeQueueFull, got.Error.Code)
+	assert.Contains(t, string(got.Data), `"id":"first"`)
+}
+
+func TestHandler_RedactsJobSecrets(t *testing.T) {
+	secret := domain.Job{ID: "secret", State: domain.JobQueued, CommandSpec: domain.CommandSpec{
+		Argv:  []string{"deploy"},
+		Env:   map[string]string{"TOKEN": "s3cret"},
+		Stdin: "password",
+	}}
+	worker := &jobsWorker{jobs: map[string]domain.Job{"secret": secret}}
+	handler := NewServer(&lifecycleService{state: domain.ServiceRunning}, nopLogger{}, worker).Handler()
+
+	for _, path := range []string{"/api/v1/jobs", "/api/v1/jobs/secret", "/jobs", "/jobs/secret"} {
+		recorder, _ := serve(t, handler, http.MethodGet, path, "", nil)
+		assert.Equal(t, http.StatusOK, recorder.Code, path)
+		assert.Contains(t, recorder.Body.String(), `"TOKEN":"[redacted]"`, path)
+		assert.NotContains(t, recorder.Body.String(), "s3cret", path)
+		assert.NotContains(t, recorder.Body.String(), "password", path)
+	}
+	assert.Equal(t, "s3cret", worker.jobs["secret"].Env["TOKEN"], "the job itself keeps its environment")
+}
+
+func TestHandler_DeprecatedRoutes(t *testing.T) {
+	worker := &jobsWorker{jobs: map[string]domain.Job{"done": {ID: "done", State: domain.JobSucceeded}}}
+	handler := NewServer(&lifecycleService{state: domain.ServiceRunning}, nopLogger{}, worker).Handler()
+
+	recorder, _ := serve(t, handler, http.MethodGet, "/jobs/done", "", nil)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "true", recorder.Header().Get("Deprecation"))
+	assert.Equal(t, `</api/v1/jobs/done>; rel="successor-version"`, recorder.Header().Get("Link"))
+	assert.NotEmpty(t, recorder.Header().Get(api.RequestIDHeader))
+	assert.True(t, strings.HasPrefix(recorder.Body.String(), `{"ID":"done"`), "old routes keep their format")
+}
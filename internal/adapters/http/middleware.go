@@ -0,0 +1,84 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"file-mod-tracker/internal/ports/api"
+)
+
+// maxRequestIDLength caps request IDs chosen by clients.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// withRequestID gives every request an ID, taken from the X-Request-ID
+// header when the client sent a usable one, and echoes it in the response.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(api.RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(api.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the ID withRequestID gave r.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID accepts printable ASCII without spaces, so that IDs are
+// safe to log and to send back.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// deprecated marks a route that predates /api/v1. It answers as before and
+// points to its successor with the Deprecation and Link headers. Wildcards
+// such as {id} in successor are filled in from the request. Requests are
+// validated against the successor's operation in openapi.json, but errors
+// are answered in plain text like the rest of the route.
+func deprecated(successor string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		segments := strings.Split(successor, "/")
+		link := make([]string, len(segments))
+		for i, segment := range segments {
+			link[i] = segment
+			if isParameter(segment) {
+				segments[i] = r.PathValue(segment[1 : len(segment)-1])
+				link[i] = url.PathEscape(segments[i])
+			}
+		}
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+strings.Join(link, "/")+`>; rel="successor-version"`)
+		if err := apiSpec.check(w, r, strings.TrimPrefix(strings.Join(segments, "/"), apiPrefix)); err != nil {
+			http.Error(w, err.message, err.status)
+			return
+		}
+		handler(w, r)
+	}
+}
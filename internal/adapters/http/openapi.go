@@ -0,0 +1,542 @@
+package http
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"file-mod-tracker/internal/ports/api"
+)
+
+// openAPIDocument describes the API below /api/v1. Requests are validated
+// against it, so it has to be kept up to date with the handlers; the tests
+// fail when the two disagree.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// apiSpec is openAPIDocument, parsed.
+var apiSpec = mustLoadOpenAPI(openAPIDocument)
+
+// maxRequestBody caps the size of request bodies the API reads.
+const maxRequestBody = 1 << 20
+
+// openAPI holds the parts of an OpenAPI 3.0 document needed to validate
+// requests and responses. Schemas support the keywords the document uses:
+// $ref, type, format, enum, pattern, minimum, minLength, minItems, items,
+// properties, required, additionalProperties and oneOf.
+type openAPI struct {
+	// Paths maps a path template to its operations by lower-case method.
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+
+	// templates lists the keys of Paths in the order find tries them.
+	templates []string
+}
+
+type operation struct {
+	Parameters  []parameter          `json:"parameters"`
+	RequestBody *requestBody         `json:"requestBody"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Name     string          `json:"name"`
+	In       string          `json:"in"`
+	Required bool            `json:"required"`
+	Schema   *schema         `json:"schema"`
+	Example  json.RawMessage `json:"example"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Content map[string]*mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema  *schema         `json:"schema"`
+	Example json.RawMessage `json:"example"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []interface{}      `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	Minimum              *float64           `json:"minimum"`
+	MinLength            int                `json:"minLength"`
+	MinItems             int                `json:"minItems"`
+	Items                *schema            `json:"items"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	OneOf                []*schema          `json:"oneOf"`
+
+	// Filled in by compile
+	target     *schema
+	pattern    *regexp.Regexp
+	closed     bool
+	additional *schema
+}
+
+func mustLoadOpenAPI(data []byte) *openAPI {
+	spec, err := loadOpenAPI(data)
+	if err != nil {
+		panic("openapi.json: " + err.Error())
+	}
+	return spec
+}
+
+func loadOpenAPI(data []byte) (*openAPI, error) {
+	var spec openAPI
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	compiled := make(map[*schema]bool)
+	compile := func(s *schema) error { return spec.compile(s, compiled) }
+	for _, s := range spec.Components.Schemas {
+		if err := compile(s); err != nil {
+			return nil, err
+		}
+	}
+	for path, operations := range spec.Paths {
+		spec.templates = append(spec.templates, path)
+		for method, op := range operations {
+			for _, param := range op.Parameters {
+				if param.Schema == nil {
+					return nil, fmt.Errorf("%s %s: parameter %s has no schema", method, path, param.Name)
+				}
+				if err := compile(param.Schema); err != nil {
+					return nil, err
+				}
+			}
+			var media []*mediaType
+			if op.RequestBody != nil {
+				media = append(media, op.RequestBody.Content["application/json"])
+			}
+			for _, resp := range op.Responses {
+				media = append(media, resp.Content["application/json"])
+			}
+			for _, m := range media {
+				if m == nil || m.Schema == nil {
+					return nil, fmt.Errorf("%s %s: content without an application/json schema", method, path)
+				}
+				if err := compile(m.Schema); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	sort.Slice(spec.templates, func(i, j int) bool {
+		return moreSpecific(spec.templates[i], spec.templates[j])
+	})
+	return &spec, nil
+}
+
+// moreSpecific orders path templates so that, at the first segment where
+// they differ, a literal segment comes before a parameter. A path such as
+// "/jobs/stats" then matches "/jobs/stats" rather than "/jobs/{id}".
+func moreSpecific(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if aParam, bParam := isParameter(as[i]), isParameter(bs[i]); aParam != bParam {
+			return bParam
+		}
+	}
+	return a < b
+}
+
+func isParameter(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// compile resolves references, compiles patterns and reads
+// additionalProperties, for s and every schema below it.
+func (spec *openAPI) compile(s *schema, compiled map[*schema]bool) error {
+	if s == nil || compiled[s] {
+		return nil
+	}
+	compiled[s] = true
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		if s.target = spec.Components.Schemas[name]; s.target == nil {
+			return fmt.Errorf("unknown reference %s", s.Ref)
+		}
+		return spec.compile(s.target, compiled)
+	}
+	if s.Pattern != "" {
+		var err error
+		if s.pattern, err = regexp.Compile(s.Pattern); err != nil {
+			return err
+		}
+	}
+	switch raw := bytes.TrimSpace(s.AdditionalProperties); {
+	case len(raw) == 0, string(raw) == "true":
+	case string(raw) == "false":
+		s.closed = true
+	default:
+		if err := json.Unmarshal(raw, &s.additional); err != nil {
+			return err
+		}
+	}
+	children := append([]*schema{s.Items, s.additional}, s.OneOf...)
+	for _, property := range s.Properties {
+		children = append(children, property)
+	}
+	for _, child := range children {
+		if err := spec.compile(child, compiled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// find returns the operation for method and an API path such as
+// "/jobs/42", with the values of its path parameters. Templates with a
+// literal segment are preferred over templates with a parameter there.
+func (spec *openAPI) find(method, path string) (*operation, map[string]string) {
+	segments := strings.Split(path, "/")
+	for _, template := range spec.templates {
+		op := spec.Paths[template][strings.ToLower(method)]
+		if op == nil {
+			continue
+		}
+		parts := strings.Split(template, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		values := make(map[string]string)
+		for i, part := range parts {
+			if isParameter(part) && segments[i] != "" {
+				values[part[1:len(part)-1]] = segments[i]
+			} else if part != segments[i] {
+				values = nil
+				break
+			}
+		}
+		if values != nil {
+			return op, values
+		}
+	}
+	return nil, nil
+}
+
+// validateRequests answers API requests whose parameters or body do not
+// match the OpenAPI document with an error. Requests for paths or methods
+// the document does not know are passed on for next to answer.
+func validateRequests(spec *openAPI, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := spec.check(w, r, strings.TrimPrefix(r.URL.Path, apiPrefix)); err != nil {
+			writeError(w, r, err.status, err.code, err.message)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestError is why a request does not match the OpenAPI document.
+type requestError struct {
+	status  int
+	code    api.ErrorCode
+	message string
+}
+
+// check validates r as a request for the API path, such as "/jobs/42",
+// against the operation the document describes for it. It returns nil when
+// r matches, or when the document does not know the path or method. A
+// validated body is put back in r for the handler to read.
+func (spec *openAPI) check(w http.ResponseWriter, r *http.Request, path string) *requestError {
+	op, pathValues := spec.find(r.Method, path)
+	if op == nil {
+		return nil
+	}
+
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		var value string
+		var present bool
+		switch param.In {
+		case "path":
+			value, present = pathValues[param.Name]
+		case "query":
+			value, present = query.Get(param.Name), query.Has(param.Name)
+		default:
+			continue
+		}
+		if !present {
+			if param.Required {
+				return &requestError{http.StatusBadRequest, api.CodeInvalidRequest, fmt.Sprintf("Missing %s parameter %s", param.In, param.Name)}
+			}
+			continue
+		}
+		if err := param.Schema.validateString(value); err != nil {
+			if param.In == "path" {
+				// The path names something that does not exist
+				return &requestError{http.StatusNotFound, api.CodeNotFound, "No such endpoint: " + r.URL.Path}
+			}
+			return &requestError{http.StatusBadRequest, api.CodeInvalidRequest, fmt.Sprintf("Invalid %s parameter %s: %v", param.In, param.Name, err)}
+		}
+	}
+
+	if op.RequestBody != nil {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+		if err != nil {
+			return &requestError{http.StatusBadRequest, api.CodeInvalidRequest, "Invalid request body: " + err.Error()}
+		}
+		if err := op.RequestBody.validate(body); err != nil {
+			return &requestError{http.StatusBadRequest, api.CodeInvalidRequest, "Invalid request body: " + err.Error()}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return nil
+}
+
+func (b *requestBody) validate(body []byte) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if b.Required {
+			return errors.New("body is required")
+		}
+		return nil
+	}
+	value, err := decodeJSON(body)
+	if err != nil {
+		return err
+	}
+	return b.Content["application/json"].Schema.validate(value, "")
+}
+
+// decodeJSON decodes data keeping numbers as json.Number.
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+func (s *schema) resolve() *schema {
+	for s.target != nil {
+		s = s.target
+	}
+	return s
+}
+
+// validateString validates a parameter value, converting it to the type
+// the schema asks for first.
+func (s *schema) validateString(value string) error {
+	s = s.resolve()
+	switch s.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("must be %s %s", article(s.Type), s.Type)
+		}
+		return s.validate(json.Number(value), "")
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		return s.validate(b, "")
+	}
+	return s.validate(value, "")
+}
+
+// validate checks a decoded JSON value. at is the JSON pointer of value,
+// used in error messages.
+func (s *schema) validate(value interface{}, at string) error {
+	s = s.resolve()
+	fail := func(format string, args ...interface{}) error {
+		return &schemaError{at: at, message: fmt.Sprintf(format, args...)}
+	}
+
+	if len(s.OneOf) > 0 {
+		return s.validateOneOf(value, at, fail)
+	}
+	if s.Type != "" && !hasType(value, s.Type) {
+		return fail("must be %s %s", article(s.Type), s.Type)
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			found = found || fmt.Sprint(allowed) == fmt.Sprint(value)
+		}
+		if !found {
+			allowed := make([]string, len(s.Enum))
+			for i, v := range s.Enum {
+				allowed[i] = fmt.Sprint(v)
+			}
+			return fail("must be one of %s", strings.Join(allowed, ", "))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		switch {
+		case s.MinLength == 1 && v == "":
+			return fail("must not be empty")
+		case len(v) < s.MinLength:
+			return fail("must not be shorter than %d characters", s.MinLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return fail("must match %s", s.Pattern)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				return fail("must be an RFC 3339 timestamp")
+			}
+		}
+	case json.Number:
+		if s.Minimum != nil {
+			if f, _ := v.Float64(); f < *s.Minimum {
+				return fail("must be at least %v", *s.Minimum)
+			}
+		}
+	case []interface{}:
+		switch {
+		case s.MinItems == 1 && len(v) == 0:
+			return fail("must not be empty")
+		case len(v) < s.MinItems:
+			return fail("must have at least %d items", s.MinItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(item, at+"/"+strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fail("%s is required", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property := s.Properties[name]
+			if property == nil {
+				property = s.additional
+			}
+			if property == nil {
+				if s.closed {
+					return fail("unknown property %s", name)
+				}
+				continue
+			}
+			if err := property.validate(v[name], at+"/"+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// schemaError is a value that does not match its schema. at is the JSON
+// pointer of the value.
+type schemaError struct {
+	at      string
+	message string
+}
+
+func (e *schemaError) Error() string {
+	if e.at == "" {
+		return e.message
+	}
+	return e.at + ": " + e.message
+}
+
+// validateOneOf requires value to match exactly one alternative. When none
+// matches, the error of the only alternative of the right type explains the
+// problem best, or else that of the only one that failed below value.
+func (s *schema) validateOneOf(value interface{}, at string, fail func(string, ...interface{}) error) error {
+	var matched int
+	var candidates, deeper []error
+	for _, alternative := range s.OneOf {
+		err := alternative.validate(value, at)
+		if err == nil {
+			matched++
+			continue
+		}
+		if resolved := alternative.resolve(); resolved.Type == "" || hasType(value, resolved.Type) {
+			candidates = append(candidates, err)
+			var schemaErr *schemaError
+			if errors.As(err, &schemaErr) && len(schemaErr.at) > len(at) {
+				deeper = append(deeper, err)
+			}
+		}
+	}
+	switch {
+	case matched == 1:
+		return nil
+	case matched > 1:
+		return fail("matches more than one of the allowed forms")
+	case len(candidates) == 1:
+		return candidates[0]
+	case len(deeper) == 1:
+		return deeper[0]
+	}
+	return fail("does not match any of the allowed forms")
+}
+
+func hasType(value interface{}, typ string) bool {
+	switch v := value.(type) {
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case json.Number:
+		if typ == "number" {
+			return true
+		}
+		if typ != "integer" {
+			return false
+		}
+		if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return true
+		}
+		_, err := strconv.ParseUint(string(v), 10, 64)
+		return err == nil
+	case []interface{}:
+		return typ == "array"
+	case map[string]interface{}:
+		return typ == "object"
+	}
+	return false
+}
+
+func article(typ string) string {
+	if strings.ContainsRune("aeiou", rune(typ[0])) {
+		return "an"
+	}
+	return "a"
+}
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
@@ -0,0 +1,306 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+	"file-mod-tracker/internal/ports/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sampleTime = time.Date(2024, 9, 16, 8, 25, 27, 500, time.UTC)
+
+// sampleFile sets every field, so that all of them are checked against the
+// document.
+var sampleFile = domain.FileInfo{
+	Path: "/srv/app/main.go", LastModified: sampleTime, Size: 245, Mode: "0644",
+	UID: 501, GID: 20, Owner: "app", Group: "staff", Inode: 9183742, Device: 16777220, LinkCount: 1,
+	ChangeTime: sampleTime, AccessTime: sampleTime, SymlinkTarget: "/srv/app/real.go",
+	Hashes: map[string]string{domain.HashSHA256: "9f86d081884c7d65"},
+}
+
+var sampleReport = domain.ScanReport{
+	Root: "/srv/app", StartedAt: sampleTime, DurationMillis: 12, FilesScanned: 1, ErrorCount: 1,
+	Errors: []domain.ScanError{{Path: "/srv/app/private", Kind: domain.ScanErrorPermission, Message: "permission denied"}},
+}
+
+func sampleJob(id string, state domain.JobState) domain.Job {
+	exitCode := 0
+	return domain.Job{
+		ID: id, Command: "make check",
+		CommandSpec: domain.CommandSpec{Argv: []string{"make", "check"}, Env: map[string]string{"LC_ALL": "C"}, Cwd: "/srv/app", Stdin: "y\n"},
+		State:       state, ExitCode: &exitCode, Error: "exit status 2", TimeoutMillis: 90000, Queue: "dir:/srv/app",
+		Attempts: 1, EnqueuedAt: sampleTime, StartedAt: sampleTime, FinishedAt: sampleTime,
+	}
+}
+
+// sampleService answers with sample data. Enqueueing to the queue named
+// "full" fails with ErrQueueFull after the first command.
+type sampleService struct {
+	lifecycleService
+}
+
+func (s *sampleService) ScanDirectory(directory string) (domain.ScanResult, error) {
+	return domain.ScanResult{Files: []domain.FileInfo{sampleFile}, Report: sampleReport}, nil
+}
+
+func (s *sampleService) EnqueueJobs(specs []domain.CommandSpec, opts domain.EnqueueOptions) ([]domain.Job, error) {
+	jobs := []domain.Job{sampleJob("queued", domain.JobQueued)}
+	if opts.Queue == "full" {
+		return jobs, ports.ErrQueueFull
+	}
+	return jobs, nil
+}
+
+type sampleWorker struct {
+	jobsWorker
+}
+
+func (w *sampleWorker) GetFileChanges(filter domain.EventFilter) []domain.ChangeEvent {
+	return []domain.ChangeEvent{{Seq: 43, Root: "/srv/app", Type: domain.ChangeModified, Path: sampleFile.Path, Old: &sampleFile, New: &sampleFile, DetectedAt: sampleTime}}
+}
+
+func (w *sampleWorker) GetRootStatuses() []domain.RootStatus {
+	return []domain.RootStatus{{
+		Path: "/srv/app", WatchMode: domain.WatchModePoll, CheckFrequency: 60, LastScan: sampleTime, LastScanMillis: 12,
+		NextScan: sampleTime, FilesTracked: 1, EventsRecorded: 43, LastError: "scan failed", LastErrorAt: sampleTime, LastReport: &sampleReport,
+	}}
+}
+
+func (w *sampleWorker) GetJobOutput(id string) (domain.JobOutput, error) {
+	if _, err := w.GetJob(id); err != nil {
+		return domain.JobOutput{}, err
+	}
+	return domain.JobOutput{Stdout: "ok\n", Stderr: "warning\n", Truncated: true}, nil
+}
+
+type sampleReporter struct{}
+
+func (sampleReporter) Report([]domain.ChangeEvent) {}
+func (sampleReporter) Status() domain.ReporterStatus {
+	return domain.ReporterStatus{Endpoint: "https://example.com/events", Delivered: 10, Pending: 1, LastDelivery: sampleTime, LastError: "timeout", LastErrorAt: sampleTime, NextAttempt: sampleTime}
+}
+
+func newSampleHandler() http.Handler {
+	worker := &sampleWorker{jobsWorker{jobs: map[string]domain.Job{
+		"4f9c2a61d03b7e85": sampleJob("4f9c2a61d03b7e85", domain.JobRunning),
+		"done":             sampleJob("done", domain.JobSucceeded),
+	}}}
+	service := &sampleService{lifecycleService{state: domain.ServiceRunning}}
+	return NewServer(service, nopLogger{}, worker, WithReporter(sampleReporter{})).Handler()
+}
+
+// checkResponse sends a request and fails unless the document lists the
+// status it gets for the operation and the body matches the documented
+// schema. It returns the status.
+func checkResponse(t *testing.T, method, target, body string) int {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	newSampleHandler().ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	name := method + " " + target
+
+	path := strings.TrimPrefix(strings.SplitN(target, "?", 2)[0], apiPrefix)
+	op, _ := apiSpec.find(method, path)
+	var documented *response
+	if op != nil {
+		documented = op.Responses[strconv.Itoa(recorder.Code)]
+	} else if recorder.Code == http.StatusMethodNotAllowed || recorder.Code == http.StatusNotFound {
+		documented = &response{Content: map[string]*mediaType{"application/json": {Schema: apiSpec.Components.Schemas["ErrorResponse"]}}}
+	}
+	require.NotNil(t, documented, "%s: status %d is not documented: %s", name, recorder.Code, recorder.Body.String())
+
+	value, err := decodeJSON(recorder.Body.Bytes())
+	require.NoError(t, err, name)
+	assert.NoError(t, documented.Content["application/json"].Schema.validate(value, ""), "%s: %s", name, recorder.Body.String())
+	return recorder.Code
+}
+
+// exampleRequest builds a request for op from the examples in the document.
+func exampleRequest(t *testing.T, template string, op *operation) (string, string) {
+	query := url.Values{}
+	path := template
+	for _, param := range op.Parameters {
+		require.NotEmpty(t, param.Example, "%s: parameter %s has no example", template, param.Name)
+		var example interface{}
+		require.NoError(t, json.Unmarshal(param.Example, &example))
+		value := fmt.Sprint(example)
+		if param.In == "path" {
+			path = strings.Replace(path, "{"+param.Name+"}", url.PathEscape(value), 1)
+		} else {
+			query.Set(param.Name, value)
+		}
+	}
+	target := apiPrefix + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var body string
+	if op.RequestBody != nil {
+		example := op.RequestBody.Content["application/json"].Example
+		require.NotEmpty(t, example, "%s: request body has no example", template)
+		body = string(example)
+	}
+	return target, body
+}
+
+func TestOpenAPI_DescribesEveryRoute(t *testing.T) {
+	var routed, documented []string
+	for path := range routes(NewHandler(nil, nil, nil, nopLogger{})) {
+		routed = append(routed, path)
+	}
+	for path := range apiSpec.Paths {
+		documented = append(documented, path)
+	}
+	sort.Strings(routed)
+	sort.Strings(documented)
+	assert.Equal(t, routed, documented)
+}
+
+func TestOpenAPI_MatchesHandlers(t *testing.T) {
+	for template, operations := range apiSpec.Paths {
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			op := operations[strings.ToLower(method)]
+			if op == nil {
+				target := apiPrefix + strings.NewReplacer("{id}", "done", "{action}", "pause").Replace(template)
+				assert.Equal(t, http.StatusMethodNotAllowed, checkResponse(t, method, target, ""), "%s %s is not documented", method, template)
+				continue
+			}
+			target, body := exampleRequest(t, template, op)
+			status := checkResponse(t, method, target, body)
+			assert.Less(t, status, 300, "%s %s fails with the documented examples", method, target)
+		}
+	}
+
+	// The documented failures
+	for _, tc := range []struct{ method, target, body string }{
+		{http.MethodGet, "/api/v1/file-stats", ""},
+		{http.MethodGet, "/api/v1/jobs/missing", ""},
+		{http.MethodDelete, "/api/v1/jobs/done", ""},
+		{http.MethodGet, "/api/v1/jobs/missing/output", ""},
+		{http.MethodPost, "/api/v1/service/start", ""},
+		{http.MethodPost, "/api/v1/service/restart", ""},
+		{http.MethodPost, "/api/v1/jobs", `["ls", {"argv": []}]`},
+		{http.MethodPost, "/api/v1/jobs?queue=full", `["ls", "ls"]`},
+		{http.MethodGet, "/api/v1/logs?after=-1", ""},
+	} {
+		assert.GreaterOrEqual(t, checkResponse(t, tc.method, tc.target, tc.body), 400, tc.method+" "+tc.target)
+	}
+}
+
+func TestValidateRequests(t *testing.T) {
+	for _, tc := range []struct {
+		method, target, body string
+		status               int
+		message              string
+	}{
+		{http.MethodGet, "/api/v1/file-stats", "", http.StatusBadRequest, "Missing query parameter directory"},
+		{http.MethodGet, "/api/v1/file-stats?directory=/srv&since=yesterday", "", http.StatusBadRequest, "Invalid query parameter since: must be an RFC 3339 timestamp"},
+		{http.MethodGet, "/api/v1/logs?after=x", "", http.StatusBadRequest, "Invalid query parameter after: must be an integer"},
+		{http.MethodGet, "/api/v1/jobs?state=done", "", http.StatusBadRequest, "Invalid query parameter state: must be one of queued, running"},
+		{http.MethodPost, "/api/v1/jobs?timeout=soon", `["ls"]`, http.StatusBadRequest, "Invalid query parameter timeout: must match"},
+		{http.MethodPost, "/api/v1/jobs", ``, http.StatusBadRequest, "Invalid request body: body is required"},
+		{http.MethodPost, "/api/v1/jobs", `["ls", {"argv": ["ls"], "shell": true}]`, http.StatusBadRequest, "Invalid request body: /1: unknown property shell"},
+		{http.MethodPost, "/api/v1/jobs", `[""]`, http.StatusBadRequest, "Invalid request body: /0: must not be empty"},
+		{http.MethodPost, "/api/v1/jobs", `[{"argv": []}]`, http.StatusBadRequest, "Invalid request body: /0/argv: must not be empty"},
+		{http.MethodPost, "/api/v1/jobs", `{"command": [42]}`, http.StatusBadRequest, "Invalid request body: /command/0: does not match any of the allowed forms"},
+		{http.MethodPost, "/api/v1/service/restart", "", http.StatusNotFound, "No such endpoint"},
+		{http.MethodPost, "/api/v1/jobs?timeout=90", `{"argv": ["ls"], "env": {"A": "1"}}`, http.StatusAccepted, ""},
+		{http.MethodGet, "/api/v1/logs?after=18446744073709551615", "", http.StatusOK, ""},
+	} {
+		recorder := httptest.NewRecorder()
+		newSampleHandler().ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+		name := tc.method + " " + tc.target + " " + tc.body
+		assert.Equal(t, tc.status, recorder.Code, name)
+
+		var got api.Response
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got), name)
+		if tc.message != "" {
+			require.NotNil(t, got.Error, name)
+			assert.Contains(t, got.Error.Message, tc.message, name)
+		}
+	}
+}
+
+func TestValidateRequests_DeprecatedRoutes(t *testing.T) {
+	for _, tc := range []struct {
+		method, target, body string
+		status               int
+		message              string
+	}{
+		{http.MethodPost, "/enqueue-commands", `["ls", {"argv": ["ls"], "shell": true}]`, http.StatusBadRequest, "Invalid request body: /1: unknown property shell\n"},
+		{http.MethodPost, "/enqueue-commands?timeout=soon", `["ls"]`, http.StatusBadRequest, "Invalid query parameter timeout: must match"},
+		{http.MethodGet, "/jobs?state=done", "", http.StatusBadRequest, "Invalid query parameter state: must be one of queued, running"},
+		{http.MethodGet, "/logs?after=x", "", http.StatusBadRequest, "Invalid query parameter after: must be an integer"},
+		{http.MethodPost, "/enqueue-commands", `{"command": ["ls"]}`, http.StatusAccepted, ""},
+	} {
+		recorder := httptest.NewRecorder()
+		newSampleHandler().ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+		name := tc.method + " " + tc.target + " " + tc.body
+		assert.Equal(t, tc.status, recorder.Code, name)
+		assert.Equal(t, "true", recorder.Header().Get("Deprecation"), name)
+		if tc.message != "" {
+			assert.Equal(t, "text/plain; charset=utf-8", recorder.Header().Get("Content-Type"), name)
+			assert.Contains(t, recorder.Body.String(), tc.message, name)
+		}
+	}
+}
+
+func TestDeprecatedFileStats_ListsBareFiles(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	newSampleHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/file-stats?directory=/srv/app", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var files []domain.FileInfo
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &files), recorder.Body.String())
+	require.Len(t, files, 1)
+	assert.Equal(t, sampleFile.Path, files[0].Path)
+
+	recorder = httptest.NewRecorder()
+	newSampleHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/file-stats?directory=/srv/app&since=2024-09-17T00:00:00Z", nil))
+	assert.JSONEq(t, "[]", recorder.Body.String())
+}
+
+func TestOpenAPI_FindPrefersLiteralSegments(t *testing.T) {
+	spec, err := loadOpenAPI([]byte(`{"paths": {
+		"/jobs/{id}": {"get": {}},
+		"/jobs/stats": {"get": {}},
+		"/{kind}/stats": {"get": {}}
+	}}`))
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		op, values := spec.find(http.MethodGet, "/jobs/stats")
+		assert.Same(t, spec.Paths["/jobs/stats"]["get"], op)
+		assert.Empty(t, values)
+
+		op, values = spec.find(http.MethodGet, "/jobs/42")
+		assert.Same(t, spec.Paths["/jobs/{id}"]["get"], op)
+		assert.Equal(t, map[string]string{"id": "42"}, values)
+
+		op, _ = spec.find(http.MethodGet, "/roots/stats")
+		assert.Same(t, spec.Paths["/{kind}/stats"]["get"], op)
+	}
+}
+
+func TestServer_ServesOpenAPIDocument(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	newSampleHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var document struct{ OpenAPI string }
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &document))
+	assert.Equal(t, "3.0.3", document.OpenAPI)
+}
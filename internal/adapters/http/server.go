@@ -1,10 +1,18 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
+	"file-mod-tracker/internal/core/domain"
 	"file-mod-tracker/internal/ports"
+	"file-mod-tracker/internal/ports/api"
 	"file-mod-tracker/pkg/logger"
 )
 
@@ -12,24 +20,92 @@ type Server struct {
 	fileMonitorService ports.FileMonitorService
 	logger             logger.Logger
 	workerAdapter      ports.WorkerAdapter
+	reporter           ports.ChangeReporter
+	api                api.HTTPHandler
+
+	mu         sync.Mutex
+	httpServer *http.Server
+	closed     bool
+}
+
+// Option customises a Server at construction time.
+type Option func(*Server)
+
+// WithReporter includes the delivery status of reporter in /health.
+func WithReporter(reporter ports.ChangeReporter) Option {
+	return func(s *Server) {
+		s.reporter = reporter
+	}
 }
 
-func NewServer(fileMonitorService ports.FileMonitorService, logger logger.Logger, workerAdapter ports.WorkerAdapter) *Server {
-	return &Server{
+func NewServer(fileMonitorService ports.FileMonitorService, logger logger.Logger, workerAdapter ports.WorkerAdapter, opts ...Option) *Server {
+	s := &Server{
 		fileMonitorService: fileMonitorService,
 		logger:             logger,
 		workerAdapter:      workerAdapter,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.api = NewHandler(fileMonitorService, workerAdapter, s.reporter, logger)
+	return s
+}
+
+// health is the /health response.
+type health struct {
+	Status   string
+	Service  domain.ServiceStatus
+	Reporter *domain.ReporterStatus `json:",omitempty"`
+}
+
+// Handler returns the server's routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	register(mux, s.api)
+
+	// The routes from before /api/v1 answer in their old format
+	mux.HandleFunc("/file-stats", deprecated(apiPrefix+"/file-stats", s.handleFileStats))
+	mux.HandleFunc("/enqueue-commands", deprecated(apiPrefix+"/jobs", s.handleEnqueueCommands))
+	mux.HandleFunc("/health", deprecated(apiPrefix+"/health", s.handleHealthCheck))
+	mux.HandleFunc("/logs", deprecated(apiPrefix+"/logs", s.handleGetLogs))
+	mux.HandleFunc("/roots", deprecated(apiPrefix+"/roots", s.handleGetRoots))
+	mux.HandleFunc("GET /jobs", deprecated(apiPrefix+"/jobs", s.handleGetJobs))
+	mux.HandleFunc("GET /jobs/{id}", deprecated(apiPrefix+"/jobs/{id}", s.handleGetJob))
+	mux.HandleFunc("GET /jobs/{id}/output", deprecated(apiPrefix+"/jobs/{id}/output", s.handleGetJobOutput))
+	mux.HandleFunc("DELETE /jobs/{id}", deprecated(apiPrefix+"/jobs/{id}", s.handleCancelJob))
+	mux.HandleFunc("GET /service", deprecated(apiPrefix+"/service", s.handleGetService))
+	mux.HandleFunc("POST /service/{action}", deprecated(apiPrefix+"/service/{action}", s.handleServiceAction))
+	return withRequestID(mux)
 }
 
+// Start serves HTTP on port until Shutdown is called, and then returns
+// http.ErrServerClosed.
 func (s *Server) Start(port string) error {
-	http.HandleFunc("/file-stats", s.handleFileStats)
-	http.HandleFunc("/enqueue-commands", s.handleEnqueueCommands)
-	http.HandleFunc("/health", s.handleHealthCheck)
-	http.HandleFunc("/logs", s.handleGetLogs)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return http.ErrServerClosed
+	}
+	s.httpServer = &http.Server{Addr: ":" + port, Handler: s.Handler()}
+	httpServer := s.httpServer
+	s.mu.Unlock()
 
 	s.logger.Info("Starting HTTP server", "port", port)
-	return http.ListenAndServe(":"+port, nil)
+	return httpServer.ListenAndServe()
+}
+
+// Shutdown stops accepting connections and waits for the requests in
+// flight to finish, or for ctx to be done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	httpServer := s.httpServer
+	s.mu.Unlock()
+	if httpServer == nil {
+		return nil
+	}
+	s.logger.Info("Stopping HTTP server")
+	return httpServer.Shutdown(ctx)
 }
 
 func (s *Server) handleFileStats(w http.ResponseWriter, r *http.Request) {
@@ -44,14 +120,21 @@ func (s *Server) handleFileStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stats, err := s.fileMonitorService.GetFileStats(directory)
+	timeRange, err := parseTimeRange(r)
+	if err != nil {
+		http.Error(w, "Invalid time range: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.fileMonitorService.ScanDirectory(directory)
 	if err != nil {
 		s.logger.Error("Failed to get file stats", "error", err)
 		http.Error(w, "Failed to get file stats", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(stats)
+	// The scan report is only served by the successor of this route.
+	json.NewEncoder(w).Encode(domain.FilterByModTime(result.Files, timeRange))
 }
 
 func (s *Server) handleEnqueueCommands(w http.ResponseWriter, r *http.Request) {
@@ -60,19 +143,152 @@ func (s *Server) handleEnqueueCommands(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var commands []string
-	if err := json.NewDecoder(r.Body).Decode(&commands); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	commands, err := decodeCommands(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timeout, err := parseTimeout(r)
+	if err != nil {
+		http.Error(w, "Invalid timeout: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := s.fileMonitorService.EnqueueCommands(commands); err != nil {
+	jobs, err := s.fileMonitorService.EnqueueJobs(commands, domain.EnqueueOptions{
+		Timeout: timeout,
+		Queue:   r.URL.Query().Get("queue"),
+	})
+	var rejected *ports.CommandRejectedError
+	switch {
+	case errors.As(err, &rejected):
+		http.Error(w, fmt.Sprintf("Command %q rejected: %s", rejected.Command, rejected.Reason), http.StatusForbidden)
+		return
+	case errors.Is(err, ports.ErrQueueFull):
+		// The jobs queued before the queue filled up still run.
+		writeJSON(w, http.StatusServiceUnavailable, redactJobs(jobs))
+		return
+	case errors.Is(err, ports.ErrWorkerStopped):
+		http.Error(w, "Worker is stopped", http.StatusServiceUnavailable)
+		return
+	case err != nil:
 		s.logger.Error("Failed to enqueue commands", "error", err)
 		http.Error(w, "Failed to enqueue commands", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, http.StatusAccepted, redactJobs(jobs))
+}
+
+func (s *Server) handleGetJobs(w http.ResponseWriter, r *http.Request) {
+	state := domain.JobState(r.URL.Query().Get("state"))
+	jobs := s.workerAdapter.GetJobs()
+	if state != "" {
+		filtered := jobs[:0]
+		for _, job := range jobs {
+			if job.State == state {
+				filtered = append(filtered, job)
+			}
+		}
+		jobs = filtered
+	}
+	json.NewEncoder(w).Encode(redactJobs(jobs))
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.workerAdapter.GetJob(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(redactJob(job))
+}
+
+func (s *Server) handleGetJobOutput(w http.ResponseWriter, r *http.Request) {
+	output, err := s.workerAdapter.GetJobOutput(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(output)
+}
+
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	switch err := s.workerAdapter.CancelJob(id); {
+	case errors.Is(err, ports.ErrJobNotFound):
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ports.ErrJobFinished):
+		http.Error(w, "Job already finished", http.StatusConflict)
+		return
+	case err != nil:
+		s.logger.Error("Failed to cancel job", "job", id, "error", err)
+		http.Error(w, "Failed to cancel job", http.StatusInternalServerError)
+		return
+	}
+
+	// A running job is only marked cancelled once its process has exited.
+	job, _ := s.workerAdapter.GetJob(id)
+	writeJSON(w, http.StatusAccepted, redactJob(job))
+}
+
+func (s *Server) handleGetService(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.fileMonitorService.Status())
+}
+
+// handleServiceAction starts, stops, pauses or resumes the monitoring
+// service and answers with its new status. Stopping waits for the queued
+// commands to drain.
+func (s *Server) handleServiceAction(w http.ResponseWriter, r *http.Request) {
+	actions := map[string]func() error{
+		"start":  s.fileMonitorService.Start,
+		"stop":   s.fileMonitorService.Stop,
+		"pause":  s.fileMonitorService.Pause,
+		"resume": s.fileMonitorService.Resume,
+	}
+	action, ok := actions[r.PathValue("action")]
+	if !ok {
+		http.Error(w, "Unknown action, use start, stop, pause or resume", http.StatusNotFound)
+		return
+	}
+	switch err := action(); {
+	case errors.Is(err, ports.ErrInvalidTransition):
+		http.Error(w, "Service is "+string(s.fileMonitorService.Status().State), http.StatusConflict)
+		return
+	case err != nil:
+		s.logger.Error("Failed to change service state", "action", r.PathValue("action"), "error", err)
+		http.Error(w, "Failed to change service state", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.fileMonitorService.Status())
+}
+
+// parseTimeout reads the optional timeout query parameter, either a Go
+// duration such as "90s" or a number of seconds.
+func parseTimeout(r *http.Request) (time.Duration, error) {
+	value := r.URL.Query().Get("timeout")
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, fmt.Errorf("timeout must be a duration such as 90s or a number of seconds")
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be positive")
+	}
+	return timeout, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
 }
 
 func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
@@ -81,9 +297,12 @@ func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Implement health check logic here
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	response := health{Status: "OK", Service: s.fileMonitorService.Status()}
+	if s.reporter != nil {
+		status := s.reporter.Status()
+		response.Reporter = &status
+	}
+	writeJSON(w, http.StatusOK, response)
 }
 
 func (s *Server) handleGetLogs(w http.ResponseWriter, r *http.Request) {
@@ -92,6 +311,57 @@ func (s *Server) handleGetLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fileChanges := s.workerAdapter.GetFileChanges()
+	timeRange, err := parseTimeRange(r)
+	if err != nil {
+		http.Error(w, "Invalid time range: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := domain.EventFilter{TimeRange: timeRange}
+	if after := r.URL.Query().Get("after"); after != "" {
+		filter.AfterSeq, err = strconv.ParseUint(after, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid after: must be an event sequence number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	fileChanges := s.workerAdapter.GetFileChanges(filter)
 	json.NewEncoder(w).Encode(fileChanges)
 }
+
+func (s *Server) handleGetRoots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(s.workerAdapter.GetRootStatuses())
+}
+
+// parseTimeRange reads the optional since and until query parameters, both
+// RFC 3339 timestamps.
+func parseTimeRange(r *http.Request) (domain.TimeRange, error) {
+	var timeRange domain.TimeRange
+	for _, param := range []struct {
+		name   string
+		target *time.Time
+	}{
+		{"since", &timeRange.Since},
+		{"until", &timeRange.Until},
+	} {
+		value := r.URL.Query().Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return domain.TimeRange{}, fmt.Errorf("%s must be an RFC 3339 timestamp", param.name)
+		}
+		*param.target = t
+	}
+	if !timeRange.Since.IsZero() && !timeRange.Until.IsZero() && timeRange.Until.Before(timeRange.Since) {
+		return domain.TimeRange{}, fmt.Errorf("until is before since")
+	}
+	return timeRange, nil
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+func (nopLogger) Fatal(string, ...interface{}) {}
+
+func TestServer_ShutdownStopsStart(t *testing.T) {
+	s := NewServer(nil, nopLogger{}, nil)
+	result := make(chan error, 1)
+	go func() { result <- s.Start("0") }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.httpServer != nil
+	}, time.Second, time.Millisecond)
+	require.NoError(t, s.Shutdown(ctx))
+
+	select {
+	case err := <-result:
+		assert.ErrorIs(t, err, http.ErrServerClosed)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start did not return after Shutdown")
+	}
+	assert.ErrorIs(t, s.Start("0"), http.ErrServerClosed)
+}
+
+// lifecycleService implements the lifecycle part of FileMonitorService.
+type lifecycleService struct {
+	ports.FileMonitorService
+	state domain.ServiceState
+}
+
+func (s *lifecycleService) move(from, to domain.ServiceState) error {
+	if s.state != from {
+		return ports.ErrInvalidTransition
+	}
+	s.state = to
+	return nil
+}
+
+func (s *lifecycleService) Start() error  { return s.move(domain.ServiceStopped, domain.ServiceRunning) }
+func (s *lifecycleService) Stop() error   { return s.move(domain.ServiceRunning, domain.ServiceStopped) }
+func (s *lifecycleService) Pause() error  { return s.move(domain.ServiceRunning, domain.ServicePaused) }
+func (s *lifecycleService) Resume() error { return s.move(domain.ServicePaused, domain.ServiceRunning) }
+func (s *lifecycleService) Status() domain.ServiceStatus {
+	return domain.ServiceStatus{State: s.state}
+}
+
+func TestServer_ServiceActions(t *testing.T) {
+	handler := NewServer(&lifecycleService{state: domain.ServiceRunning}, nopLogger{}, nil).Handler()
+	do := func(method, path string) (int, string) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		return recorder.Code, recorder.Body.String()
+	}
+
+	code, body := do(http.MethodPost, "/service/pause")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"State":"paused"`)
+
+	code, body = do(http.MethodPost, "/service/stop")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, "Service is paused\n", body)
+
+	code, _ = do(http.MethodPost, "/service/restart")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = do(http.MethodGet, "/service/resume")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code, _ = do(http.MethodPost, "/service/resume")
+	assert.Equal(t, http.StatusOK, code)
+	code, body = do(http.MethodGet, "/service")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"State":"running"`)
+}
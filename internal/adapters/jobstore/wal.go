@@ -0,0 +1,341 @@
+package jobstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"file-mod-tracker/internal/core/domain"
+)
+
+const (
+	// FileName is the log file kept in the store directory.
+	FileName = "jobs.wal"
+
+	walMagic         = "FMTJOB"
+	walVersion       = 1
+	headerSize       = 8
+	recordHeaderSize = 8
+	maxRecordSize    = 16 << 20
+	// compactSlack is how many superseded records the log may hold beyond
+	// twice the number of live jobs before it is rewritten.
+	compactSlack = 1000
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errCorruptRecord = errors.New("corrupt record")
+
+// CorruptError is returned by Open when a record before the end of the log
+// is damaged. Cutting the log off there would lose every job recorded after
+// it, so the file is left untouched for the operator to inspect.
+type CorruptError struct {
+	Path string
+	// Offset is where the damaged record starts.
+	Offset int64
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("%s: corrupt record at byte %d; move the file aside to start with an empty job store", e.Path, e.Offset)
+}
+
+// record is one log entry: the new state of a job, or jobs to forget.
+type record struct {
+	Job    *domain.Job `json:",omitempty"`
+	Forget []string    `json:",omitempty"`
+}
+
+// WAL is a job store backed by a write-ahead log. Every Save and Forget is
+// appended as a checksummed record and synced before it returns. Opening the
+// store replays the log, cuts off a torn write at its tail and rewrites it
+// with one record per remaining job. Damage anywhere else fails Open with a
+// *CorruptError. Only the owner may read the log; it holds the environment of
+// each job but, like every encoding of a job, not its standard input.
+type WAL struct {
+	mu      sync.Mutex
+	dir     string
+	file    *os.File
+	size    int64
+	records int
+	jobs    map[string]domain.Job
+	order   []string
+	closed  bool
+}
+
+// Open opens or creates the store in dir.
+func Open(dir string) (*WAL, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	w := &WAL{dir: dir, jobs: make(map[string]domain.Job)}
+	if err := w.replay(); err != nil {
+		return nil, err
+	}
+	if err := w.compact(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *WAL) path() string {
+	return filepath.Join(w.dir, FileName)
+}
+
+// replay applies every record of the log. A damaged last record is a write
+// torn by a crash: reading stops there and compact drops it. A damaged record
+// followed by more data is corruption.
+func (w *WAL) replay() error {
+	f, err := os.Open(w.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < headerSize {
+		return nil
+	}
+	if err := checkHeader(f, w.path()); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(io.NewSectionReader(f, headerSize, info.Size()-headerSize))
+	for offset := int64(headerSize); ; {
+		payload, n, err := readRecord(reader)
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			// The last record was cut short by a crash
+			return nil
+		case errors.Is(err, errCorruptRecord) && offset+n >= info.Size():
+			// The last record was written in full but not flushed
+			return nil
+		case err != nil:
+			return &CorruptError{Path: w.path(), Offset: offset}
+		}
+		var rec record
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return &CorruptError{Path: w.path(), Offset: offset}
+		}
+		w.apply(rec)
+		offset += n
+	}
+}
+
+func (w *WAL) apply(rec record) {
+	if rec.Job != nil {
+		if _, ok := w.jobs[rec.Job.ID]; !ok {
+			w.order = append(w.order, rec.Job.ID)
+		}
+		w.jobs[rec.Job.ID] = *rec.Job
+	}
+	for _, id := range rec.Forget {
+		if _, ok := w.jobs[id]; !ok {
+			continue
+		}
+		delete(w.jobs, id)
+		for i, other := range w.order {
+			if other == id {
+				w.order = append(w.order[:i], w.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// compact writes the live jobs to a new log and swaps it in atomically.
+func (w *WAL) compact() error {
+	var data []byte
+	for _, id := range w.order {
+		job := w.jobs[id]
+		payload, err := json.Marshal(record{Job: &job})
+		if err != nil {
+			return err
+		}
+		data = appendRecord(data, payload)
+	}
+
+	tmp := w.path() + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	// Commands and their environment may hold secrets. Chmod also covers a
+	// temporary file left behind with other permissions.
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := writeHeader(f); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteAt(data, headerSize); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+	if err := os.Rename(tmp, w.path()); err != nil {
+		return err
+	}
+	syncDir(w.dir)
+
+	file, err := os.OpenFile(w.path(), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.file = file
+	w.size = headerSize + int64(len(data))
+	w.records = len(w.order)
+	return nil
+}
+
+func (w *WAL) Jobs() []domain.Job {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	jobs := make([]domain.Job, 0, len(w.order))
+	for _, id := range w.order {
+		jobs = append(jobs, w.jobs[id])
+	}
+	return jobs
+}
+
+func (w *WAL) Save(jobs ...domain.Job) error {
+	recs := make([]record, len(jobs))
+	for i := range jobs {
+		recs[i] = record{Job: &jobs[i]}
+	}
+	return w.append(recs)
+}
+
+func (w *WAL) Forget(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return w.append([]record{{Forget: ids}})
+}
+
+func (w *WAL) append(recs []record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errors.New("job store is closed")
+	}
+
+	var data []byte
+	for _, rec := range recs {
+		payload, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		data = appendRecord(data, payload)
+	}
+	if _, err := w.file.Write(data); err != nil {
+		w.file.Truncate(w.size)
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.size += int64(len(data))
+	w.records += len(recs)
+	for _, rec := range recs {
+		w.apply(rec)
+	}
+	if w.records > 2*len(w.jobs)+compactSlack {
+		return w.compact()
+	}
+	return nil
+}
+
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.file.Close()
+}
+
+func writeHeader(f *os.File) error {
+	header := make([]byte, headerSize)
+	copy(header, walMagic)
+	header[len(walMagic)] = walVersion
+	_, err := f.WriteAt(header, 0)
+	return err
+}
+
+func checkHeader(f *os.File, path string) error {
+	header := make([]byte, headerSize)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return err
+	}
+	if string(header[:len(walMagic)]) != walMagic {
+		return fmt.Errorf("%s: not a job log", path)
+	}
+	if version := header[len(walMagic)]; version != walVersion {
+		return fmt.Errorf("%s: unsupported format version %d", path, version)
+	}
+	return nil
+}
+
+func appendRecord(buf, payload []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(payload, crcTable))
+	return append(buf, payload...)
+}
+
+// readRecord reads the next record. n is the size the record claims to
+// take up in the log, header included, even when it cannot be read; just the
+// header when its length is out of range.
+func readRecord(r io.Reader) (payload []byte, n int64, err error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, recordHeaderSize, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length > maxRecordSize {
+		// The length cannot be trusted, so neither can the record's end
+		return nil, recordHeaderSize, errCorruptRecord
+	}
+	n = recordHeaderSize + int64(length)
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, n, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, n, errCorruptRecord
+	}
+	return payload, n, nil
+}
+
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	// Some platforms do not support syncing directories.
+	d.Sync()
+}
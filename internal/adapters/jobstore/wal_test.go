@@ -0,0 +1,170 @@
+package jobstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newJob(i int, state domain.JobState) domain.Job {
+	return domain.Job{
+		ID:         fmt.Sprintf("job%d", i),
+		Command:    fmt.Sprintf("echo %d", i),
+		State:      state,
+		EnqueuedAt: time.Date(2024, 9, 23, 12, 0, i, 0, time.UTC),
+	}
+}
+
+func TestWAL_SurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(newJob(1, domain.JobQueued), newJob(2, domain.JobQueued), newJob(3, domain.JobQueued)))
+	require.NoError(t, store.Save(newJob(1, domain.JobRunning)))
+	require.NoError(t, store.Forget("job2"))
+	require.NoError(t, store.Close())
+
+	store, err = Open(dir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	jobs := store.Jobs()
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "job1", jobs[0].ID)
+	assert.Equal(t, domain.JobRunning, jobs[0].State)
+	assert.Equal(t, "job3", jobs[1].ID)
+	assert.Equal(t, time.Date(2024, 9, 23, 12, 0, 3, 0, time.UTC), jobs[1].EnqueuedAt.UTC())
+}
+
+func TestWAL_TruncatesTornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(newJob(1, domain.JobQueued)))
+	require.NoError(t, store.Close())
+
+	// Simulate a crash halfway through writing a second record.
+	f, err := os.OpenFile(filepath.Join(dir, FileName), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 50, 1, 2, 3, 4, '{', '"'})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	store, err = Open(dir)
+	require.NoError(t, err)
+	require.Len(t, store.Jobs(), 1)
+	require.NoError(t, store.Save(newJob(2, domain.JobQueued)))
+	require.NoError(t, store.Close())
+
+	store, err = Open(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Len(t, store.Jobs(), 2)
+}
+
+func TestWAL_TruncatesRecordTornAtTail(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(newJob(1, domain.JobQueued), newJob(2, domain.JobQueued)))
+	require.NoError(t, store.Close())
+
+	// The last record is complete in length but its data never reached the
+	// disk.
+	path := filepath.Join(dir, FileName)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-2] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	store, err = Open(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	jobs := store.Jobs()
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "job1", jobs[0].ID)
+}
+
+func TestWAL_RefusesCorruptionBeforeTail(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(newJob(1, domain.JobQueued), newJob(2, domain.JobQueued), newJob(3, domain.JobQueued)))
+	require.NoError(t, store.Close())
+
+	// Flip a byte inside the second record's payload.
+	path := filepath.Join(dir, FileName)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	first := recordHeaderSize + int(binary.BigEndian.Uint32(data[headerSize:]))
+	data[headerSize+first+recordHeaderSize+2] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	_, err = Open(dir)
+	var corrupt *CorruptError
+	require.ErrorAs(t, err, &corrupt)
+	assert.Equal(t, int64(headerSize+first), corrupt.Offset)
+
+	// The log is left as it was, with the records after the damage.
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, data, after)
+}
+
+func TestWAL_RefusesCorruptLengthBeforeTail(t *testing.T) {
+	for name, length := range map[string]uint32{
+		"out of range": maxRecordSize + 1,
+		"too long":     0,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := Open(dir)
+			require.NoError(t, err)
+			require.NoError(t, store.Save(newJob(1, domain.JobQueued), newJob(2, domain.JobQueued), newJob(3, domain.JobQueued)))
+			require.NoError(t, store.Close())
+
+			path := filepath.Join(dir, FileName)
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			first := recordHeaderSize + int(binary.BigEndian.Uint32(data[headerSize:]))
+			second := data[headerSize+first:]
+			if length == 0 {
+				// Still within the log, so the checksum has to catch it
+				length = binary.BigEndian.Uint32(second) + 4
+			}
+			binary.BigEndian.PutUint32(second, length)
+			require.NoError(t, os.WriteFile(path, data, 0o644))
+
+			_, err = Open(dir)
+			var corrupt *CorruptError
+			require.ErrorAs(t, err, &corrupt)
+			assert.Equal(t, int64(headerSize+first), corrupt.Offset)
+		})
+	}
+}
+
+func TestWAL_CompactsSupersededRecords(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := Open(dir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	for i := 0; i < 2*compactSlack; i++ {
+		require.NoError(t, store.Save(newJob(1, domain.JobQueued)))
+	}
+	assert.LessOrEqual(t, store.records, 2+compactSlack, "the log was rewritten")
+	assert.Len(t, store.Jobs(), 1)
+}
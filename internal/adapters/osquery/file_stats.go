@@ -1,35 +1,151 @@
 package osquery
 
 import (
+	"errors"
 	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/pkg/ignore"
 	"file-mod-tracker/pkg/logger"
+	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"syscall"
 	"time"
 )
 
+const defaultQueryTimeout = 30 * time.Second
+
 type OsqueryAdapter struct {
-	logger logger.Logger
+	logger  logger.Logger
+	binary  string
+	socket  string
+	timeout time.Duration
+}
+
+// Option customises an OsqueryAdapter at construction time.
+type Option func(*OsqueryAdapter)
+
+// WithBinary queries the file and hash tables through the given osqueryi
+// executable. Without it the adapter only walks the filesystem.
+func WithBinary(binary string) Option {
+	return func(a *OsqueryAdapter) {
+		a.binary = binary
+	}
+}
+
+// WithSocket makes osqueryi connect to a running osqueryd through its
+// extension socket instead of starting an embedded instance.
+func WithSocket(socket string) Option {
+	return func(a *OsqueryAdapter) {
+		a.socket = socket
+	}
 }
 
-func NewAdapter(logger logger.Logger) *OsqueryAdapter {
-	return &OsqueryAdapter{logger: logger}
+// WithTimeout bounds how long a single osqueryi invocation may run.
+func WithTimeout(timeout time.Duration) Option {
+	return func(a *OsqueryAdapter) {
+		a.timeout = timeout
+	}
 }
 
+func NewAdapter(logger logger.Logger, opts ...Option) *OsqueryAdapter {
+	a := &OsqueryAdapter{logger: logger, timeout: defaultQueryTimeout}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.binary != "" {
+		resolved, err := exec.LookPath(a.binary)
+		if err != nil {
+			logger.Error("osqueryi not found, falling back to filesystem walk", "binary", a.binary, "error", err)
+			a.binary = ""
+		} else {
+			a.binary = resolved
+		}
+	}
+	return a
+}
+
+// GetFileStats lists the files below directory, or the file itself when
+// directory names a file. Paths that cannot be read are skipped.
 func (a *OsqueryAdapter) GetFileStats(directory string) ([]domain.FileInfo, error) {
+	result, err := a.ScanFiles(directory, domain.ScanOptions{})
+	return result.Files, err
+}
+
+// ScanFiles lists the files below directory that opts and the .fmtignore
+// files in the tree allow. It asks osquery when a binary is configured and
+// walks the filesystem when osquery is unavailable or fails. Only a failure
+// to read directory itself is returned as an error; paths below it that
+// cannot be read are listed in the report. osquery fills in sha256 only when
+// opts asks for it.
+func (a *OsqueryAdapter) ScanFiles(directory string, opts domain.ScanOptions) (domain.ScanResult, error) {
+	result := domain.ScanResult{Report: domain.ScanReport{Root: directory, StartedAt: time.Now()}}
+	filter := ignore.NewFilter(directory, ignore.Options{MaxDepth: opts.MaxDepth, Include: opts.Include, Exclude: opts.Exclude})
+
+	fileInfos, err := a.listFiles(directory, opts, filter, &result)
+	if err != nil {
+		return domain.ScanResult{}, err
+	}
+
+	for _, ignoreErr := range filter.Errors() {
+		result.AddError(newScanError("", ignoreErr))
+	}
+	result.Files = fileInfos
+	result.Report.FilesScanned = len(fileInfos)
+	result.Report.DurationMillis = time.Since(result.Report.StartedAt).Milliseconds()
+	if result.Report.Partial() {
+		a.logger.Error("Scan skipped unreadable paths", "directory", directory, "errors", result.Report.ErrorCount)
+	}
+	return result, nil
+}
+
+func (a *OsqueryAdapter) listFiles(directory string, opts domain.ScanOptions, filter *ignore.Filter, result *domain.ScanResult) ([]domain.FileInfo, error) {
+	if a.binary != "" {
+		fileInfos, err := a.queryFileStats(directory, slices.Contains(opts.HashAlgorithms, domain.HashSHA256))
+		if err == nil {
+			return filterFileInfos(directory, fileInfos, filter), nil
+		}
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		a.logger.Error("osquery query failed, falling back to filesystem walk", "directory", directory, "error", err)
+	}
+	return a.walkFileStats(directory, filter, result)
+}
+
+// walkFileStats walks directory and prunes the subtrees filter excludes.
+// Entries that cannot be read are recorded in result and skipped.
+func (a *OsqueryAdapter) walkFileStats(directory string, filter *ignore.Filter, result *domain.ScanResult) ([]domain.FileInfo, error) {
 	var fileInfos []domain.FileInfo
 
-	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return err
+			if path == directory {
+				return err
+			}
+			result.AddError(newScanError(path, err))
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel := relativePath(directory, path)
+		if d.IsDir() {
+			if rel != "." && filter.SkipDir(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel != "." && !filter.IncludeFile(rel) {
+			return nil
 		}
-		if !info.IsDir() {
-			fileInfos = append(fileInfos, domain.FileInfo{
-				Path:         path,
-				LastModified: info.ModTime().Format(time.RFC3339),
-				Size:         info.Size(),
-			})
+		info, err := d.Info()
+		if err != nil {
+			result.AddError(newScanError(path, err))
+			return nil
 		}
+		fileInfos = append(fileInfos, newFileInfo(path, info))
 		return nil
 	})
 
@@ -40,3 +156,43 @@ func (a *OsqueryAdapter) GetFileStats(directory string) ([]domain.FileInfo, erro
 
 	return fileInfos, nil
 }
+
+// newScanError classifies a failure to read path. When path is empty it is
+// taken from err.
+func newScanError(path string, err error) domain.ScanError {
+	var pathErr *fs.PathError
+	if path == "" && errors.As(err, &pathErr) {
+		path = pathErr.Path
+	}
+
+	kind := domain.ScanErrorOther
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		kind = domain.ScanErrorPermission
+	case errors.Is(err, fs.ErrNotExist):
+		kind = domain.ScanErrorVanished
+	case errors.Is(err, syscall.ENAMETOOLONG):
+		kind = domain.ScanErrorNameTooLong
+	}
+	return domain.ScanError{Path: path, Kind: kind, Message: err.Error()}
+}
+
+// filterFileInfos applies filter to results that were not produced by a
+// pruned walk.
+func filterFileInfos(directory string, fileInfos []domain.FileInfo, filter *ignore.Filter) []domain.FileInfo {
+	filtered := fileInfos[:0]
+	for _, fileInfo := range fileInfos {
+		if rel := relativePath(directory, fileInfo.Path); rel == "." || filter.Allows(rel) {
+			filtered = append(filtered, fileInfo)
+		}
+	}
+	return filtered
+}
+
+func relativePath(directory, path string) string {
+	rel, err := filepath.Rel(directory, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
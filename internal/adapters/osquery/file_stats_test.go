@@ -0,0 +1,253 @@
+package osquery
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (nopLogger) Error(msg string, keysAndValues ...interface{}) {}
+func (nopLogger) Fatal(msg string, keysAndValues ...interface{}) {}
+
+// writeFakeOsqueryi installs a shell script that records its arguments and
+// prints output in place of osqueryi.
+func writeFakeOsqueryi(t *testing.T, output string, exitCode int) (binary, argsFile string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake osqueryi is a shell script")
+	}
+	dir := t.TempDir()
+	binary = filepath.Join(dir, "osqueryi")
+	argsFile = filepath.Join(dir, "args")
+	script := "#!/bin/sh\n" +
+		"printf '%s\\n' \"$@\" > '" + argsFile + "'\n" +
+		"cat <<'EOF'\n" + output + "\nEOF\n" +
+		"exit " + string(rune('0'+exitCode)) + "\n"
+	require.NoError(t, os.WriteFile(binary, []byte(script), 0o755))
+	return binary, argsFile
+}
+
+// writeRecordedOsqueryi installs a shell script in place of osqueryi that
+// answers each query in recorded, compared exactly, with its recorded output
+// and fails on any other query, as osqueryi answers queries it cannot run.
+func writeRecordedOsqueryi(t *testing.T, recorded map[string]string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake osqueryi is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nfor query; do :; done\n"
+	i := 0
+	for query, output := range recorded {
+		i++
+		queryFile := filepath.Join(dir, fmt.Sprintf("query%d", i))
+		outputFile := filepath.Join(dir, fmt.Sprintf("output%d", i))
+		require.NoError(t, os.WriteFile(queryFile, []byte(query), 0o644))
+		require.NoError(t, os.WriteFile(outputFile, []byte(output), 0o644))
+		script += fmt.Sprintf("if [ \"$query\" = \"$(cat '%s')\" ]; then cat '%s'; exit 0; fi\n", queryFile, outputFile)
+	}
+	script += "echo \"Error: unexpected query: $query\" >&2\nexit 1\n"
+	binary := filepath.Join(dir, "osqueryi")
+	require.NoError(t, os.WriteFile(binary, []byte(script), 0o755))
+	return binary
+}
+
+func TestGetFileStats_QueriesOsquery(t *testing.T) {
+	// A directory whose name osquery would read as a glob
+	dir := filepath.Join(t.TempDir(), "it's_100%")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	sibling := strings.TrimSuffix(dir, "%") + "0"
+
+	// Rows as osqueryi 5.x prints them for the file table
+	binary := writeRecordedOsqueryi(t, map[string]string{
+		"SELECT f.path, f.size, f.mtime, f.ctime, f.atime, f.mode, f.uid, f.gid, f.inode, f.device, f.hard_links, f.type " +
+			"FROM file AS f WHERE f.path LIKE '" + strings.ReplaceAll(dir, "'", "''") + "/%%' AND f.type != 'directory';": `[
+  {"atime":"1727092900","ctime":"1727092805","device":"66306","gid":"0","hard_links":"2","inode":"1234","mode":"4755","mtime":"1727092800","path":"` + dir + `/a.txt","size":"12","type":"regular","uid":"0"},
+  {"atime":"","ctime":"","device":"","gid":"","hard_links":"","inode":"","mode":"","mtime":"1727092801","path":"` + dir + `/sub/link","size":"5","type":"symlink","uid":""},
+  {"atime":"1727092900","ctime":"1727092805","device":"66306","gid":"0","hard_links":"1","inode":"99","mode":"0644","mtime":"1727092800","path":"` + sibling + `/b.txt","size":"1","type":"regular","uid":"0"}
+]`,
+	})
+
+	adapter := NewAdapter(nopLogger{}, WithBinary(binary))
+	stats, err := adapter.GetFileStats(dir)
+	require.NoError(t, err)
+
+	require.Len(t, stats, 2, "rows outside the directory are dropped")
+	assert.Equal(t, dir+"/a.txt", stats[0].Path)
+	assert.Equal(t, int64(12), stats[0].Size)
+	assert.Equal(t, time.Unix(1727092800, 0), stats[0].LastModified)
+	assert.Nil(t, stats[0].Hashes)
+	assert.Equal(t, "4755", stats[0].Mode)
+	assert.Equal(t, uint32(0), stats[0].UID)
+	assert.Equal(t, uint64(1234), stats[0].Inode)
+	assert.Equal(t, uint64(2), stats[0].LinkCount)
+	assert.Equal(t, time.Unix(1727092805, 0), stats[0].ChangeTime)
+	assert.Equal(t, dir+"/sub/link", stats[1].Path)
+}
+
+func TestScanFiles_JoinsHashOnlyForSHA256(t *testing.T) {
+	dir := t.TempDir()
+	binary := writeRecordedOsqueryi(t, map[string]string{
+		"SELECT f.path, f.size, f.mtime, f.ctime, f.atime, f.mode, f.uid, f.gid, f.inode, f.device, f.hard_links, f.type, h.sha256 " +
+			"FROM file AS f LEFT JOIN hash AS h ON h.path = f.path WHERE f.path LIKE '" + dir + "/%%' AND f.type != 'directory';": `[
+  {"atime":"1727092900","ctime":"1727092805","device":"66306","gid":"0","hard_links":"1","inode":"1234","mode":"0644","mtime":"1727092800","path":"` + dir + `/a.txt","sha256":"abc123","size":"12","type":"regular","uid":"0"}
+]`,
+	})
+
+	adapter := NewAdapter(nopLogger{}, WithBinary(binary))
+	result, err := adapter.ScanFiles(dir, domain.ScanOptions{HashAlgorithms: []string{domain.HashXXHash, domain.HashSHA256}})
+	require.NoError(t, err)
+	require.Len(t, result.Files, 1)
+	assert.Equal(t, map[string]string{domain.HashSHA256: "abc123"}, result.Files[0].Hashes)
+}
+
+func TestQuery_PassesSocket(t *testing.T) {
+	dir := t.TempDir()
+	binary, argsFile := writeFakeOsqueryi(t, `[]`, 0)
+
+	adapter := NewAdapter(nopLogger{}, WithBinary(binary), WithSocket("/var/osquery/osquery.em"))
+	_, err := adapter.GetFileStats(dir)
+	require.NoError(t, err)
+
+	args, err := os.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "--json\n--connect\n/var/osquery/osquery.em\n")
+}
+
+func TestGetFileStats_FallsBackToWalk(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0o644))
+	binary, _ := writeFakeOsqueryi(t, "Error: no such table: hash", 1)
+
+	adapter := NewAdapter(nopLogger{}, WithBinary(binary))
+	stats, err := adapter.GetFileStats(dir)
+	require.NoError(t, err)
+
+	require.Len(t, stats, 1)
+	assert.Equal(t, filepath.Join(dir, "a.txt"), stats[0].Path)
+	assert.Equal(t, int64(5), stats[0].Size)
+}
+
+func TestGetFileStats_WalkReportsMetadata(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits and symlinks differ on Windows")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "secret.conf")
+	require.NoError(t, os.WriteFile(target, []byte("key=value"), 0o600))
+	require.NoError(t, os.Chmod(target, 0o640))
+	require.NoError(t, os.Symlink(target, filepath.Join(dir, "current.conf")))
+
+	adapter := NewAdapter(nopLogger{})
+	stats, err := adapter.GetFileStats(dir)
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+
+	link, file := stats[0], stats[1]
+	assert.Equal(t, target, link.SymlinkTarget)
+	assert.Equal(t, "0640", file.Mode)
+	assert.Equal(t, uint32(os.Getuid()), file.UID)
+	assert.Equal(t, uint32(os.Getgid()), file.GID)
+	assert.NotZero(t, file.Inode)
+	assert.Equal(t, uint64(1), file.LinkCount)
+	assert.False(t, file.ChangeTime.IsZero())
+}
+
+func TestScanFiles_PrunesIgnoredTrees(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":                      "package main",
+		"notes.swp":                    "swap",
+		"node_modules/dep/index.js":    "dep",
+		"web/.fmtignore":               "dist/\n!keep.log\n",
+		"web/dist/app.js":              "bundle",
+		"web/debug.log":                "log",
+		"web/keep.log":                 "log",
+		"web/src/app.ts":               "src",
+		"vendor/lib/.fmtignore":        "*.ts\n",
+		"vendor/lib/generated/code.ts": "generated",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	adapter := NewAdapter(nopLogger{})
+	result, err := adapter.ScanFiles(dir, domain.ScanOptions{Exclude: []string{"node_modules/", "*.swp", "*.log"}})
+	require.NoError(t, err)
+
+	var paths []string
+	for _, stat := range result.Files {
+		paths = append(paths, filepath.ToSlash(strings.TrimPrefix(stat.Path, dir+string(filepath.Separator))))
+	}
+	assert.ElementsMatch(t, []string{
+		"main.go",
+		"web/.fmtignore",
+		"web/keep.log",
+		"web/src/app.ts",
+		"vendor/lib/.fmtignore",
+	}, paths)
+}
+
+func TestScanFiles_SkipsUnreadableDirectories(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("needs POSIX permissions enforced for the current user")
+	}
+	dir := t.TempDir()
+	private := filepath.Join(dir, "private")
+	require.NoError(t, os.Mkdir(private, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(private, "secret"), []byte("data"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "public"), []byte("data"), 0o644))
+	require.NoError(t, os.Chmod(private, 0))
+	defer os.Chmod(private, 0o755)
+
+	result, err := NewAdapter(nopLogger{}).ScanFiles(dir, domain.ScanOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, result.Files, 1)
+	assert.Equal(t, filepath.Join(dir, "public"), result.Files[0].Path)
+	assert.Equal(t, 1, result.Report.FilesScanned)
+	assert.Equal(t, 1, result.Report.ErrorCount)
+	assert.Equal(t, private, result.Report.Errors[0].Path)
+	assert.Equal(t, domain.ScanErrorPermission, result.Report.Errors[0].Kind)
+}
+
+func TestScanFiles_FailsOnMissingRoot(t *testing.T) {
+	_, err := NewAdapter(nopLogger{}).ScanFiles(filepath.Join(t.TempDir(), "missing"), domain.ScanOptions{})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestNewScanError_ClassifiesErrors(t *testing.T) {
+	cases := map[error]string{
+		&fs.PathError{Op: "open", Path: "/a", Err: syscall.EACCES}:       domain.ScanErrorPermission,
+		&fs.PathError{Op: "lstat", Path: "/a", Err: syscall.ENOENT}:      domain.ScanErrorVanished,
+		&fs.PathError{Op: "open", Path: "/a", Err: syscall.ENAMETOOLONG}: domain.ScanErrorNameTooLong,
+		&fs.PathError{Op: "open", Path: "/a", Err: syscall.EIO}:          domain.ScanErrorOther,
+	}
+	for err, kind := range cases {
+		scanErr := newScanError("", err)
+		assert.Equal(t, "/a", scanErr.Path)
+		assert.Equal(t, kind, scanErr.Kind, err.Error())
+	}
+}
+
+func TestBuildFileQuery_SelectsFileOrTree(t *testing.T) {
+	assert.Equal(t, "SELECT f.path, f.size, f.mtime, f.ctime, f.atime, f.mode, f.uid, f.gid, f.inode, f.device, f.hard_links, f.type "+
+		"FROM file AS f WHERE f.path = '/srv/it''s.txt' AND f.type != 'directory';", buildFileQuery("/srv/it's.txt", false, false))
+	assert.NotContains(t, buildFileQuery("/srv", true, false), "ESCAPE")
+}
@@ -0,0 +1,58 @@
+package osquery
+
+import (
+	"fmt"
+	"os"
+
+	"file-mod-tracker/internal/adapters/fsstat"
+	"file-mod-tracker/internal/core/domain"
+)
+
+// newFileInfo converts the result of os.Lstat into the domain model.
+func newFileInfo(path string, info os.FileInfo) domain.FileInfo {
+	fileInfo := domain.FileInfo{
+		Path:         path,
+		LastModified: info.ModTime(),
+		Size:         info.Size(),
+		Mode:         formatMode(info.Mode()),
+	}
+	if stat, ok := fsstat.FromFileInfo(info); ok {
+		fileInfo.UID = stat.UID
+		fileInfo.GID = stat.GID
+		fileInfo.Owner = fsstat.UserName(stat.UID)
+		fileInfo.Group = fsstat.GroupName(stat.GID)
+		fileInfo.Inode = stat.Inode
+		fileInfo.Device = stat.Device
+		fileInfo.LinkCount = stat.LinkCount
+		fileInfo.ChangeTime = stat.ChangeTime
+		fileInfo.AccessTime = stat.AccessTime
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		fileInfo.SymlinkTarget = readlink(path)
+	}
+	return fileInfo
+}
+
+// formatMode renders the permission bits the way chmod takes them, with the
+// setuid, setgid and sticky bits in the leading digit.
+func formatMode(mode os.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+func readlink(path string) string {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return ""
+	}
+	return target
+}
@@ -0,0 +1,183 @@
+package osquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"file-mod-tracker/internal/adapters/fsstat"
+	"file-mod-tracker/internal/core/domain"
+)
+
+// fileRow is one row of the file table, joined with the hash table when
+// sha256 is asked for. osqueryi renders every column as a string in its JSON
+// output.
+type fileRow struct {
+	Path      string `json:"path"`
+	Size      string `json:"size"`
+	Mtime     string `json:"mtime"`
+	Ctime     string `json:"ctime"`
+	Atime     string `json:"atime"`
+	Mode      string `json:"mode"`
+	UID       string `json:"uid"`
+	GID       string `json:"gid"`
+	Inode     string `json:"inode"`
+	Device    string `json:"device"`
+	HardLinks string `json:"hard_links"`
+	Type      string `json:"type"`
+	SHA256    string `json:"sha256"`
+}
+
+func (a *OsqueryAdapter) queryFileStats(target string, sha256 bool) ([]domain.FileInfo, error) {
+	info, err := os.Lstat(target)
+	if err != nil {
+		return nil, err
+	}
+
+	target = filepath.Clean(target)
+	rows, err := a.query(buildFileQuery(target, info.IsDir(), sha256))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.TrimSuffix(target, string(filepath.Separator)) + string(filepath.Separator)
+	fileInfos := make([]domain.FileInfo, 0, len(rows))
+	for _, row := range rows {
+		// osquery turns the LIKE pattern into a glob, so wildcards in the
+		// directory name may match more than the tree.
+		if row.Path != target && !strings.HasPrefix(row.Path, prefix) {
+			continue
+		}
+		fileInfo, err := row.toFileInfo()
+		if err != nil {
+			return nil, err
+		}
+		fileInfos = append(fileInfos, fileInfo)
+	}
+	return fileInfos, nil
+}
+
+// buildFileQuery selects every non-directory entry at or below target, with
+// its sha256 digest when sha256 is set. In osquery a LIKE pattern ending in
+// %% matches recursively. The pattern is not escaped: osquery only sees a
+// plain two-argument LIKE, and treats % as a glob wildcard whatever the
+// escaping, so queryFileStats drops the rows outside target instead.
+func buildFileQuery(target string, isDir, sha256 bool) string {
+	constraint := "f.path = " + quote(target)
+	if isDir {
+		prefix := strings.TrimSuffix(target, string(filepath.Separator)) + string(filepath.Separator)
+		constraint = "f.path LIKE " + quote(prefix+"%%")
+	}
+	columns := "f.path, f.size, f.mtime, f.ctime, f.atime, f.mode, f.uid, f.gid, f.inode, f.device, f.hard_links, f.type"
+	from := "file AS f"
+	if sha256 {
+		columns += ", h.sha256"
+		from += " LEFT JOIN hash AS h ON h.path = f.path"
+	}
+	return "SELECT " + columns + " FROM " + from + " WHERE " + constraint + " AND f.type != 'directory';"
+}
+
+func (a *OsqueryAdapter) query(sql string) ([]fileRow, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+
+	args := []string{"--json"}
+	if a.socket != "" {
+		args = append(args, "--connect", a.socket)
+	}
+	args = append(args, sql)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, a.binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("osqueryi: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var rows []fileRow
+	if err := json.Unmarshal(stdout.Bytes(), &rows); err != nil {
+		return nil, fmt.Errorf("osqueryi: decoding output: %w", err)
+	}
+	return rows, nil
+}
+
+func (r fileRow) toFileInfo() (domain.FileInfo, error) {
+	p := rowParser{row: r}
+	size := p.int("size", r.Size)
+	mtime := p.int("mtime", r.Mtime)
+	ctime := p.int("ctime", r.Ctime)
+	atime := p.int("atime", r.Atime)
+	mode := p.uint("mode", r.Mode, 8, 32)
+	uid := p.uint("uid", r.UID, 10, 32)
+	gid := p.uint("gid", r.GID, 10, 32)
+	inode := p.uint("inode", r.Inode, 10, 64)
+	device := p.uint("device", r.Device, 10, 64)
+	hardLinks := p.uint("hard_links", r.HardLinks, 10, 64)
+	if p.err != nil {
+		return domain.FileInfo{}, p.err
+	}
+
+	fileInfo := domain.FileInfo{
+		Path:         r.Path,
+		LastModified: time.Unix(mtime, 0),
+		Size:         size,
+		Mode:         fmt.Sprintf("%04o", mode&0o7777),
+		UID:          uint32(uid),
+		GID:          uint32(gid),
+		Owner:        fsstat.UserName(uint32(uid)),
+		Group:        fsstat.GroupName(uint32(gid)),
+		Inode:        inode,
+		Device:       device,
+		LinkCount:    hardLinks,
+		ChangeTime:   time.Unix(ctime, 0),
+		AccessTime:   time.Unix(atime, 0),
+	}
+	if r.Type == "symlink" {
+		fileInfo.SymlinkTarget = readlink(r.Path)
+	}
+	if r.SHA256 != "" {
+		fileInfo.Hashes = map[string]string{domain.HashSHA256: r.SHA256}
+	}
+	return fileInfo, nil
+}
+
+// rowParser converts the string columns of a row and keeps the first error.
+// Empty columns parse as zero since osquery leaves unsupported ones blank.
+type rowParser struct {
+	row fileRow
+	err error
+}
+
+func (p *rowParser) int(column, value string) int64 {
+	if p.err != nil || value == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		p.err = fmt.Errorf("osqueryi: %s: invalid %s %q", p.row.Path, column, value)
+	}
+	return n
+}
+
+func (p *rowParser) uint(column, value string, base, bitSize int) uint64 {
+	if p.err != nil || value == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(value, base, bitSize)
+	if err != nil {
+		p.err = fmt.Errorf("osqueryi: %s: invalid %s %q", p.row.Path, column, value)
+	}
+	return n
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,447 @@
+// Package reporter delivers detected change events to a remote HTTP
+// endpoint in signed batches.
+package reporter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports/api"
+	"file-mod-tracker/pkg/logger"
+)
+
+const (
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = 5 * time.Second
+	DefaultMinBackoff    = time.Second
+	DefaultMaxBackoff    = 5 * time.Minute
+	DefaultSpoolMaxBytes = 64 << 20
+
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+	// timestamp, a dot and the request body.
+	SignatureHeader = "X-Signature"
+	// TimestampHeader carries the Unix time the request was signed at.
+	TimestampHeader = "X-Signature-Timestamp"
+
+	requestTimeout = 30 * time.Second
+)
+
+// Batch is the body POSTed to the endpoint. ID stays the same when a batch
+// is retried, so receivers can discard duplicates. Events are encoded like
+// those of /api/v1/logs.
+type Batch struct {
+	ID     string            `json:"id"`
+	Events []api.ChangeEvent `json:"events"`
+}
+
+// Sign returns the signature header value for a request body signed at
+// timestamp.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// errRejected marks responses that retrying cannot fix.
+var errRejected = errors.New("rejected by endpoint")
+
+// Reporter batches change events and POSTs them to an endpoint. Batches the
+// endpoint does not accept are spooled and retried with exponential backoff,
+// oldest first, before any newer events are sent.
+type Reporter struct {
+	endpoint      string
+	secret        []byte
+	client        *http.Client
+	logger        logger.Logger
+	batchSize     int
+	flushInterval time.Duration
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	spoolDir      string
+	spoolMaxBytes int64
+
+	mu      sync.Mutex
+	started bool
+	pending []domain.ChangeEvent
+	spool   *spool
+	backoff time.Duration
+	status  domain.ReporterStatus
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Option customises a Reporter at construction time.
+type Option func(*Reporter)
+
+// WithSecret signs every request with secret. Without it requests are sent
+// unsigned.
+func WithSecret(secret []byte) Option {
+	return func(r *Reporter) {
+		r.secret = secret
+	}
+}
+
+// WithBatchSize caps the number of events per request.
+func WithBatchSize(size int) Option {
+	return func(r *Reporter) {
+		r.batchSize = size
+	}
+}
+
+// WithFlushInterval sets how long events may wait for a batch to fill up.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(r *Reporter) {
+		r.flushInterval = interval
+	}
+}
+
+// WithBackoff sets the first and the longest delay between failed attempts.
+func WithBackoff(min, max time.Duration) Option {
+	return func(r *Reporter) {
+		r.minBackoff = min
+		r.maxBackoff = max
+	}
+}
+
+// WithSpool keeps undelivered batches in dir, up to maxBytes, so they
+// survive a restart. Without a directory they are kept in memory, and lost
+// on restart; the application passes a directory unless one is configured.
+func WithSpool(dir string, maxBytes int64) Option {
+	return func(r *Reporter) {
+		r.spoolDir = dir
+		r.spoolMaxBytes = maxBytes
+	}
+}
+
+// WithHTTPClient replaces the client used to reach the endpoint.
+func WithHTTPClient(client *http.Client) Option {
+	return func(r *Reporter) {
+		r.client = client
+	}
+}
+
+// New creates a reporter for endpoint and loads the batches left in its
+// spool by a previous run.
+func New(endpoint string, logger logger.Logger, opts ...Option) (*Reporter, error) {
+	r := newSettings(endpoint, opts)
+	r.logger = logger
+	r.wake = make(chan struct{}, 1)
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	spool, err := openSpool(r.spoolDir, r.spoolMaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("open spool: %w", err)
+	}
+	r.spool = spool
+	r.status.Endpoint = endpoint
+	return r, nil
+}
+
+// newSettings returns a Reporter holding only the settings opts select.
+func newSettings(endpoint string, opts []Option) *Reporter {
+	r := &Reporter{
+		endpoint:      endpoint,
+		client:        &http.Client{Timeout: requestTimeout},
+		batchSize:     DefaultBatchSize,
+		flushInterval: DefaultFlushInterval,
+		minBackoff:    DefaultMinBackoff,
+		maxBackoff:    DefaultMaxBackoff,
+		spoolMaxBytes: DefaultSpoolMaxBytes,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = DefaultBatchSize
+	}
+	if r.spoolMaxBytes <= 0 {
+		r.spoolMaxBytes = DefaultSpoolMaxBytes
+	}
+	return r
+}
+
+// Reconfigure replaces the reporter's settings with endpoint and opts, as if
+// it had been created with them, without losing pending or spooled events.
+// The spool directory cannot change while the reporter runs.
+func (r *Reporter) Reconfigure(endpoint string, opts ...Option) error {
+	next := newSettings(endpoint, opts)
+	if next.spoolDir != r.spoolDir {
+		return fmt.Errorf("spool directory cannot change from %q to %q without a restart", r.spoolDir, next.spoolDir)
+	}
+
+	r.mu.Lock()
+	r.endpoint = next.endpoint
+	r.secret = next.secret
+	r.client = next.client
+	r.batchSize = next.batchSize
+	r.flushInterval = next.flushInterval
+	r.minBackoff = next.minBackoff
+	r.maxBackoff = next.maxBackoff
+	r.spoolMaxBytes = next.spoolMaxBytes
+	r.spool.maxBytes = next.spoolMaxBytes
+	r.backoff = min(r.backoff, r.maxBackoff)
+	r.status.Endpoint = endpoint
+	// Retry right away: the new endpoint may well be reachable.
+	r.status.NextAttempt = time.Time{}
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Report queues events for the next batch. When more than a few batches are
+// waiting, for example while the endpoint is down, they are moved to the
+// spool so memory stays bounded.
+func (r *Reporter) Report(events []domain.ChangeEvent) {
+	if len(events) == 0 {
+		return
+	}
+	r.mu.Lock()
+	r.pending = append(r.pending, events...)
+	full := len(r.pending) >= r.batchSize
+	if len(r.pending) >= 10*r.batchSize {
+		r.spillLocked()
+	}
+	r.mu.Unlock()
+	if full {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Status implements ports.ChangeReporter.
+func (r *Reporter) Status() domain.ReporterStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := r.status
+	status.Pending = len(r.pending)
+	status.SpooledBatches = len(r.spool.batches)
+	status.SpooledBytes = r.spool.size
+	return status
+}
+
+// Start begins delivery. Calling it again has no effect.
+func (r *Reporter) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return
+	}
+	r.started = true
+	go r.run()
+}
+
+// Stop ends delivery and spools the events that were not sent yet. It may
+// be called without Start, which only spools the events.
+func (r *Reporter) Stop() {
+	r.mu.Lock()
+	started := r.started
+	r.mu.Unlock()
+	if started {
+		close(r.stop)
+		<-r.done
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spillLocked()
+}
+
+func (r *Reporter) run() {
+	defer close(r.done)
+	r.mu.Lock()
+	wait := r.flushInterval
+	r.mu.Unlock()
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.stop:
+			timer.Stop()
+			return
+		case <-r.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+		wait = r.deliver()
+	}
+}
+
+// deliver sends the spooled batches and then the pending events, and
+// returns how long to wait before the next attempt.
+func (r *Reporter) deliver() time.Duration {
+	r.mu.Lock()
+	if wait := time.Until(r.status.NextAttempt); wait > 0 {
+		r.mu.Unlock()
+		return wait
+	}
+	r.mu.Unlock()
+
+	for {
+		r.mu.Lock()
+		batch, ok := r.spool.oldest()
+		r.mu.Unlock()
+		if !ok {
+			break
+		}
+		err := r.send(batch)
+		r.mu.Lock()
+		if err == nil || errors.Is(err, errRejected) {
+			if oldest, ok := r.spool.oldest(); ok && oldest == batch {
+				r.spool.remove()
+			}
+		}
+		r.mu.Unlock()
+		if err != nil && !errors.Is(err, errRejected) {
+			return r.failed(err)
+		}
+	}
+
+	for {
+		r.mu.Lock()
+		n := min(len(r.pending), r.batchSize)
+		if n == 0 {
+			wait := r.flushInterval
+			r.mu.Unlock()
+			return wait
+		}
+		batch := &Batch{ID: newBatchID(), Events: api.NewChangeEvents(r.pending[:n])}
+		r.pending = r.pending[n:]
+		r.mu.Unlock()
+
+		if err := r.send(batch); err != nil && !errors.Is(err, errRejected) {
+			r.mu.Lock()
+			r.spoolLocked(batch)
+			r.spillLocked()
+			r.mu.Unlock()
+			return r.failed(err)
+		}
+	}
+}
+
+// send POSTs one batch and records the outcome.
+func (r *Reporter) send(batch *Batch) error {
+	err := r.post(batch)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case err == nil:
+		r.status.Delivered += uint64(len(batch.Events))
+		r.status.LastDelivery = time.Now()
+		r.status.ConsecutiveFailures = 0
+		r.status.NextAttempt = time.Time{}
+		r.backoff = 0
+	case errors.Is(err, errRejected):
+		r.status.Rejected += uint64(len(batch.Events))
+		r.status.LastError = err.Error()
+		r.status.LastErrorAt = time.Now()
+		r.logger.Error("Endpoint rejected change events", "batch", batch.ID, "events", len(batch.Events), "error", err)
+	}
+	return err
+}
+
+func (r *Reporter) post(batch *Batch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRejected, err)
+	}
+	r.mu.Lock()
+	endpoint, secret, client := r.endpoint, r.secret, r.client
+	r.mu.Unlock()
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", errRejected, resp.Status)
+	default:
+		return fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+}
+
+// failed records a failed attempt and returns the delay before the next.
+func (r *Reporter) failed(err error) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.backoff == 0 {
+		r.backoff = r.minBackoff
+	} else {
+		r.backoff = min(2*r.backoff, r.maxBackoff)
+	}
+	r.status.ConsecutiveFailures++
+	r.status.LastError = err.Error()
+	r.status.LastErrorAt = time.Now()
+	r.status.NextAttempt = time.Now().Add(r.backoff)
+	r.logger.Error("Failed to deliver change events", "endpoint", r.endpoint, "error", err, "retry_in", r.backoff)
+	return r.backoff
+}
+
+// spillLocked moves all pending events to the spool. The caller must hold
+// r.mu.
+func (r *Reporter) spillLocked() {
+	for len(r.pending) > 0 {
+		n := min(len(r.pending), r.batchSize)
+		r.spoolLocked(&Batch{ID: newBatchID(), Events: api.NewChangeEvents(r.pending[:n])})
+		r.pending = r.pending[n:]
+	}
+	r.pending = nil
+}
+
+// spoolLocked keeps batch for a retry. The caller must hold r.mu.
+func (r *Reporter) spoolLocked(batch *Batch) {
+	dropped, err := r.spool.add(batch)
+	if err != nil {
+		r.logger.Error("Failed to spool change events", "batch", batch.ID, "error", err)
+		dropped += len(batch.Events)
+	}
+	if dropped > 0 {
+		r.status.Dropped += uint64(dropped)
+		r.logger.Error("Change event spool is full, dropped events", "dropped", dropped)
+	}
+}
+
+func newBatchID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
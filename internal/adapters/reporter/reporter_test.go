@@ -0,0 +1,289 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockLogger struct {
+	mock.Mock
+}
+
+func (l *mockLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.Called(msg, keysAndValues)
+}
+
+func (l *mockLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.Called(msg, keysAndValues)
+}
+
+func (l *mockLogger) Fatal(msg string, keysAndValues ...interface{}) {
+	l.Called(msg, keysAndValues)
+}
+
+func newLogger() *mockLogger {
+	l := new(mockLogger)
+	l.On("Info", mock.Anything, mock.Anything).Maybe()
+	l.On("Error", mock.Anything, mock.Anything).Maybe()
+	return l
+}
+
+func newEvents(from, n int) []domain.ChangeEvent {
+	events := make([]domain.ChangeEvent, n)
+	for i := range events {
+		events[i] = domain.ChangeEvent{Seq: uint64(from + i), Type: domain.ChangeCreated, Path: fmt.Sprintf("/test/file%d.txt", from+i)}
+	}
+	return events
+}
+
+// receiver is an endpoint that answers with the queued status codes, then
+// with 200, and keeps the batches it accepted.
+type receiver struct {
+	t        *testing.T
+	secret   []byte
+	mu       sync.Mutex
+	statuses []int
+	requests int
+	batches  []Batch
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	require.NoError(rc.t, err)
+	if rc.secret != nil {
+		assert.Equal(rc.t, Sign(rc.secret, r.Header.Get(TimestampHeader), body), r.Header.Get(SignatureHeader))
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests++
+	if len(rc.statuses) > 0 {
+		status := rc.statuses[0]
+		rc.statuses = rc.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+	var batch Batch
+	require.NoError(rc.t, json.Unmarshal(body, &batch))
+	rc.batches = append(rc.batches, batch)
+}
+
+// seqs lists the sequence numbers of the accepted events in arrival order.
+func (rc *receiver) seqs() []uint64 {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	var seqs []uint64
+	for _, batch := range rc.batches {
+		for _, event := range batch.Events {
+			seqs = append(seqs, event.Seq)
+		}
+	}
+	return seqs
+}
+
+func seqRange(from, to int) []uint64 {
+	var seqs []uint64
+	for i := from; i <= to; i++ {
+		seqs = append(seqs, uint64(i))
+	}
+	return seqs
+}
+
+func TestReporter_DeliversSignedBatches(t *testing.T) {
+	rc := &receiver{t: t, secret: []byte("s3cret")}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	r, err := New(server.URL, newLogger(), WithSecret(rc.secret), WithBatchSize(2), WithFlushInterval(20*time.Millisecond))
+	require.NoError(t, err)
+	r.Start()
+	defer r.Stop()
+
+	r.Report(newEvents(1, 5))
+	require.Eventually(t, func() bool { return r.Status().Delivered == 5 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, seqRange(1, 5), rc.seqs())
+	assert.Len(t, rc.batches, 3)
+
+	status := r.Status()
+	assert.Equal(t, uint64(5), status.Delivered)
+	assert.Equal(t, server.URL, status.Endpoint)
+	assert.False(t, status.LastDelivery.IsZero())
+}
+
+func TestBatch_EncodesEventsLikeTheAPI(t *testing.T) {
+	detected := time.Date(2024, 9, 16, 8, 25, 27, 500, time.UTC)
+	events := []domain.ChangeEvent{{
+		Seq: 7, Root: "/test", Type: domain.ChangeCreated, Path: "/test/a.txt", DetectedAt: detected,
+		New: &domain.FileInfo{Path: "/test/a.txt", LastModified: detected, Size: 3, Mode: "0644"},
+	}}
+
+	data, err := json.Marshal(Batch{ID: "0123456789abcdef", Events: api.NewChangeEvents(events)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id": "0123456789abcdef", "events": [{
+		"seq": 7, "root": "/test", "type": "created", "path": "/test/a.txt", "detected_at": "2024-09-16T08:25:27.0000005Z",
+		"new": {"path": "/test/a.txt", "last_modified": "2024-09-16T08:25:27.0000005Z", "size": 3, "mode": "0644", "uid": 0, "gid": 0,
+			"change_time": "0001-01-01T00:00:00Z", "access_time": "0001-01-01T00:00:00Z"}
+	}]}`, string(data))
+}
+
+func TestReporter_RetriesWithBackoffInOrder(t *testing.T) {
+	rc := &receiver{t: t, statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusBadGateway}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	r, err := New(server.URL, newLogger(), WithBatchSize(3), WithFlushInterval(10*time.Millisecond), WithBackoff(20*time.Millisecond, 50*time.Millisecond))
+	require.NoError(t, err)
+	r.Start()
+	defer r.Stop()
+
+	r.Report(newEvents(1, 3))
+	require.Eventually(t, func() bool { return r.Status().ConsecutiveFailures == 1 }, 2*time.Second, 5*time.Millisecond)
+	// Events reported while the endpoint is down are delivered after the
+	// spooled ones.
+	r.Report(newEvents(4, 4))
+
+	require.Eventually(t, func() bool { return r.Status().Delivered == 7 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, seqRange(1, 7), rc.seqs())
+
+	status := r.Status()
+	assert.Equal(t, uint64(7), status.Delivered)
+	assert.Zero(t, status.ConsecutiveFailures)
+	assert.Zero(t, status.SpooledBatches)
+	assert.True(t, status.NextAttempt.IsZero())
+	assert.Contains(t, status.LastError, "502")
+}
+
+func TestReporter_SpoolSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	r, err := New(down.URL, newLogger(), WithSpool(dir, 1<<20), WithFlushInterval(10*time.Millisecond), WithBackoff(time.Hour, time.Hour))
+	require.NoError(t, err)
+	r.Start()
+	r.Report(newEvents(1, 3))
+	require.Eventually(t, func() bool { return r.Status().ConsecutiveFailures == 1 }, 2*time.Second, 5*time.Millisecond)
+	r.Report(newEvents(4, 2))
+	r.Stop()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	rc := &receiver{t: t}
+	up := httptest.NewServer(rc)
+	defer up.Close()
+	r, err = New(up.URL, newLogger(), WithSpool(dir, 1<<20), WithFlushInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, 2, r.Status().SpooledBatches)
+	r.Start()
+	defer r.Stop()
+
+	require.Eventually(t, func() bool { return r.Status().Delivered == 5 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, seqRange(1, 5), rc.seqs())
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestReporter_StopWithoutStartSpoolsPending(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New("http://127.0.0.1:1", newLogger(), WithSpool(dir, 1<<20))
+	require.NoError(t, err)
+	r.Report(newEvents(1, 3))
+
+	stopped := make(chan struct{})
+	go func() {
+		r.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop did not return without Start")
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestReporter_DropsRejectedBatches(t *testing.T) {
+	rc := &receiver{t: t, statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	r, err := New(server.URL, newLogger(), WithBatchSize(2), WithFlushInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	r.Start()
+	defer r.Stop()
+
+	r.Report(newEvents(1, 4))
+	require.Eventually(t, func() bool { return r.Status().Delivered == 2 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, seqRange(3, 4), rc.seqs())
+	status := r.Status()
+	assert.Equal(t, uint64(2), status.Rejected)
+	assert.Zero(t, status.ConsecutiveFailures)
+}
+
+func TestSpool_StaysWithinMaxBytes(t *testing.T) {
+	batch := &Batch{ID: "0123456789abcdef", Events: api.NewChangeEvents(newEvents(1, 1))}
+	data, err := json.Marshal(batch)
+	require.NoError(t, err)
+
+	s, err := openSpool(t.TempDir(), int64(2*len(data)))
+	require.NoError(t, err)
+	for i, wantDropped := range []int{0, 0, 1} {
+		dropped, err := s.add(&Batch{ID: batch.ID, Events: api.NewChangeEvents(newEvents(i+1, 1))})
+		require.NoError(t, err)
+		assert.Equal(t, wantDropped, dropped)
+	}
+	oldest, ok := s.oldest()
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), oldest.Events[0].Seq, "the oldest batch was dropped")
+
+	dropped, err := s.add(&Batch{ID: batch.ID, Events: api.NewChangeEvents(newEvents(10, 100))})
+	require.NoError(t, err)
+	assert.Equal(t, 100, dropped, "a batch larger than the spool is dropped")
+	assert.Len(t, s.batches, 2)
+}
+
+func TestReporter_ReconfigureSwitchesEndpoint(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	rc := &receiver{t: t, secret: []byte("rotated")}
+	up := httptest.NewServer(rc)
+	defer up.Close()
+
+	r, err := New(down.URL, newLogger(), WithFlushInterval(10*time.Millisecond), WithBackoff(time.Hour, time.Hour))
+	require.NoError(t, err)
+	r.Start()
+	defer r.Stop()
+
+	r.Report(newEvents(1, 3))
+	require.Eventually(t, func() bool { return r.Status().ConsecutiveFailures > 0 }, 2*time.Second, 10*time.Millisecond)
+
+	assert.Error(t, r.Reconfigure(up.URL, WithSpool(t.TempDir(), 0)))
+	require.NoError(t, r.Reconfigure(up.URL, WithSecret(rc.secret), WithFlushInterval(10*time.Millisecond)))
+	require.Eventually(t, func() bool { return r.Status().Delivered == 3 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, seqRange(1, 3), rc.seqs())
+	assert.Equal(t, up.URL, r.Status().Endpoint)
+}
@@ -0,0 +1,150 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const spoolExt = ".json"
+
+// spooledBatch is a batch waiting for a retry.
+type spooledBatch struct {
+	seq   uint64
+	size  int64
+	count int
+	batch *Batch
+}
+
+// spool keeps batches that could not be delivered, oldest first, up to
+// maxBytes of encoded batches. With a directory every batch is written to
+// its own file, so it survives a restart; without one batches are kept in
+// memory. The spool is only used by the delivery goroutine and by Report,
+// both holding the reporter's lock.
+type spool struct {
+	dir      string
+	maxBytes int64
+	batches  []spooledBatch
+	size     int64
+	nextSeq  uint64
+}
+
+// openSpool loads the batches already spooled in dir.
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	s := &spool{dir: dir, maxBytes: maxBytes, nextSeq: 1}
+	if dir == "" {
+		return s, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, spoolExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		var batch Batch
+		if err := json.Unmarshal(data, &batch); err != nil {
+			// A batch torn by a crash; it was never acknowledged as spooled.
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		s.batches = append(s.batches, spooledBatch{seq: seq, size: int64(len(data)), count: len(batch.Events), batch: &batch})
+		s.size += int64(len(data))
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(s.batches, func(i, j int) bool { return s.batches[i].seq < s.batches[j].seq })
+	return s, nil
+}
+
+func (s *spool) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, spoolExt))
+}
+
+// add spools batch and returns how many events had to be dropped to stay
+// within maxBytes. The oldest batches are dropped first; a batch larger than
+// maxBytes on its own is dropped as well.
+func (s *spool) add(batch *Batch) (int, error) {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return 0, err
+	}
+	size := int64(len(data))
+	if size > s.maxBytes {
+		return len(batch.Events), nil
+	}
+	dropped := 0
+	for len(s.batches) > 0 && s.size+size > s.maxBytes {
+		dropped += s.batches[0].count
+		s.remove()
+	}
+
+	seq := s.nextSeq
+	s.nextSeq++
+	if s.dir != "" {
+		tmp := s.path(seq) + ".tmp"
+		if err := writeFileSync(tmp, data); err != nil {
+			os.Remove(tmp)
+			return dropped, err
+		}
+		if err := os.Rename(tmp, s.path(seq)); err != nil {
+			os.Remove(tmp)
+			return dropped, err
+		}
+	}
+	s.batches = append(s.batches, spooledBatch{seq: seq, size: size, count: len(batch.Events), batch: batch})
+	s.size += size
+	return dropped, nil
+}
+
+// oldest returns the batch to retry next.
+func (s *spool) oldest() (*Batch, bool) {
+	if len(s.batches) == 0 {
+		return nil, false
+	}
+	return s.batches[0].batch, true
+}
+
+// remove drops the oldest batch.
+func (s *spool) remove() {
+	oldest := s.batches[0]
+	if s.dir != "" {
+		os.Remove(s.path(oldest.seq))
+	}
+	s.batches = s.batches[1:]
+	s.size -= oldest.size
+}
+
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -2,7 +2,7 @@ package ui
 
 import (
 	"encoding/json"
-	"file-mod-tracker/internal/adapters/config"
+	"file-mod-tracker/internal/core/domain"
 	"file-mod-tracker/internal/ports"
 	"fmt"
 	"fyne.io/fyne/v2"
@@ -17,18 +17,25 @@ import (
 type MacOSUI struct {
 	fileMonitorService ports.FileMonitorService
 	workerAdapter      ports.WorkerAdapter
+	app                fyne.App
 }
 
 func NewMacOSUI(fileMonitorService ports.FileMonitorService, workerAdapter ports.WorkerAdapter) *MacOSUI {
 	return &MacOSUI{
 		fileMonitorService: fileMonitorService,
 		workerAdapter:      workerAdapter,
+		app:                app.New(),
 	}
 }
 
+// Quit closes the window, which makes Show return. It may be called from
+// any goroutine.
+func (ui *MacOSUI) Quit() {
+	ui.app.Quit()
+}
+
 func (ui *MacOSUI) Show() {
-	myApp := app.New()
-	myWindow := myApp.NewWindow("File Modification Tracker")
+	myWindow := ui.app.NewWindow("File Modification Tracker")
 
 	messageLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 
@@ -39,7 +46,12 @@ func (ui *MacOSUI) Show() {
 	scrollContainer := container.NewScroll(logsArea)
 	scrollContainer.SetMinSize(fyne.NewSize(800, 400))
 
-	statusLabel := widget.NewLabelWithStyle("Service Status: Stopped", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	statusLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	showStatus := func() {
+		status := ui.fileMonitorService.Status()
+		statusLabel.SetText(fmt.Sprintf("Service Status: %s since %s", status.State, status.Since.Format("15:04:05")))
+	}
+	showStatus()
 
 	showMessage := func(msg string, isError bool) {
 		messageLabel.SetText(msg)
@@ -51,25 +63,39 @@ func (ui *MacOSUI) Show() {
 		}
 	}
 
+	// control runs a lifecycle action off the UI goroutine, as stopping
+	// waits for queued commands to drain.
+	control := func(action func() error, done string) {
+		go func() {
+			defer showStatus()
+			if err := action(); err != nil {
+				showMessage(fmt.Sprintf("Error: %v", err), true)
+				return
+			}
+			showMessage(done, false)
+		}()
+	}
+
 	startBtn := widget.NewButtonWithIcon("Start Service", theme.MediaPlayIcon(), func() {
-		_, err := config.LoadConfig()
-		if err != nil {
-			showMessage(fmt.Sprintf("Error loading config: %v", err), true)
-			return
-		}
-		// Implement start service logic here
-		showMessage("Service started successfully!", false)
-		statusLabel.SetText("Service Status: Running")
+		control(ui.fileMonitorService.Start, "Service started successfully!")
 	})
 
 	stopBtn := widget.NewButtonWithIcon("Stop Service", theme.MediaStopIcon(), func() {
-		// Implement stop service logic here
-		showMessage("Service stopped successfully!", false)
-		statusLabel.SetText("Service Status: Stopped")
+		showMessage("Stopping service, waiting for queued commands...", false)
+		statusLabel.SetText("Service Status: stopping")
+		control(ui.fileMonitorService.Stop, "Service stopped successfully!")
+	})
+
+	pauseBtn := widget.NewButtonWithIcon("Pause", theme.MediaPauseIcon(), func() {
+		control(ui.fileMonitorService.Pause, "Service paused.")
+	})
+
+	resumeBtn := widget.NewButtonWithIcon("Resume", theme.MediaReplayIcon(), func() {
+		control(ui.fileMonitorService.Resume, "Service resumed.")
 	})
 
 	logsBtn := widget.NewButtonWithIcon("Fetch Logs", theme.DocumentIcon(), func() {
-		fileChanges := ui.workerAdapter.GetFileChanges()
+		fileChanges := ui.workerAdapter.GetFileChanges(domain.EventFilter{})
 		if len(fileChanges) == 0 {
 			logsArea.SetText("No file changes detected yet.")
 		} else {
@@ -84,7 +110,28 @@ func (ui *MacOSUI) Show() {
 		showMessage("Logs fetched successfully!", false)
 	})
 
-	buttonContainer := container.NewHBox(startBtn, stopBtn, logsBtn)
+	scanReportBtn := widget.NewButtonWithIcon("Scan Report", theme.WarningIcon(), func() {
+		var reports []domain.ScanReport
+		for _, status := range ui.workerAdapter.GetRootStatuses() {
+			if status.LastReport != nil {
+				reports = append(reports, *status.LastReport)
+			}
+		}
+		if len(reports) == 0 {
+			logsArea.SetText("No scan has completed yet.")
+		} else {
+			reportJSON, err := json.MarshalIndent(reports, "", "  ")
+			if err != nil {
+				log.Println("Error converting scan report to JSON:", err)
+				showMessage(fmt.Sprintf("Error converting scan report to JSON: %v", err), true)
+				return
+			}
+			logsArea.SetText(string(reportJSON))
+		}
+		showMessage("Scan report fetched successfully!", false)
+	})
+
+	buttonContainer := container.NewHBox(startBtn, stopBtn, pauseBtn, resumeBtn, logsBtn, scanReportBtn)
 
 	content := container.NewVBox(
 		statusLabel,
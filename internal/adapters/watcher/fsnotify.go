@@ -0,0 +1,185 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+	"file-mod-tracker/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FsnotifyWatcher watches directory trees recursively on top of fsnotify,
+// which only watches single directories. Directories created below a watched
+// root are added as soon as their creation is reported.
+type FsnotifyWatcher struct {
+	watcher *fsnotify.Watcher
+	logger  logger.Logger
+	events  chan domain.WatchEvent
+	errors  chan error
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+	skipDir func(path string) bool
+}
+
+// Option customises an FsnotifyWatcher at construction time.
+type Option func(*FsnotifyWatcher)
+
+// WithSkipDir leaves directories for which skip returns true, and everything
+// below them, unwatched. Roots passed to Add are always watched.
+func WithSkipDir(skip func(path string) bool) Option {
+	return func(w *FsnotifyWatcher) {
+		w.skipDir = skip
+	}
+}
+
+func NewFsnotifyWatcher(logger logger.Logger, opts ...Option) (*FsnotifyWatcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, wrapWatchError("", err)
+	}
+
+	w := &FsnotifyWatcher{
+		watcher: fsWatcher,
+		logger:  logger,
+		events:  make(chan domain.WatchEvent, 256),
+		errors:  make(chan error, 16),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.wg.Add(1)
+	go w.loop()
+	return w, nil
+}
+
+func (w *FsnotifyWatcher) Add(root string) error {
+	return w.addTree(root)
+}
+
+func (w *FsnotifyWatcher) Events() <-chan domain.WatchEvent {
+	return w.events
+}
+
+func (w *FsnotifyWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *FsnotifyWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.watcher.Close()
+		w.wg.Wait()
+		close(w.events)
+		close(w.errors)
+	})
+	return err
+}
+
+// addTree watches root and every directory below it. Subdirectories that
+// cannot be read are skipped; only a failure on root itself is returned.
+func (w *FsnotifyWatcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			w.logger.Error("Failed to watch directory", "path", path, "error", err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && w.skip(path) {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(path); err != nil {
+			return wrapWatchError(path, err)
+		}
+		return nil
+	})
+}
+
+func (w *FsnotifyWatcher) skip(path string) bool {
+	return w.skipDir != nil && w.skipDir(path)
+}
+
+func (w *FsnotifyWatcher) loop() {
+	defer w.wg.Done()
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) {
+				// Watch new directories before reporting them so nothing
+				// written into them afterwards is missed.
+				if info, err := os.Lstat(event.Name); err == nil && info.IsDir() && !w.skip(event.Name) {
+					if err := w.addTree(event.Name); err != nil {
+						w.sendError(err)
+					}
+				}
+			}
+			w.send(domain.WatchEvent{Path: event.Name, Op: toWatchOp(event.Op)})
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.sendError(err)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *FsnotifyWatcher) send(event domain.WatchEvent) {
+	select {
+	case w.events <- event:
+	case <-w.done:
+	}
+}
+
+func (w *FsnotifyWatcher) sendError(err error) {
+	select {
+	case w.errors <- err:
+	case <-w.done:
+	}
+}
+
+func toWatchOp(op fsnotify.Op) domain.WatchOp {
+	switch {
+	case op.Has(fsnotify.Create):
+		return domain.WatchCreate
+	case op.Has(fsnotify.Remove):
+		return domain.WatchRemove
+	case op.Has(fsnotify.Rename):
+		return domain.WatchRename
+	case op.Has(fsnotify.Write):
+		return domain.WatchWrite
+	default:
+		return domain.WatchChmod
+	}
+}
+
+// wrapWatchError maps the errors the kernel returns when inotify watches or
+// kqueue file descriptors run out to ports.ErrWatchLimit.
+func wrapWatchError(path string, err error) error {
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE) {
+		return fmt.Errorf("%w: %s: %v", ports.ErrWatchLimit, path, err)
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (nopLogger) Error(msg string, keysAndValues ...interface{}) {}
+func (nopLogger) Fatal(msg string, keysAndValues ...interface{}) {}
+
+func waitForEvent(t *testing.T, w *FsnotifyWatcher, path string, op domain.WatchOp) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-w.Events():
+			if event.Path == path && event.Op == op {
+				return
+			}
+		case err := <-w.Errors():
+			t.Fatalf("unexpected watcher error: %v", err)
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s on %s", op, path)
+		}
+	}
+}
+
+func TestFsnotifyWatcher_WatchesNewSubdirectories(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewFsnotifyWatcher(nopLogger{})
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Add(root))
+
+	subdir := filepath.Join(root, "nested")
+	require.NoError(t, os.Mkdir(subdir, 0o755))
+	waitForEvent(t, w, subdir, domain.WatchCreate)
+
+	file := filepath.Join(subdir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("data"), 0o644))
+	waitForEvent(t, w, file, domain.WatchCreate)
+
+	require.NoError(t, os.Remove(file))
+	waitForEvent(t, w, file, domain.WatchRemove)
+}
+
+func TestFsnotifyWatcher_SkipsExcludedDirectories(t *testing.T) {
+	root := t.TempDir()
+	ignored := filepath.Join(root, "node_modules")
+	require.NoError(t, os.Mkdir(ignored, 0o755))
+
+	w, err := NewFsnotifyWatcher(nopLogger{}, WithSkipDir(func(path string) bool {
+		return filepath.Base(path) == "node_modules"
+	}))
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Add(root))
+
+	require.NoError(t, os.WriteFile(filepath.Join(ignored, "dep.js"), []byte("data"), 0o644))
+	marker := filepath.Join(root, "marker.txt")
+	require.NoError(t, os.WriteFile(marker, []byte("data"), 0o644))
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-w.Events():
+			require.NotContains(t, event.Path, "dep.js")
+			if event.Path == marker {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for the marker file")
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package worker_test
+
+import (
+	"file-mod-tracker/internal/adapters/jobstore"
+	"file-mod-tracker/internal/adapters/worker"
+	"file-mod-tracker/internal/core/domain"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobStore_ReplaysQueuedJobsAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := jobstore.Open(dir)
+	require.NoError(t, err)
+
+	workerAdapter := newJobTestAdapter(worker.WithJobStore(store))
+	jobs, err := workerAdapter.EnqueueCommands([]string{"echo replayed"}, domain.EnqueueOptions{Queue: "replay"})
+	require.NoError(t, err)
+	workerAdapter.Stop()
+	require.NoError(t, store.Close())
+
+	store, err = jobstore.Open(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	workerAdapter = newJobTestAdapter(worker.WithJobStore(store))
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	job := waitForJob(t, workerAdapter, jobs[0].ID)
+	assert.Equal(t, domain.JobSucceeded, job.State)
+	assert.Equal(t, "replay", job.Queue)
+	assert.Equal(t, 1, job.Attempts)
+	output, err := workerAdapter.GetJobOutput(jobs[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "replayed\n", output.Stdout)
+}
+
+func TestJobStore_DoesNotKeepStdin(t *testing.T) {
+	dir := t.TempDir()
+	store, err := jobstore.Open(dir)
+	require.NoError(t, err)
+
+	workerAdapter := newJobTestAdapter(worker.WithJobStore(store))
+	jobs, err := workerAdapter.EnqueueJobs([]domain.CommandSpec{{Argv: []string{"cat"}, Stdin: "s3cret"}}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	workerAdapter.Stop()
+	require.NoError(t, store.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, jobstore.FileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cret")
+
+	store, err = jobstore.Open(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	workerAdapter = newJobTestAdapter(worker.WithJobStore(store), worker.WithRequeuePolicy(worker.RequeuePolicy{Requeue: true}))
+	job, err := workerAdapter.GetJob(jobs[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobInterrupted, job.State, "a job without its input is not run")
+	assert.Equal(t, "standard input is not kept across restarts", job.Error)
+	assert.Equal(t, 6, job.StdinBytes)
+}
+
+func TestJobStore_InterruptedJobsFollowRequeuePolicy(t *testing.T) {
+	crashed := func(id string, attempts int) domain.Job {
+		return domain.Job{ID: id, Command: "echo again", CommandSpec: domain.CommandSpec{Argv: []string{"echo", "again"}}, State: domain.JobRunning, Attempts: attempts, TimeoutMillis: time.Minute.Milliseconds(), EnqueuedAt: time.Now(), StartedAt: time.Now()}
+	}
+
+	t.Run("never", func(t *testing.T) {
+		store, err := jobstore.Open(t.TempDir())
+		require.NoError(t, err)
+		defer store.Close()
+		require.NoError(t, store.Save(crashed("a", 1)))
+
+		workerAdapter := newJobTestAdapter(worker.WithJobStore(store))
+		job, err := workerAdapter.GetJob("a")
+		require.NoError(t, err)
+		assert.Equal(t, domain.JobInterrupted, job.State)
+		assert.Equal(t, "interrupted by restart", job.Error)
+		assert.Equal(t, domain.JobInterrupted, store.Jobs()[0].State, "the new state is persisted")
+	})
+
+	t.Run("requeue up to max attempts", func(t *testing.T) {
+		store, err := jobstore.Open(t.TempDir())
+		require.NoError(t, err)
+		defer store.Close()
+		require.NoError(t, store.Save(crashed("retry", 1), crashed("exhausted", 2)))
+
+		workerAdapter := newJobTestAdapter(worker.WithJobStore(store), worker.WithRequeuePolicy(worker.RequeuePolicy{Requeue: true, MaxAttempts: 2}))
+		workerAdapter.Start()
+		defer workerAdapter.Stop()
+
+		retried := waitForJob(t, workerAdapter, "retry")
+		assert.Equal(t, domain.JobSucceeded, retried.State)
+		assert.Equal(t, 2, retried.Attempts)
+
+		exhausted, err := workerAdapter.GetJob("exhausted")
+		require.NoError(t, err)
+		assert.Equal(t, domain.JobInterrupted, exhausted.State)
+	})
+}
+
+func TestJobStore_StopKeepsUnfinishedJobs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses tail")
+	}
+	store, err := jobstore.Open(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	workerAdapter := newJobTestAdapter(worker.WithJobStore(store), worker.WithPoolSize(1), worker.WithDrainTimeout(0))
+	workerAdapter.Start()
+	jobs, err := workerAdapter.EnqueueCommands([]string{"tail -f /dev/null", "echo later"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	waitForState(t, workerAdapter, jobs[0].ID, domain.JobRunning)
+	workerAdapter.Stop()
+
+	stored := store.Jobs()
+	require.Len(t, stored, 2)
+	assert.Equal(t, domain.JobInterrupted, stored[0].State)
+	assert.Equal(t, domain.JobQueued, stored[1].State)
+}
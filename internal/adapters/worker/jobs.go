@@ -0,0 +1,260 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+	"file-mod-tracker/pkg/logger"
+)
+
+const (
+	// maxOutputBytes caps how much of each output stream a job keeps.
+	maxOutputBytes = 1 << 20
+	// maxJobs caps how many jobs are remembered. The oldest finished jobs
+	// are forgotten first.
+	maxJobs = 1000
+)
+
+// jobRecord is a job together with its captured output.
+type jobRecord struct {
+	job    domain.Job
+	stdout outputBuffer
+	stderr outputBuffer
+	// cancel kills the job's process while it is running.
+	cancel context.CancelCauseFunc
+}
+
+// jobRegistry keeps every job the adapter knows about. With a store, every
+// state change is persisted while r.mu is held, so the store sees the changes
+// in the order they happened.
+type jobRegistry struct {
+	mu     sync.Mutex
+	jobs   map[string]*jobRecord
+	order  []string
+	store  ports.JobStore
+	logger logger.Logger
+}
+
+func newJobRegistry(logger logger.Logger) *jobRegistry {
+	return &jobRegistry{jobs: make(map[string]*jobRecord), logger: logger}
+}
+
+// add registers a queued job for command. The job is not registered if it
+// cannot be persisted.
+func (r *jobRegistry) add(command string, spec domain.CommandSpec, timeout time.Duration, queue string) (domain.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record := &jobRecord{job: domain.Job{
+		ID:            newJobID(),
+		Command:       command,
+		CommandSpec:   spec,
+		StdinBytes:    len(spec.Stdin),
+		State:         domain.JobQueued,
+		TimeoutMillis: timeout.Milliseconds(),
+		Queue:         queue,
+		EnqueuedAt:    time.Now(),
+	}}
+	if r.store != nil {
+		if err := r.store.Save(record.job); err != nil {
+			return domain.Job{}, err
+		}
+	}
+	r.insert(record)
+	return record.job, nil
+}
+
+// restore registers a job loaded from the store, saving it again if it
+// changed on the way.
+func (r *jobRegistry) restore(job domain.Job, changed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.insert(&jobRecord{job: job})
+	if changed {
+		r.persist(job)
+	}
+}
+
+// insert adds record and forgets old jobs. The caller must hold r.mu.
+func (r *jobRegistry) insert(record *jobRecord) {
+	r.jobs[record.job.ID] = record
+	r.order = append(r.order, record.job.ID)
+	r.prune()
+}
+
+// remove forgets a job that never made it into the queue.
+func (r *jobRegistry) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.forget(id)
+}
+
+// forget drops jobs from the registry and the store. The caller must hold
+// r.mu.
+func (r *jobRegistry) forget(ids ...string) {
+	for _, id := range ids {
+		delete(r.jobs, id)
+		for i, other := range r.order {
+			if other == id {
+				r.order = append(r.order[:i], r.order[i+1:]...)
+				break
+			}
+		}
+	}
+	if r.store != nil && len(ids) > 0 {
+		if err := r.store.Forget(ids...); err != nil {
+			r.logger.Error("Failed to persist jobs", "error", err)
+		}
+	}
+}
+
+// prune forgets the oldest finished jobs beyond maxJobs. The caller must
+// hold r.mu.
+func (r *jobRegistry) prune() {
+	var pruned []string
+	for i := 0; len(r.jobs)-len(pruned) > maxJobs && i < len(r.order); i++ {
+		if id := r.order[i]; r.jobs[id].job.State.Finished() {
+			pruned = append(pruned, id)
+		}
+	}
+	r.forget(pruned...)
+}
+
+// persist saves the job's current state. The caller must hold r.mu.
+func (r *jobRegistry) persist(job domain.Job) {
+	if r.store == nil {
+		return
+	}
+	if err := r.store.Save(job); err != nil {
+		r.logger.Error("Failed to persist job", "job", job.ID, "error", err)
+	}
+}
+
+// update applies fn to the job with the given ID.
+func (r *jobRegistry) update(id string, fn func(job *domain.Job)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if record, ok := r.jobs[id]; ok {
+		fn(&record.job)
+		if record.job.State.Finished() {
+			record.cancel = nil
+		}
+		r.persist(record.job)
+	}
+}
+
+// start marks a queued job as running. It returns false if the job was
+// cancelled while it waited in the queue.
+func (r *jobRegistry) start(id string, cancel context.CancelCauseFunc) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.jobs[id]
+	if !ok || record.job.State != domain.JobQueued {
+		return false
+	}
+	record.job.State = domain.JobRunning
+	record.job.StartedAt = time.Now()
+	record.job.Attempts++
+	record.cancel = cancel
+	r.persist(record.job)
+	return true
+}
+
+// cancel cancels a queued job right away and asks a running one to stop.
+func (r *jobRegistry) cancel(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.jobs[id]
+	if !ok {
+		return ports.ErrJobNotFound
+	}
+	switch record.job.State {
+	case domain.JobQueued:
+		record.job.State = domain.JobCancelled
+		record.job.FinishedAt = time.Now()
+		r.persist(record.job)
+	case domain.JobRunning:
+		record.cancel(errJobCancelled)
+	default:
+		return ports.ErrJobFinished
+	}
+	return nil
+}
+
+// record returns the job with the given ID for writing its output.
+func (r *jobRegistry) record(id string) (*jobRecord, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.jobs[id]
+	return record, ok
+}
+
+func (r *jobRegistry) get(id string) (domain.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.jobs[id]
+	if !ok {
+		return domain.Job{}, ports.ErrJobNotFound
+	}
+	return record.job, nil
+}
+
+// list returns the jobs in enqueue order.
+func (r *jobRegistry) list() []domain.Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	jobs := make([]domain.Job, 0, len(r.order))
+	for _, id := range r.order {
+		jobs = append(jobs, r.jobs[id].job)
+	}
+	return jobs
+}
+
+func (r *jobRegistry) output(id string) (domain.JobOutput, error) {
+	record, ok := r.record(id)
+	if !ok {
+		return domain.JobOutput{}, ports.ErrJobNotFound
+	}
+	stdout, stdoutTruncated := record.stdout.contents()
+	stderr, stderrTruncated := record.stderr.contents()
+	return domain.JobOutput{Stdout: stdout, Stderr: stderr, Truncated: stdoutTruncated || stderrTruncated}, nil
+}
+
+func newJobID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// outputBuffer keeps the first maxOutputBytes written to it and can be read
+// while a command is still writing.
+type outputBuffer struct {
+	mu        sync.Mutex
+	data      []byte
+	truncated bool
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := maxOutputBytes - len(b.data); len(p) > room {
+		b.data = append(b.data, p[:room]...)
+		b.truncated = true
+	} else {
+		b.data = append(b.data, p...)
+	}
+	return len(p), nil
+}
+
+func (b *outputBuffer) contents() (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data), b.truncated
+}
@@ -0,0 +1,247 @@
+package worker_test
+
+import (
+	"file-mod-tracker/internal/adapters/worker"
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/core/policy"
+	"file-mod-tracker/internal/ports"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newJobTestAdapter returns an adapter allowing the commands the job tests
+// run; opts may replace its policy.
+func newJobTestAdapter(opts ...worker.Option) *worker.WorkerAdapter {
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Maybe()
+	opts = append([]worker.Option{allowCommands("echo", "cat", "ls", "tail", "sleep")}, opts...)
+	return worker.NewAdapter(mockLogger, new(mockOsqueryAdapter), "/test", 60, opts...)
+}
+
+// allowCommands sets a policy allowing only executables.
+func allowCommands(executables ...string) worker.Option {
+	commandPolicy, err := policy.New(policy.Rules{AllowedExecutables: executables})
+	if err != nil {
+		panic(err)
+	}
+	return worker.WithPolicy(commandPolicy)
+}
+
+func waitForJob(t *testing.T, workerAdapter *worker.WorkerAdapter, id string) domain.Job {
+	t.Helper()
+	var job domain.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = workerAdapter.GetJob(id)
+		require.NoError(t, err)
+		return job.State.Finished()
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestJobs_RecordOutcomeAndOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses echo and ls")
+	}
+	workerAdapter := newJobTestAdapter()
+
+	jobs, err := workerAdapter.EnqueueCommands([]string{"echo hello", "ls /does-not-exist"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, domain.JobQueued, jobs[0].State)
+	assert.NotEqual(t, jobs[0].ID, jobs[1].ID)
+
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	succeeded := waitForJob(t, workerAdapter, jobs[0].ID)
+	assert.Equal(t, domain.JobSucceeded, succeeded.State)
+	require.NotNil(t, succeeded.ExitCode)
+	assert.Equal(t, 0, *succeeded.ExitCode)
+	assert.False(t, succeeded.StartedAt.IsZero())
+	assert.False(t, succeeded.FinishedAt.Before(succeeded.StartedAt))
+
+	output, err := workerAdapter.GetJobOutput(jobs[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", output.Stdout)
+	assert.Empty(t, output.Stderr)
+
+	failed := waitForJob(t, workerAdapter, jobs[1].ID)
+	assert.Equal(t, domain.JobFailed, failed.State)
+	require.NotNil(t, failed.ExitCode)
+	assert.NotEqual(t, 0, *failed.ExitCode)
+	output, err = workerAdapter.GetJobOutput(jobs[1].ID)
+	require.NoError(t, err)
+	assert.Contains(t, output.Stderr, "does-not-exist")
+
+	listed := workerAdapter.GetJobs()
+	require.Len(t, listed, 2)
+	assert.Equal(t, jobs[0].ID, listed[0].ID)
+
+	_, err = workerAdapter.GetJob("unknown")
+	assert.ErrorIs(t, err, ports.ErrJobNotFound)
+}
+
+func TestJobs_StopCancelsQueuedJobs(t *testing.T) {
+	workerAdapter := newJobTestAdapter()
+
+	jobs, err := workerAdapter.EnqueueCommands([]string{"echo never"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	workerAdapter.Stop()
+
+	job, err := workerAdapter.GetJob(jobs[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobCancelled, job.State)
+	assert.Nil(t, job.ExitCode)
+}
+
+func waitForState(t *testing.T, workerAdapter *worker.WorkerAdapter, id string, state domain.JobState) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		job, err := workerAdapter.GetJob(id)
+		require.NoError(t, err)
+		return job.State == state
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestJobs_TimeoutKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("inspects /proc")
+	}
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "child.pid")
+	script := filepath.Join(dir, "spawn.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\nsleep 60 &\necho $! > "+pidFile+"\nwait\n"), 0o755))
+
+	workerAdapter := newJobTestAdapter(allowCommands(script))
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	jobs, err := workerAdapter.EnqueueCommands([]string{script}, domain.EnqueueOptions{Timeout: 300 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), jobs[0].TimeoutMillis)
+
+	job := waitForJob(t, workerAdapter, jobs[0].ID)
+	assert.Equal(t, domain.JobFailed, job.State)
+	assert.Equal(t, "timed out after 300ms", job.Error)
+	assert.Nil(t, job.ExitCode)
+
+	data, err := os.ReadFile(pidFile)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		// A killed child is either gone or a zombie nobody reaped yet.
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		return err != nil || strings.Contains(string(stat), ") Z ")
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestJobs_CancelRunningAndQueuedJobs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses tail")
+	}
+	workerAdapter := newJobTestAdapter(worker.WithPoolSize(1))
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	jobs, err := workerAdapter.EnqueueCommands([]string{"tail -f /dev/null", "echo later"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	waitForState(t, workerAdapter, jobs[0].ID, domain.JobRunning)
+
+	require.NoError(t, workerAdapter.CancelJob(jobs[1].ID))
+	require.NoError(t, workerAdapter.CancelJob(jobs[0].ID))
+
+	running := waitForJob(t, workerAdapter, jobs[0].ID)
+	assert.Equal(t, domain.JobCancelled, running.State)
+	assert.Equal(t, "cancelled by request", running.Error)
+
+	queued, err := workerAdapter.GetJob(jobs[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobCancelled, queued.State)
+	assert.True(t, queued.StartedAt.IsZero())
+
+	assert.ErrorIs(t, workerAdapter.CancelJob(jobs[0].ID), ports.ErrJobFinished)
+	assert.ErrorIs(t, workerAdapter.CancelJob("unknown"), ports.ErrJobNotFound)
+}
+
+func TestJobs_StopKillsCommandsPastDrainTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses tail")
+	}
+	workerAdapter := newJobTestAdapter(worker.WithDrainTimeout(100 * time.Millisecond))
+	workerAdapter.Start()
+
+	jobs, err := workerAdapter.EnqueueCommands([]string{"tail -f /dev/null"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	waitForState(t, workerAdapter, jobs[0].ID, domain.JobRunning)
+
+	stopped := make(chan struct{})
+	go func() {
+		workerAdapter.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop hung on a running command")
+	}
+
+	job, err := workerAdapter.GetJob(jobs[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobInterrupted, job.State)
+}
+
+func TestJobs_CommandLinesAndSpecs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	// Shells are only run when allowed by name.
+	shellPolicy, err := policy.New(policy.Rules{AllowedExecutables: []string{"sh", "printf", "echo"}})
+	require.NoError(t, err)
+	workerAdapter := newJobTestAdapter(worker.WithPolicy(shellPolicy))
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	lines, err := workerAdapter.EnqueueCommands([]string{`printf '%s\n' 'search term' "it's"`}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"printf", `%s\n`, "search term", "it's"}, lines[0].Argv)
+
+	specs, err := workerAdapter.EnqueueJobs([]domain.CommandSpec{{
+		Argv:  []string{"sh", "-c", `printf '%s %s ' "$GREETING" "$(pwd -P)"; cat`},
+		Env:   map[string]string{"GREETING": "hello world"},
+		Cwd:   dir,
+		Stdin: "from stdin",
+	}}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, `sh -c 'printf '\''%s %s '\'' "$GREETING" "$(pwd -P)"; cat'`, specs[0].Command)
+
+	for id, want := range map[string]string{
+		lines[0].ID: "search term\nit's\n",
+		specs[0].ID: "hello world " + dir + " from stdin",
+	} {
+		assert.Equal(t, domain.JobSucceeded, waitForJob(t, workerAdapter, id).State)
+		output, err := workerAdapter.GetJobOutput(id)
+		require.NoError(t, err)
+		assert.Equal(t, want, output.Stdout)
+	}
+
+	_, err = workerAdapter.EnqueueCommands([]string{`echo 'unterminated`}, domain.EnqueueOptions{})
+	var rejected *ports.CommandRejectedError
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, "unterminated quote", rejected.Reason)
+}
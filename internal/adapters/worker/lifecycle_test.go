@@ -0,0 +1,68 @@
+package worker_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"file-mod-tracker/internal/adapters/worker"
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle_PauseHoldsScansAndCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses echo")
+	}
+	scanner := &countingScanner{scans: make(map[string]int)}
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+	workerAdapter := worker.NewAdapter(mockLogger, scanner, "/root", 60, worker.WithRoots([]domain.WatchRoot{
+		{Path: "/root", CheckFrequency: 20 * time.Millisecond},
+	}), allowCommands("echo"))
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+	require.Eventually(t, func() bool { return scanner.count("/root") > 0 }, 3*time.Second, 10*time.Millisecond)
+
+	workerAdapter.Pause()
+	scans := scanner.count("/root")
+	jobs, err := workerAdapter.EnqueueCommands([]string{"echo held"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, scans, scanner.count("/root"))
+	job, err := workerAdapter.GetJob(jobs[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobQueued, job.State)
+
+	workerAdapter.Resume()
+	assert.Equal(t, domain.JobSucceeded, waitForJob(t, workerAdapter, jobs[0].ID).State)
+	require.Eventually(t, func() bool { return scanner.count("/root") > scans }, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestLifecycle_RestartAfterStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses echo")
+	}
+	workerAdapter := newJobTestAdapter()
+	workerAdapter.Start()
+	jobs, err := workerAdapter.EnqueueCommands([]string{"echo one"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobSucceeded, waitForJob(t, workerAdapter, jobs[0].ID).State)
+	workerAdapter.Stop()
+
+	_, err = workerAdapter.EnqueueCommands([]string{"echo stopped"}, domain.EnqueueOptions{})
+	assert.ErrorIs(t, err, ports.ErrWorkerStopped)
+
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+	jobs, err = workerAdapter.EnqueueCommands([]string{"echo two"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobSucceeded, waitForJob(t, workerAdapter, jobs[0].ID).State)
+	output, err := workerAdapter.GetJobOutput(jobs[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "two\n", output.Stdout)
+}
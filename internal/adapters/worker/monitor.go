@@ -0,0 +1,477 @@
+package worker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+	"file-mod-tracker/pkg/ignore"
+)
+
+const defaultCheckFrequency = time.Minute
+
+// watchDebounce is how long notifications are collected before the paths
+// they name are read, so a burst of writes to a file costs one query.
+const watchDebounce = 100 * time.Millisecond
+
+// rootMonitor holds the snapshot and status of a single watch root. Its
+// mutex serialises scans and watch events of that root only, so roots never
+// wait for each other.
+type rootMonitor struct {
+	root        domain.WatchRoot
+	mu          sync.Mutex
+	snapshot    map[string]domain.FileInfo
+	hasSnapshot bool
+	status      domain.RootStatus
+	// stop is closed when the root is removed or replaced; done is closed
+	// once its goroutine has returned.
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newRootMonitor(root domain.WatchRoot) *rootMonitor {
+	if root.CheckFrequency <= 0 {
+		root.CheckFrequency = defaultCheckFrequency
+	}
+	if root.WatchMode == "" {
+		root.WatchMode = domain.WatchModePoll
+	}
+	return &rootMonitor{
+		root:     root,
+		snapshot: make(map[string]domain.FileInfo),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		status: domain.RootStatus{
+			Path:           root.Path,
+			WatchMode:      root.WatchMode,
+			CheckFrequency: int(root.CheckFrequency / time.Second),
+		},
+	}
+}
+
+func (a *WorkerAdapter) timerThread(m *rootMonitor) {
+	defer a.wg.Done()
+	defer close(m.done)
+	if m.root.WatchMode == domain.WatchModeFsnotify && a.watchLoop(m) {
+		return
+	}
+	a.pollLoop(m)
+}
+
+func (a *WorkerAdapter) pollLoop(m *rootMonitor) {
+	m.mu.Lock()
+	m.status.WatchMode = domain.WatchModePoll
+	m.status.NextScan = time.Now().Add(m.root.CheckFrequency)
+	m.mu.Unlock()
+
+	ticker := time.NewTicker(m.root.CheckFrequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.logger.Info("Timer thread woke up", "root", m.root.Path)
+			a.scan(m)
+			m.mu.Lock()
+			m.status.NextScan = time.Now().Add(m.root.CheckFrequency)
+			m.mu.Unlock()
+		case <-a.stopChan:
+			return
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// watchLoop feeds filesystem notifications into the change pipeline. It
+// returns true when the adapter was stopped and false when the watcher can no
+// longer cover the tree and the caller should fall back to polling.
+func (a *WorkerAdapter) watchLoop(m *rootMonitor) bool {
+	if a.newWatcher == nil {
+		a.logger.Error("No file watcher configured, falling back to polling", "root", m.root.Path)
+		return false
+	}
+	watcher, err := a.newWatcher(m.root)
+	if err != nil {
+		a.logger.Error("Failed to create file watcher, falling back to polling", "root", m.root.Path, "error", err)
+		return false
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(m.root.Path); err != nil {
+		a.logger.Error("Failed to watch directory, falling back to polling", "directory", m.root.Path, "error", err)
+		m.recordError(err)
+		return false
+	}
+	a.logger.Info("Watching directory for changes", "directory", m.root.Path)
+	a.scan(m)
+
+	// Notifications are coalesced per path, in the order the paths were
+	// first notified, until flush fires.
+	pending := make(map[string]domain.WatchOp)
+	var order []string
+	var flush <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events():
+			if !ok {
+				return false
+			}
+			op, seen := pending[event.Path]
+			if !seen {
+				order = append(order, event.Path)
+			}
+			// A path created during the window is handled as created, so
+			// a file removed again still reports both events.
+			if !seen || op != domain.WatchCreate {
+				pending[event.Path] = event.Op
+			}
+			if flush == nil {
+				flush = time.After(watchDebounce)
+			}
+		case <-flush:
+			for _, path := range order {
+				a.handleWatchEvent(m, domain.WatchEvent{Path: path, Op: pending[path]})
+			}
+			pending = make(map[string]domain.WatchOp)
+			order = nil
+			flush = nil
+		case err, ok := <-watcher.Errors():
+			if !ok {
+				return false
+			}
+			m.recordError(err)
+			if errors.Is(err, ports.ErrWatchLimit) {
+				a.logger.Error("Watch limit reached, falling back to polling", "root", m.root.Path, "error", err)
+				return false
+			}
+			// Events may have been dropped, so resynchronise with a full scan.
+			a.logger.Error("File watcher error, rescanning directory", "root", m.root.Path, "error", err)
+			a.scan(m)
+		case <-a.stopChan:
+			return true
+		case <-m.stop:
+			return true
+		}
+	}
+}
+
+func (a *WorkerAdapter) scan(m *rootMonitor) {
+	started := time.Now()
+	result, err := a.osqueryAdapter.ScanFiles(m.root.Path, m.root.ScanOptions())
+	if err != nil {
+		a.logger.Error("Failed to get file stats", "root", m.root.Path, "error", err)
+		m.recordError(err)
+		return
+	}
+	stats := a.hashFiles(m, result.Files)
+	a.pruneHashes(m, m.root.Path, stats)
+
+	m.mu.Lock()
+	m.status.LastScan = started
+	m.status.LastScanMillis = time.Since(started).Milliseconds()
+	m.status.LastReport = &result.Report
+	m.mu.Unlock()
+	a.updateFileChanges(m, stats, result.Unreadable)
+}
+
+func (a *WorkerAdapter) hashFiles(m *rootMonitor, stats []domain.FileInfo) []domain.FileInfo {
+	if a.hasher == nil || len(m.root.HashAlgorithms) == 0 {
+		return stats
+	}
+	return a.hasher.HashFiles(stats, m.root.HashAlgorithms)
+}
+
+// pruneHashes lets the hasher forget the files at or below path that are not
+// in stats. Nothing below path is kept when the root is not hashed.
+func (a *WorkerAdapter) pruneHashes(m *rootMonitor, path string, stats []domain.FileInfo) {
+	if a.hasher == nil {
+		return
+	}
+	if len(m.root.HashAlgorithms) == 0 {
+		stats = nil
+	}
+	a.hasher.Prune(path, stats)
+}
+
+// handleWatchEvent re-reads the notified path and diffs it against the part
+// of the snapshot it covers.
+func (a *WorkerAdapter) handleWatchEvent(m *rootMonitor, event domain.WatchEvent) {
+	var stats []domain.FileInfo
+	filter := m.filter()
+	info, err := os.Lstat(event.Path)
+	switch {
+	case err == nil && info.IsDir() && event.Op != domain.WatchCreate:
+		// Only file attributes are tracked; files inside the directory
+		// report their own changes.
+		return
+	case err == nil:
+		stats, err = a.osqueryAdapter.GetFileStats(event.Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			a.logger.Error("Failed to get file stats", "path", event.Path, "error", err)
+			return
+		}
+		stats = a.hashFiles(m, m.allowed(stats, filter))
+	case errors.Is(err, os.ErrNotExist):
+		a.pruneHashes(m, event.Path, nil)
+	default:
+		a.logger.Error("Failed to stat changed path", "path", event.Path, "error", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix := event.Path + string(filepath.Separator)
+	var previous []domain.FileInfo
+	for path, info := range m.snapshot {
+		if path == event.Path || strings.HasPrefix(path, prefix) {
+			previous = append(previous, info)
+		}
+	}
+
+	now := time.Now()
+	events := domain.DiffSnapshots(previous, stats, now)
+	if len(events) == 0 && event.Op == domain.WatchCreate && len(previous) == 0 && len(stats) == 0 && m.allows(event.Path, filter) {
+		// The file was removed again before it could be read.
+		info := domain.FileInfo{Path: event.Path}
+		events = []domain.ChangeEvent{
+			{Type: domain.ChangeCreated, Path: event.Path, New: &info, DetectedAt: now},
+			{Type: domain.ChangeDeleted, Path: event.Path, Old: &info, DetectedAt: now},
+		}
+	}
+
+	for _, info := range previous {
+		delete(m.snapshot, info.Path)
+	}
+	for _, info := range stats {
+		m.snapshot[info.Path] = info
+	}
+	m.status.FilesTracked = len(m.snapshot)
+	a.recordChanges(m, events)
+}
+
+// updateFileChanges diffs the latest scan against the previous one and records
+// the resulting change events. The first scan only establishes the baseline.
+// Files below paths the scan could not read keep their previous state rather
+// than being reported as deleted.
+func (a *WorkerAdapter) updateFileChanges(m *rootMonitor, newStats []domain.FileInfo, unreadablePaths []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := make([]domain.FileInfo, 0, len(m.snapshot))
+	for _, info := range m.snapshot {
+		previous = append(previous, info)
+		if below(info.Path, unreadablePaths) {
+			newStats = append(newStats, info)
+		}
+	}
+	m.snapshot = make(map[string]domain.FileInfo, len(newStats))
+	for _, info := range newStats {
+		m.snapshot[info.Path] = info
+	}
+	m.status.FilesTracked = len(m.snapshot)
+
+	if !m.hasSnapshot {
+		m.hasSnapshot = true
+		a.logger.Info("Baseline snapshot captured", "directory", m.root.Path, "files", len(newStats))
+		return
+	}
+	a.recordChanges(m, domain.DiffSnapshots(previous, newStats, time.Now()))
+}
+
+// recordChanges stores events of root m. The caller must hold m.mu.
+func (a *WorkerAdapter) recordChanges(m *rootMonitor, events []domain.ChangeEvent) {
+	if len(events) == 0 {
+		return
+	}
+	for i := range events {
+		events[i].Root = m.root.Path
+	}
+	if err := a.eventStore.Append(events); err != nil {
+		a.logger.Error("Failed to store file changes", "error", err, "count", len(events))
+		m.status.LastError = err.Error()
+		m.status.LastErrorAt = time.Now()
+	}
+	m.status.EventsRecorded += uint64(len(events))
+	if a.reporter != nil {
+		a.reporter.Report(events)
+	}
+	for _, event := range events {
+		a.logger.Info("File change detected", "type", event.Type, "path", event.Path, "seq", event.Seq)
+	}
+}
+
+// below reports whether path is one of prefixes or lies below one of them.
+func below(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter applies the root's scan options and the ignore files in its tree.
+func (m *rootMonitor) filter() *ignore.Filter {
+	return ignore.NewFilter(m.root.Path, ignore.Options{MaxDepth: m.root.MaxDepth, Include: m.root.Include, Exclude: m.root.Exclude})
+}
+
+// allowed drops the files the root's filter excludes.
+func (m *rootMonitor) allowed(stats []domain.FileInfo, filter *ignore.Filter) []domain.FileInfo {
+	filtered := stats[:0]
+	for _, info := range stats {
+		if m.allows(info.Path, filter) {
+			filtered = append(filtered, info)
+		}
+	}
+	return filtered
+}
+
+func (m *rootMonitor) allows(path string, filter *ignore.Filter) bool {
+	rel, err := filepath.Rel(m.root.Path, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	return rel == "." || filter.Allows(filepath.ToSlash(rel))
+}
+
+func (m *rootMonitor) recordError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status.LastError = err.Error()
+	m.status.LastErrorAt = time.Now()
+}
+
+func (a *WorkerAdapter) GetFileChanges(filter domain.EventFilter) []domain.ChangeEvent {
+	changes, err := a.eventStore.Query(filter)
+	if err != nil {
+		a.logger.Error("Failed to read file changes", "error", err)
+		return nil
+	}
+	return changes
+}
+
+// GetRootStatuses reports the scheduling and scan state of every root.
+func (a *WorkerAdapter) GetRootStatuses() []domain.RootStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	statuses := make([]domain.RootStatus, 0, len(a.roots))
+	for _, m := range a.roots {
+		m.mu.Lock()
+		statuses = append(statuses, m.status)
+		m.mu.Unlock()
+	}
+	return statuses
+}
+
+// SetRoots replaces the monitored roots, also while the adapter runs. Roots
+// whose settings did not change keep running untouched. Changed roots are
+// restarted with the new settings; they keep their snapshot as long as they
+// still scan the same files, so no change is missed or reported twice.
+func (a *WorkerAdapter) SetRoots(roots []domain.WatchRoot) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	current := make(map[string]*rootMonitor, len(a.roots))
+	for _, m := range a.roots {
+		current[m.root.Path] = m
+	}
+	next := make([]*rootMonitor, 0, len(roots))
+	var started []*rootMonitor
+	for _, root := range roots {
+		m := newRootMonitor(root)
+		old, ok := current[m.root.Path]
+		delete(current, m.root.Path)
+		switch {
+		case ok && reflect.DeepEqual(old.root, m.root):
+			next = append(next, old)
+			continue
+		case ok:
+			a.stopRoot(old)
+			if sameScan(old.root, m.root) {
+				m.inherit(old)
+			}
+			a.logger.Info("Watch root changed", "root", m.root.Path, "watch_mode", m.root.WatchMode, "check_frequency", m.root.CheckFrequency)
+		default:
+			a.logger.Info("Watch root added", "root", m.root.Path, "watch_mode", m.root.WatchMode, "check_frequency", m.root.CheckFrequency)
+		}
+		next = append(next, m)
+		started = append(started, m)
+	}
+	for _, m := range a.roots {
+		if _, removed := current[m.root.Path]; removed {
+			a.stopRoot(m)
+			if a.hasher != nil {
+				a.hasher.Prune(m.root.Path, nil)
+			}
+			a.logger.Info("Watch root removed", "root", m.root.Path)
+		}
+	}
+
+	a.roots = next
+	if a.monitoring() && !a.stopped {
+		a.startRoots(started)
+	}
+}
+
+// monitoring reports whether the root goroutines run, or are stopping. The
+// caller must hold a.mu.
+func (a *WorkerAdapter) monitoring() bool {
+	return a.running && !a.paused
+}
+
+// startRoots starts a goroutine for each of roots. The caller must hold a.mu.
+func (a *WorkerAdapter) startRoots(roots []*rootMonitor) {
+	a.wg.Add(len(roots))
+	for _, m := range roots {
+		go a.timerThread(m)
+	}
+}
+
+// stopRoot stops the goroutine of root m and waits for it to return. The
+// caller must hold a.mu.
+func (a *WorkerAdapter) stopRoot(m *rootMonitor) {
+	close(m.stop)
+	if a.monitoring() {
+		<-m.done
+	}
+}
+
+// resetRoots replaces the roots, whose goroutines have returned, with
+// monitors that can be started again and keep their snapshots. The caller
+// must hold a.mu.
+func (a *WorkerAdapter) resetRoots() {
+	for i, old := range a.roots {
+		m := newRootMonitor(old.root)
+		m.inherit(old)
+		a.roots[i] = m
+	}
+}
+
+// sameScan reports whether roots a and b see the same files with the same
+// attributes, so that a snapshot taken by one is a valid baseline for the
+// other.
+func sameScan(a, b domain.WatchRoot) bool {
+	return a.Path == b.Path && reflect.DeepEqual(a.ScanOptions(), b.ScanOptions())
+}
+
+// inherit takes over the snapshot and counters of old, which must no longer
+// be running.
+func (m *rootMonitor) inherit(old *rootMonitor) {
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	m.snapshot = old.snapshot
+	m.hasSnapshot = old.hasSnapshot
+	status := old.status
+	status.WatchMode = m.status.WatchMode
+	status.CheckFrequency = m.status.CheckFrequency
+	status.NextScan = time.Time{}
+	m.status = status
+}
@@ -0,0 +1,388 @@
+package worker_test
+
+import (
+	"file-mod-tracker/internal/adapters/worker"
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWatcher lets tests push filesystem notifications into the adapter
+type fakeWatcher struct {
+	addErr error
+	events chan domain.WatchEvent
+	errors chan error
+}
+
+func newFakeWatcher(addErr error) *fakeWatcher {
+	return &fakeWatcher{
+		addErr: addErr,
+		events: make(chan domain.WatchEvent, 10),
+		errors: make(chan error, 10),
+	}
+}
+
+func (w *fakeWatcher) Add(root string) error            { return w.addErr }
+func (w *fakeWatcher) Events() <-chan domain.WatchEvent { return w.events }
+func (w *fakeWatcher) Errors() <-chan error             { return w.errors }
+func (w *fakeWatcher) Close() error                     { return nil }
+
+func watchedRoot(dir string, fileWatcher *fakeWatcher) []worker.Option {
+	return []worker.Option{
+		worker.WithRoots([]domain.WatchRoot{{Path: dir, CheckFrequency: time.Second, WatchMode: domain.WatchModeFsnotify}}),
+		worker.WithWatcherFactory(func(domain.WatchRoot) (ports.FileWatcher, error) { return fileWatcher, nil }),
+	}
+}
+
+func TestWatcher_RecordsCreatedAndDeletedFiles(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.txt")
+	created := filepath.Join(dir, "created.txt")
+	require.NoError(t, os.WriteFile(existing, []byte("old"), 0o644))
+
+	mockOsquery := new(mockOsqueryAdapter)
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+	fileWatcher := newFakeWatcher(nil)
+
+	existingInfo := domain.FileInfo{Path: existing, LastModified: time.Date(2024, 9, 23, 12, 0, 0, 0, time.UTC), Size: 3}
+	createdInfo := domain.FileInfo{Path: created, LastModified: time.Date(2024, 9, 23, 12, 0, 1, 0, time.UTC), Size: 3}
+	mockOsquery.On("ScanFiles", dir, mock.Anything).Return(domain.ScanResult{Files: []domain.FileInfo{existingInfo}}, nil).Once()
+	mockOsquery.On("GetFileStats", created).Return([]domain.FileInfo{createdInfo}, nil).Once()
+
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, dir, 60, watchedRoot(dir, fileWatcher)...)
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	require.NoError(t, os.WriteFile(created, []byte("new"), 0o644))
+	fileWatcher.events <- domain.WatchEvent{Path: created, Op: domain.WatchCreate}
+	require.NoError(t, os.Remove(existing))
+	fileWatcher.events <- domain.WatchEvent{Path: existing, Op: domain.WatchRemove}
+
+	assert.Eventually(t, func() bool {
+		return len(workerAdapter.GetFileChanges(domain.EventFilter{})) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	changes := workerAdapter.GetFileChanges(domain.EventFilter{})
+	assert.Equal(t, domain.ChangeCreated, changes[0].Type)
+	assert.Equal(t, created, changes[0].Path)
+	assert.Equal(t, domain.ChangeDeleted, changes[1].Type)
+	assert.Equal(t, existing, changes[1].Path)
+	mockOsquery.AssertExpectations(t)
+}
+
+func TestWatcher_ReportsShortLivedFiles(t *testing.T) {
+	dir := t.TempDir()
+	transient := filepath.Join(dir, "transient.swp")
+
+	mockOsquery := new(mockOsqueryAdapter)
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+	fileWatcher := newFakeWatcher(nil)
+
+	mockOsquery.On("ScanFiles", dir, mock.Anything).Return(domain.ScanResult{Files: []domain.FileInfo{}}, nil).Once()
+
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, dir, 60, watchedRoot(dir, fileWatcher)...)
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	// The file is already gone by the time the notification is handled.
+	fileWatcher.events <- domain.WatchEvent{Path: transient, Op: domain.WatchCreate}
+
+	assert.Eventually(t, func() bool {
+		return len(workerAdapter.GetFileChanges(domain.EventFilter{})) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	changes := workerAdapter.GetFileChanges(domain.EventFilter{})
+	assert.Equal(t, domain.ChangeCreated, changes[0].Type)
+	assert.Equal(t, domain.ChangeDeleted, changes[1].Type)
+	assert.Equal(t, transient, changes[1].Path)
+}
+
+func TestWatcher_CoalescesBurstsPerPath(t *testing.T) {
+	dir := t.TempDir()
+	written := filepath.Join(dir, "written.log")
+	require.NoError(t, os.WriteFile(written, []byte("line\n"), 0o644))
+
+	mockOsquery := new(mockOsqueryAdapter)
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+	fileWatcher := newFakeWatcher(nil)
+
+	before := domain.FileInfo{Path: written, LastModified: time.Date(2024, 9, 23, 12, 0, 0, 0, time.UTC), Size: 5}
+	after := domain.FileInfo{Path: written, LastModified: time.Date(2024, 9, 23, 12, 0, 1, 0, time.UTC), Size: 40}
+	mockOsquery.On("ScanFiles", dir, mock.Anything).Return(domain.ScanResult{Files: []domain.FileInfo{before}}, nil).Once()
+	mockOsquery.On("GetFileStats", written).Return([]domain.FileInfo{after}, nil)
+
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, dir, 60, watchedRoot(dir, fileWatcher)...)
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	for i := 0; i < 8; i++ {
+		fileWatcher.events <- domain.WatchEvent{Path: written, Op: domain.WatchWrite}
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(workerAdapter.GetFileChanges(domain.EventFilter{})) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, workerAdapter.GetFileChanges(domain.EventFilter{}), 1)
+	mockOsquery.AssertNumberOfCalls(t, "GetFileStats", 1)
+}
+
+func TestWatcher_FallsBackToPollingOnWatchLimit(t *testing.T) {
+	mockOsquery := new(mockOsqueryAdapter)
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+	mockLogger.On("Error", "Failed to watch directory, falling back to polling", mock.Anything).Once()
+	fileWatcher := newFakeWatcher(fmt.Errorf("%w: /test/deep", ports.ErrWatchLimit))
+
+	polled := make(chan struct{}, 1)
+	mockOsquery.On("ScanFiles", "/test", mock.Anything).Return(domain.ScanResult{Files: []domain.FileInfo{}}, nil).Run(func(mock.Arguments) {
+		select {
+		case polled <- struct{}{}:
+		default:
+		}
+	})
+
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/test", 1, watchedRoot("/test", fileWatcher)...)
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	select {
+	case <-polled:
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected the adapter to fall back to polling")
+	}
+	mockLogger.AssertExpectations(t)
+}
+
+func TestRoots_ScheduledIndependently(t *testing.T) {
+	mockOsquery := new(mockOsqueryAdapter)
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+
+	fast := make(chan struct{}, 10)
+	mockOsquery.On("ScanFiles", "/fast", domain.ScanOptions{Exclude: []string{"*.tmp"}}).Return(domain.ScanResult{Files: []domain.FileInfo{}}, nil).Run(func(mock.Arguments) {
+		select {
+		case fast <- struct{}{}:
+		default:
+		}
+	})
+
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/unused", 60, worker.WithRoots([]domain.WatchRoot{
+		{Path: "/fast", CheckFrequency: 50 * time.Millisecond, Exclude: []string{"*.tmp"}},
+		{Path: "/slow", CheckFrequency: time.Hour},
+	}))
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-fast:
+		case <-time.After(3 * time.Second):
+			t.Fatal("expected the fast root to be scanned")
+		}
+	}
+	mockOsquery.AssertNotCalled(t, "ScanFiles", "/slow", mock.Anything)
+	mockOsquery.AssertNotCalled(t, "ScanFiles", "/unused", mock.Anything)
+
+	statuses := workerAdapter.GetRootStatuses()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "/fast", statuses[0].Path)
+	assert.False(t, statuses[0].LastScan.IsZero())
+	assert.Equal(t, "/slow", statuses[1].Path)
+	assert.Equal(t, 3600, statuses[1].CheckFrequency)
+	assert.True(t, statuses[1].LastScan.IsZero())
+}
+
+func TestScan_KeepsFilesBelowUnreadablePaths(t *testing.T) {
+	mockOsquery := new(mockOsqueryAdapter)
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+
+	public := domain.FileInfo{Path: "/test/public.txt", Size: 1}
+	private := domain.FileInfo{Path: "/test/private/secret.txt", Size: 2}
+	gone := domain.FileInfo{Path: "/test/tmp/gone.txt", Size: 3}
+	mockOsquery.On("ScanFiles", "/test", mock.Anything).Return(domain.ScanResult{Files: []domain.FileInfo{public, private, gone}}, nil).Once()
+	mockOsquery.On("ScanFiles", "/test", mock.Anything).Return(domain.ScanResult{
+		Files: []domain.FileInfo{public},
+		Report: domain.ScanReport{ErrorCount: 2, Errors: []domain.ScanError{
+			{Path: "/test/private", Kind: domain.ScanErrorPermission},
+			{Path: "/test/tmp", Kind: domain.ScanErrorVanished},
+		}},
+		Unreadable: []string{"/test/private"},
+	}, nil)
+
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/unused", 60, worker.WithRoots([]domain.WatchRoot{
+		{Path: "/test", CheckFrequency: 50 * time.Millisecond},
+	}))
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	assert.Eventually(t, func() bool {
+		return len(workerAdapter.GetFileChanges(domain.EventFilter{})) > 0
+	}, 2*time.Second, 10*time.Millisecond)
+
+	changes := workerAdapter.GetFileChanges(domain.EventFilter{})
+	require.Len(t, changes, 1)
+	assert.Equal(t, domain.ChangeDeleted, changes[0].Type)
+	assert.Equal(t, gone.Path, changes[0].Path)
+
+	status := workerAdapter.GetRootStatuses()[0]
+	require.NotNil(t, status.LastReport)
+	assert.Equal(t, 2, status.LastReport.ErrorCount)
+	assert.Equal(t, 2, status.FilesTracked)
+}
+
+func TestScan_KeepsFilesBelowUnreadablePathsPastTheReportCap(t *testing.T) {
+	mockOsquery := new(mockOsqueryAdapter)
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+
+	var files []domain.FileInfo
+	second := domain.ScanResult{}
+	for i := 0; i < domain.MaxScanErrors+50; i++ {
+		dir := fmt.Sprintf("/test/dir%03d", i)
+		files = append(files, domain.FileInfo{Path: dir + "/file.txt", Size: 1})
+		second.AddError(domain.ScanError{Path: dir, Kind: domain.ScanErrorPermission})
+	}
+	require.Len(t, second.Report.Errors, domain.MaxScanErrors)
+	mockOsquery.On("ScanFiles", "/test", mock.Anything).Return(domain.ScanResult{Files: files}, nil).Once()
+	mockOsquery.On("ScanFiles", "/test", mock.Anything).Return(second, nil)
+
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/unused", 60, worker.WithRoots([]domain.WatchRoot{
+		{Path: "/test", CheckFrequency: 50 * time.Millisecond},
+	}))
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	assert.Eventually(t, func() bool {
+		status := workerAdapter.GetRootStatuses()[0]
+		return status.LastReport != nil && status.LastReport.ErrorCount > 0
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Never(t, func() bool {
+		return len(workerAdapter.GetFileChanges(domain.EventFilter{})) > 0
+	}, 300*time.Millisecond, 10*time.Millisecond)
+	assert.Equal(t, len(files), workerAdapter.GetRootStatuses()[0].FilesTracked)
+}
+
+// fakeReporter collects the events handed to it.
+type fakeReporter struct {
+	mu     sync.Mutex
+	events []domain.ChangeEvent
+}
+
+func (r *fakeReporter) Report(events []domain.ChangeEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, events...)
+}
+
+func (r *fakeReporter) Status() domain.ReporterStatus { return domain.ReporterStatus{} }
+
+func (r *fakeReporter) reported() []domain.ChangeEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]domain.ChangeEvent(nil), r.events...)
+}
+
+func TestWatcher_ForwardsEventsToReporter(t *testing.T) {
+	dir := t.TempDir()
+	created := filepath.Join(dir, "created.txt")
+
+	mockOsquery := new(mockOsqueryAdapter)
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+	fileWatcher := newFakeWatcher(nil)
+	changeReporter := new(fakeReporter)
+
+	createdInfo := domain.FileInfo{Path: created, LastModified: time.Date(2024, 9, 23, 12, 0, 1, 0, time.UTC), Size: 3}
+	mockOsquery.On("ScanFiles", dir, mock.Anything).Return(domain.ScanResult{}, nil).Once()
+	mockOsquery.On("GetFileStats", created).Return([]domain.FileInfo{createdInfo}, nil).Once()
+
+	opts := append(watchedRoot(dir, fileWatcher), worker.WithReporter(changeReporter))
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, dir, 60, opts...)
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	require.NoError(t, os.WriteFile(created, []byte("new"), 0o644))
+	fileWatcher.events <- domain.WatchEvent{Path: created, Op: domain.WatchCreate}
+
+	assert.Eventually(t, func() bool { return len(changeReporter.reported()) == 1 }, 2*time.Second, 10*time.Millisecond)
+	reported := changeReporter.reported()[0]
+	assert.Equal(t, domain.ChangeCreated, reported.Type)
+	assert.Equal(t, dir, reported.Root)
+	assert.Equal(t, uint64(1), reported.Seq)
+}
+
+// countingScanner counts the scans of each directory and finds no files.
+type countingScanner struct {
+	mu    sync.Mutex
+	scans map[string]int
+}
+
+func (s *countingScanner) GetFileStats(string) ([]domain.FileInfo, error) { return nil, nil }
+
+func (s *countingScanner) ScanFiles(dir string, _ domain.ScanOptions) (domain.ScanResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scans[dir]++
+	return domain.ScanResult{Files: []domain.FileInfo{}}, nil
+}
+
+func (s *countingScanner) count(dir string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scans[dir]
+}
+
+func TestRoots_SetRootsWhileRunning(t *testing.T) {
+	scanner := &countingScanner{scans: make(map[string]int)}
+	var mu sync.Mutex
+	baselines := make(map[string]int)
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", "Baseline snapshot captured", mock.Anything).Run(func(args mock.Arguments) {
+		mu.Lock()
+		defer mu.Unlock()
+		baselines[args.Get(1).([]interface{})[1].(string)]++
+	}).Maybe()
+	mockLogger.On("Info", mock.Anything, mock.Anything).Maybe()
+
+	workerAdapter := worker.NewAdapter(mockLogger, scanner, "/unused", 60, worker.WithRoots([]domain.WatchRoot{
+		{Path: "/kept", CheckFrequency: 20 * time.Millisecond},
+		{Path: "/removed", CheckFrequency: 20 * time.Millisecond},
+	}))
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+	require.Eventually(t, func() bool { return scanner.count("/kept") > 0 && scanner.count("/removed") > 0 }, 3*time.Second, 10*time.Millisecond)
+
+	workerAdapter.SetRoots([]domain.WatchRoot{
+		{Path: "/kept", CheckFrequency: 30 * time.Millisecond},
+		{Path: "/added", CheckFrequency: 20 * time.Millisecond},
+	})
+	removedScans := scanner.count("/removed")
+	keptScans := scanner.count("/kept")
+	require.Eventually(t, func() bool { return scanner.count("/added") > 0 && scanner.count("/kept") > keptScans+1 }, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, removedScans, scanner.count("/removed"))
+
+	mu.Lock()
+	assert.Equal(t, 1, baselines["/kept"], "a rescheduled root keeps its snapshot")
+	mu.Unlock()
+
+	statuses := workerAdapter.GetRootStatuses()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "/kept", statuses[0].Path)
+	assert.False(t, statuses[0].LastScan.IsZero())
+	assert.Equal(t, "/added", statuses[1].Path)
+}
@@ -0,0 +1,88 @@
+package worker_test
+
+import (
+	"file-mod-tracker/internal/adapters/worker"
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_RunsCommandsConcurrently(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses tail")
+	}
+	workerAdapter := newJobTestAdapter(worker.WithPoolSize(2), worker.WithDrainTimeout(0))
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	jobs, err := workerAdapter.EnqueueCommands([]string{"tail -f /dev/null", "tail -f /dev/null", "echo third"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	waitForState(t, workerAdapter, jobs[0].ID, domain.JobRunning)
+	waitForState(t, workerAdapter, jobs[1].ID, domain.JobRunning)
+
+	third, err := workerAdapter.GetJob(jobs[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobQueued, third.State, "both workers are busy")
+
+	require.NoError(t, workerAdapter.CancelJob(jobs[0].ID))
+	third = waitForJob(t, workerAdapter, jobs[2].ID)
+	assert.Equal(t, domain.JobSucceeded, third.State)
+}
+
+func TestPool_QueueLimitSerialisesJobsOfOneQueue(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses tail")
+	}
+	workerAdapter := newJobTestAdapter(
+		worker.WithPoolSize(4),
+		worker.WithQueueLimits([]worker.QueueLimit{{Pattern: "dir:*", Concurrency: 1}}),
+		worker.WithDrainTimeout(0),
+	)
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	first, err := workerAdapter.EnqueueCommands([]string{"tail -f /dev/null", "echo second"}, domain.EnqueueOptions{Queue: "dir:/srv/a"})
+	require.NoError(t, err)
+	other, err := workerAdapter.EnqueueCommands([]string{"echo other"}, domain.EnqueueOptions{Queue: "dir:/srv/b"})
+	require.NoError(t, err)
+	unlimited, err := workerAdapter.EnqueueCommands([]string{"echo unlimited"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+
+	waitForState(t, workerAdapter, first[0].ID, domain.JobRunning)
+	// Jobs of other queues overtake the one waiting for dir:/srv/a.
+	assert.Equal(t, domain.JobSucceeded, waitForJob(t, workerAdapter, other[0].ID).State)
+	assert.Equal(t, domain.JobSucceeded, waitForJob(t, workerAdapter, unlimited[0].ID).State)
+
+	second, err := workerAdapter.GetJob(first[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobQueued, second.State)
+	assert.Equal(t, "dir:/srv/a", second.Queue)
+
+	require.NoError(t, workerAdapter.CancelJob(first[0].ID))
+	assert.Equal(t, domain.JobSucceeded, waitForJob(t, workerAdapter, first[1].ID).State)
+}
+
+func TestPool_StopDrainsQueueBeforeTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	workerAdapter := newJobTestAdapter(worker.WithPoolSize(1), worker.WithDrainTimeout(5*time.Second))
+	workerAdapter.Start()
+
+	jobs, err := workerAdapter.EnqueueCommands([]string{"sleep 0.2", "echo after"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	workerAdapter.Stop()
+
+	for _, queued := range jobs {
+		job, err := workerAdapter.GetJob(queued.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.JobSucceeded, job.State, job.Command)
+	}
+	_, err = workerAdapter.EnqueueCommands([]string{"echo late"}, domain.EnqueueOptions{})
+	assert.ErrorIs(t, err, ports.ErrWorkerStopped)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package worker
+
+import "os/exec"
+
+// killProcessGroup keeps the default cancellation, which kills only the
+// command's own process. WaitDelay still stops the worker from waiting on
+// output held open by its children.
+func killProcessGroup(cmd *exec.Cmd) {}
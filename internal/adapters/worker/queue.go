@@ -1,10 +1,17 @@
 package worker
 
 import (
+	"context"
+	"errors"
+	"file-mod-tracker/internal/adapters/eventstore"
 	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/core/policy"
 	"file-mod-tracker/internal/ports"
 	"file-mod-tracker/pkg/logger"
+	"file-mod-tracker/pkg/shellwords"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
@@ -12,110 +19,560 @@ import (
 )
 
 type WorkerAdapter struct {
-	commandQueue     chan string
-	logger           logger.Logger
-	wg               sync.WaitGroup
-	stopChan         chan struct{}
-	osqueryAdapter   ports.OsqueryAdapter
-	monitoredDir     string
-	fileChanges      []domain.FileInfo
-	fileChangesMutex sync.Mutex
-	checkFrequency   int
-}
-
-func NewAdapter(logger logger.Logger, osqueryAdapter ports.OsqueryAdapter, monitoredDir string, specifiedFrequency int) *WorkerAdapter {
-	return &WorkerAdapter{
-		commandQueue:   make(chan string, 100),
+	scheduler      *scheduler
+	poolSize       int
+	queueLimits    []QueueLimit
+	drainTimeout   time.Duration
+	logger         logger.Logger
+	wg             sync.WaitGroup
+	workers        sync.WaitGroup
+	stopChan       chan struct{}
+	osqueryAdapter ports.OsqueryAdapter
+	eventStore     ports.EventStore
+	roots          []*rootMonitor
+	newWatcher     func(root domain.WatchRoot) (ports.FileWatcher, error)
+	hasher         ports.Hasher
+	reporter       ports.ChangeReporter
+	policy         ports.CommandPolicy
+	jobs           *jobRegistry
+	jobStore       ports.JobStore
+	requeue        RequeuePolicy
+	commandTimeout time.Duration
+	// lifecycle serialises Start, Stop, Pause and Resume.
+	lifecycle sync.Mutex
+	// mu guards the settings that can change while the adapter runs: the
+	// roots, the policy and the default command timeout, and its state.
+	mu      sync.Mutex
+	running bool
+	paused  bool
+	stopped bool
+	// ctx is cancelled by Stop to kill running commands.
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+const (
+	defaultCommandTimeout = 5 * time.Minute
+	defaultPoolSize       = 4
+	defaultDrainTimeout   = 30 * time.Second
+	// waitDelay bounds how long a killed command's output is waited for.
+	waitDelay = 2 * time.Second
+)
+
+var (
+	errJobCancelled = errors.New("cancelled by request")
+	errJobTimedOut  = errors.New("timed out")
+	errStopping     = errors.New("worker stopped")
+)
+
+// RequeuePolicy decides what happens to interrupted jobs when the worker
+// restarts.
+type RequeuePolicy struct {
+	// Requeue queues interrupted jobs again instead of leaving them
+	// interrupted.
+	Requeue bool
+	// MaxAttempts caps how often a job is started. Zero means no limit.
+	MaxAttempts int
+}
+
+func (p RequeuePolicy) allows(job domain.Job) bool {
+	return p.Requeue && (p.MaxAttempts <= 0 || job.Attempts < p.MaxAttempts)
+}
+
+// Option customises a WorkerAdapter at construction time.
+type Option func(*WorkerAdapter)
+
+// WithEventStore records detected changes in store instead of the default
+// in-memory store.
+func WithEventStore(store ports.EventStore) Option {
+	return func(a *WorkerAdapter) {
+		a.eventStore = store
+	}
+}
+
+// WithRoots monitors the given roots instead of the single directory passed
+// to NewAdapter. Every root is scheduled and reported on independently.
+func WithRoots(roots []domain.WatchRoot) Option {
+	return func(a *WorkerAdapter) {
+		a.roots = nil
+		for _, root := range roots {
+			a.roots = append(a.roots, newRootMonitor(root))
+		}
+	}
+}
+
+// WithWatcherFactory supplies the filesystem watchers used by roots whose
+// watch mode is fsnotify. Each such root gets its own watcher and falls back
+// to polling on its own if the watcher cannot cover its tree.
+func WithWatcherFactory(newWatcher func(root domain.WatchRoot) (ports.FileWatcher, error)) Option {
+	return func(a *WorkerAdapter) {
+		a.newWatcher = newWatcher
+	}
+}
+
+// WithHasher computes content hashes for roots that have hash algorithms
+// configured, so that edits which keep the size and modification time are
+// still detected.
+func WithHasher(hasher ports.Hasher) Option {
+	return func(a *WorkerAdapter) {
+		a.hasher = hasher
+	}
+}
+
+// WithReporter forwards every recorded change event to reporter.
+func WithReporter(reporter ports.ChangeReporter) Option {
+	return func(a *WorkerAdapter) {
+		a.reporter = reporter
+	}
+}
+
+// WithPolicy replaces the default command policy, policy.Default.
+func WithPolicy(policy ports.CommandPolicy) Option {
+	return func(a *WorkerAdapter) {
+		a.policy = policy
+	}
+}
+
+// WithCommandTimeout sets how long a command may run unless its job asks
+// for a different timeout. The default is five minutes.
+func WithCommandTimeout(timeout time.Duration) Option {
+	return func(a *WorkerAdapter) {
+		a.commandTimeout = timeout
+	}
+}
+
+// WithJobStore persists the command queue in store. Jobs found in the store
+// are restored when the adapter is created: queued jobs are queued again, and
+// jobs that were running are marked interrupted and handled according to the
+// requeue policy. Jobs still queued when Stop gives up on draining are left
+// queued for the next start instead of being cancelled.
+func WithJobStore(store ports.JobStore) Option {
+	return func(a *WorkerAdapter) {
+		a.jobStore = store
+	}
+}
+
+// WithRequeuePolicy decides whether interrupted jobs run again after a
+// restart. By default they are left interrupted.
+func WithRequeuePolicy(policy RequeuePolicy) Option {
+	return func(a *WorkerAdapter) {
+		a.requeue = policy
+	}
+}
+
+// WithPoolSize sets how many commands may run at once. The default is four.
+func WithPoolSize(size int) Option {
+	return func(a *WorkerAdapter) {
+		a.poolSize = size
+	}
+}
+
+// WithQueueLimits caps how many jobs of the matching queues run at once.
+// The first limit whose pattern matches a job's queue applies; jobs without
+// a queue, or whose queue matches no limit, are only bounded by the pool.
+func WithQueueLimits(limits []QueueLimit) Option {
+	return func(a *WorkerAdapter) {
+		a.queueLimits = limits
+	}
+}
+
+// WithDrainTimeout sets how long Stop waits for queued and running commands
+// to finish before killing them. The default is thirty seconds.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(a *WorkerAdapter) {
+		a.drainTimeout = timeout
+	}
+}
+
+func NewAdapter(logger logger.Logger, osqueryAdapter ports.OsqueryAdapter, monitoredDir string, specifiedFrequency int, opts ...Option) *WorkerAdapter {
+	a := &WorkerAdapter{
+		jobs:           newJobRegistry(logger),
+		commandTimeout: defaultCommandTimeout,
+		poolSize:       defaultPoolSize,
+		drainTimeout:   defaultDrainTimeout,
 		logger:         logger,
 		stopChan:       make(chan struct{}),
 		osqueryAdapter: osqueryAdapter,
-		monitoredDir:   monitoredDir,
-		checkFrequency: specifiedFrequency,
+		roots: []*rootMonitor{newRootMonitor(domain.WatchRoot{
+			Path:           monitoredDir,
+			CheckFrequency: time.Duration(specifiedFrequency) * time.Second,
+			WatchMode:      domain.WatchModePoll,
+		})},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.eventStore == nil {
+		a.eventStore = eventstore.NewMemoryStore(0)
+	}
+	if a.policy == nil {
+		a.policy = policy.Default()
+	}
+	if a.poolSize < 1 {
+		a.poolSize = 1
+	}
+	a.scheduler = newScheduler(a.queueLimits)
+	a.jobs.store = a.jobStore
+	a.restoreJobs()
+	a.ctx, a.cancel = context.WithCancelCause(context.Background())
+	return a
+}
+
+// EnqueueCommands queues commands for execution and returns a job for each.
+// Nothing is queued if the policy rejects any of them. When the queue fills
+// up, the jobs queued so far are returned together with ports.ErrQueueFull;
+// after Stop, ports.ErrWorkerStopped is returned.
+// Command lines are split into arguments with POSIX shell quoting rules.
+func (a *WorkerAdapter) EnqueueCommands(commands []string, opts domain.EnqueueOptions) ([]domain.Job, error) {
+	specs := make([]domain.CommandSpec, len(commands))
+	for i, cmd := range commands {
+		argv, err := shellwords.Split(cmd)
+		if err != nil {
+			a.logger.Error("Command rejected", "command", cmd, "error", err)
+			return nil, &ports.CommandRejectedError{Command: cmd, Reason: err.Error()}
+		}
+		specs[i] = domain.CommandSpec{Argv: argv}
+	}
+	return a.enqueue(commands, specs, opts)
+}
+
+// EnqueueJobs is EnqueueCommands for commands that are already split into
+// arguments and may carry an environment, working directory and input.
+func (a *WorkerAdapter) EnqueueJobs(specs []domain.CommandSpec, opts domain.EnqueueOptions) ([]domain.Job, error) {
+	commands := make([]string, len(specs))
+	for i, spec := range specs {
+		commands[i] = shellwords.Join(spec.Argv)
+	}
+	return a.enqueue(commands, specs, opts)
+}
+
+func (a *WorkerAdapter) enqueue(commands []string, specs []domain.CommandSpec, opts domain.EnqueueOptions) ([]domain.Job, error) {
+	a.mu.Lock()
+	policy, timeout := a.policy, a.commandTimeout
+	a.mu.Unlock()
+	for i, cmd := range commands {
+		if _, err := policy.Check(specs[i]); err != nil {
+			a.logger.Error("Command rejected", "command", cmd, "error", err)
+			return nil, err
+		}
+	}
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+	jobs := make([]domain.Job, 0, len(commands))
+	for i, cmd := range commands {
+		job, err := a.jobs.add(cmd, specs[i], timeout, opts.Queue)
+		if err != nil {
+			a.logger.Error("Failed to persist job", "command", cmd, "error", err)
+			return jobs, fmt.Errorf("persist job: %w", err)
+		}
+		if err := a.scheduler.push(job.ID, job.Queue); err != nil {
+			a.jobs.remove(job.ID)
+			if errors.Is(err, ports.ErrQueueFull) {
+				a.logger.Error("Command queue is full", "command", cmd)
+			} else {
+				a.logger.Error("Failed to enqueue command", "command", cmd, "error", err)
+			}
+			return jobs, err
+		}
+		a.logger.Info("Command enqueued", "command", cmd)
+		jobs = append(jobs, job)
 	}
+	return jobs, nil
 }
 
-func (a *WorkerAdapter) EnqueueCommands(commands []string) error {
-	for _, cmd := range commands {
-		select {
-		case a.commandQueue <- cmd:
-			a.logger.Info("Command enqueued", "command", cmd)
-		default:
-			a.logger.Error("Command queue is full", "command", cmd)
-			return fmt.Errorf("command queue is full")
+// restoreJobs registers the jobs found in the job store.
+func (a *WorkerAdapter) restoreJobs() {
+	if a.jobStore == nil {
+		return
+	}
+	for _, stored := range a.jobStore.Jobs() {
+		job := stored
+		if job.State == domain.JobRunning {
+			// The process running it died with the previous worker.
+			job.State = domain.JobInterrupted
+			job.Error = "interrupted by restart"
+			job.FinishedAt = time.Now()
+		}
+		if job.StdinBytes > len(job.Stdin) {
+			// The store does not keep standard input, so the job cannot
+			// run as it was submitted.
+			if job.State == domain.JobQueued {
+				job.State = domain.JobInterrupted
+				job.Error = "standard input is not kept across restarts"
+				job.FinishedAt = time.Now()
+			}
+		} else if job.State == domain.JobInterrupted && a.requeue.allows(job) {
+			a.logger.Info("Requeueing interrupted job", "job", job.ID, "command", job.Command, "attempts", job.Attempts)
+			job.State = domain.JobQueued
+			job.ExitCode = nil
+			job.Error = ""
+			job.StartedAt = time.Time{}
+			job.FinishedAt = time.Time{}
+		}
+		a.jobs.restore(job, job.State != stored.State)
+		if job.State == domain.JobQueued {
+			a.scheduler.restore(job.ID, job.Queue)
 		}
 	}
-	return nil
 }
 
+// GetJobs lists the known jobs in the order they were enqueued.
+func (a *WorkerAdapter) GetJobs() []domain.Job {
+	return a.jobs.list()
+}
+
+func (a *WorkerAdapter) GetJob(id string) (domain.Job, error) {
+	return a.jobs.get(id)
+}
+
+// CancelJob cancels a queued job, or kills the process group of a running
+// one. A running job is marked cancelled once its process has exited.
+func (a *WorkerAdapter) CancelJob(id string) error {
+	return a.jobs.cancel(id)
+}
+
+// GetJobOutput returns what the job has written so far.
+func (a *WorkerAdapter) GetJobOutput(id string) (domain.JobOutput, error) {
+	return a.jobs.output(id)
+}
+
+// Start starts the worker pool and the root monitors. An adapter can be
+// started again after Stop: jobs a job store kept queued run then, and every
+// root compares its first scan with the snapshot it had when it stopped.
 func (a *WorkerAdapter) Start() {
-	a.wg.Add(2)
-	go a.workerThread()
-	go a.timerThread()
+	a.lifecycle.Lock()
+	defer a.lifecycle.Unlock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.running {
+		return
+	}
+	if a.stopped {
+		a.stopChan = make(chan struct{})
+		a.ctx, a.cancel = context.WithCancelCause(context.Background())
+		a.scheduler.reopen()
+		for _, job := range a.jobs.list() {
+			if job.State == domain.JobQueued {
+				a.scheduler.restore(job.ID, job.Queue)
+			}
+		}
+		a.resetRoots()
+		a.stopped = false
+	}
+	a.running = true
+
+	a.workers.Add(a.poolSize)
+	for i := 0; i < a.poolSize; i++ {
+		go a.workerThread()
+	}
+	a.startRoots(a.roots)
+}
+
+// Pause stops scanning and starting commands until Resume is called.
+// Running commands carry on, and commands can still be queued. Changes made
+// while paused are detected by the first scan after Resume.
+func (a *WorkerAdapter) Pause() {
+	a.lifecycle.Lock()
+	defer a.lifecycle.Unlock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.running || a.paused {
+		return
+	}
+	a.scheduler.pause()
+	for _, m := range a.roots {
+		a.stopRoot(m)
+	}
+	a.paused = true
+	a.resetRoots()
+	a.logger.Info("Worker paused")
+}
+
+// Resume undoes Pause.
+func (a *WorkerAdapter) Resume() {
+	a.lifecycle.Lock()
+	defer a.lifecycle.Unlock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.running || !a.paused {
+		return
+	}
+	a.paused = false
+	a.scheduler.resume()
+	a.startRoots(a.roots)
+	a.logger.Info("Worker resumed")
+}
+
+// SetPolicy replaces the command policy. Queued commands are checked
+// against the new policy before they run.
+func (a *WorkerAdapter) SetPolicy(policy ports.CommandPolicy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.policy = policy
+}
+
+// SetCommandTimeout changes the timeout of commands enqueued from now on
+// that do not ask for their own. Zero restores the default.
+func (a *WorkerAdapter) SetCommandTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.commandTimeout = timeout
 }
 
+// Stop stops accepting commands and lets the pool work through the queue
+// until the drain timeout passes; a paused pool starts no more commands.
+// Commands still running then are killed and marked interrupted. Those still
+// queued are marked cancelled, unless a job store keeps them for the next
+// start.
 func (a *WorkerAdapter) Stop() {
+	a.lifecycle.Lock()
+	defer a.lifecycle.Unlock()
+	a.mu.Lock()
+	if a.stopped {
+		a.mu.Unlock()
+		return
+	}
+	a.stopped = true
+	a.mu.Unlock()
+
 	close(a.stopChan)
+	a.scheduler.close()
+
+	drained := make(chan struct{})
+	go func() {
+		a.workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(a.drainTimeout):
+		a.logger.Error("Drain timeout passed, cancelling remaining commands", "timeout", a.drainTimeout)
+	}
+	for _, id := range a.scheduler.abort() {
+		if a.jobStore != nil {
+			continue
+		}
+		a.jobs.update(id, func(job *domain.Job) {
+			if job.State == domain.JobQueued {
+				job.State = domain.JobCancelled
+				job.FinishedAt = time.Now()
+			}
+		})
+	}
+	a.cancel(errStopping)
+	<-drained
 	a.wg.Wait()
+	a.mu.Lock()
+	a.running = false
+	a.paused = false
+	a.mu.Unlock()
 }
 
 func (a *WorkerAdapter) workerThread() {
-	defer a.wg.Done()
+	defer a.workers.Done()
 	for {
-		select {
-		case cmd := <-a.commandQueue:
-			a.logger.Info("Executing command", "command", cmd)
-			parts := strings.Fields(cmd)
-			if len(parts) == 0 {
-				a.logger.Error("Empty command received")
-				continue
-			}
-			command := exec.Command(parts[0], parts[1:]...)
-			output, err := command.CombinedOutput()
-			if err != nil {
-				a.logger.Error("Command execution failed", "command", cmd, "error", err, "output", string(output))
-			} else {
-				a.logger.Info("Command executed successfully", "command", cmd, "output", string(output))
-			}
-		case <-a.stopChan:
+		job, ok := a.scheduler.next()
+		if !ok {
 			return
 		}
+		a.runJob(job.id)
+		a.scheduler.done(job)
 	}
 }
 
-func (a *WorkerAdapter) timerThread() {
-	defer a.wg.Done()
-	ticker := time.NewTicker(time.Duration(a.checkFrequency) * time.Second)
-	defer ticker.Stop()
+// runJob executes a queued job and records its outcome.
+func (a *WorkerAdapter) runJob(id string) {
+	record, ok := a.jobs.record(id)
+	if !ok {
+		return
+	}
+	cmd := record.job.Command
+	timeout := time.Duration(record.job.TimeoutMillis) * time.Millisecond
 
-	for {
-		select {
-		case <-ticker.C:
-			a.logger.Info("Timer thread woke up")
-			stats, err := a.osqueryAdapter.GetFileStats(a.monitoredDir)
-			if err != nil {
-				a.logger.Error("Failed to get file stats", "error", err)
-				continue
-			}
-			a.updateFileChanges(stats)
-		case <-a.stopChan:
-			return
-		}
+	ctx, cancel := context.WithCancelCause(a.ctx)
+	defer cancel(nil)
+	ctx, cancelTimeout := context.WithTimeoutCause(ctx, timeout, errJobTimedOut)
+	defer cancelTimeout()
+	if !a.jobs.start(id, cancel) {
+		// Cancelled while it waited in the queue.
+		return
 	}
-}
+	a.logger.Info("Executing command", "command", cmd)
 
-func (a *WorkerAdapter) updateFileChanges(newStats []domain.FileInfo) {
-	a.fileChangesMutex.Lock()
-	defer a.fileChangesMutex.Unlock()
+	finish := func(state domain.JobState, exitCode *int, errMsg string) {
+		a.jobs.update(id, func(job *domain.Job) {
+			job.State = state
+			job.ExitCode = exitCode
+			job.Error = errMsg
+			job.FinishedAt = time.Now()
+		})
+	}
 
-	// Simple implementation: just store the latest stats
-	a.fileChanges = newStats
-	for _, stat := range newStats {
-		a.logger.Info("File info", "path", stat.Path, "lastModified", stat.LastModified, "size", stat.Size)
+	spec := record.job.CommandSpec
+	if len(spec.Argv) == 0 {
+		a.logger.Error("Empty command received")
+		finish(domain.JobFailed, nil, "empty command")
+		return
+	}
+	// The policy may have changed since the command was queued.
+	a.mu.Lock()
+	policy := a.policy
+	a.mu.Unlock()
+	executable, err := policy.Check(spec)
+	if err != nil {
+		a.logger.Error("Command rejected", "command", cmd, "error", err)
+		finish(domain.JobFailed, nil, err.Error())
+		return
 	}
-}
 
-func (a *WorkerAdapter) GetFileChanges() []domain.FileInfo {
-	a.fileChangesMutex.Lock()
-	defer a.fileChangesMutex.Unlock()
-	return a.fileChanges
+	var combined outputBuffer
+	command := exec.CommandContext(ctx, executable, spec.Argv[1:]...)
+	command.Dir = spec.Cwd
+	if len(spec.Env) > 0 {
+		command.Env = os.Environ()
+		for name, value := range spec.Env {
+			command.Env = append(command.Env, name+"="+value)
+		}
+	}
+	if spec.Stdin != "" {
+		command.Stdin = strings.NewReader(spec.Stdin)
+	}
+	command.Stdout = io.MultiWriter(&record.stdout, &combined)
+	command.Stderr = io.MultiWriter(&record.stderr, &combined)
+	// Kill everything the command started, and stop waiting for output
+	// from descendants that outlive it.
+	killProcessGroup(command)
+	command.WaitDelay = waitDelay
+
+	err = command.Run()
+	output, _ := combined.contents()
+
+	var exitErr *exec.ExitError
+	cause := context.Cause(ctx)
+	switch {
+	case err == nil:
+		exitCode := 0
+		finish(domain.JobSucceeded, &exitCode, "")
+		a.logger.Info("Command executed successfully", "command", cmd, "output", output)
+	case errors.Is(cause, errJobTimedOut):
+		finish(domain.JobFailed, nil, fmt.Sprintf("timed out after %s", timeout))
+		a.logger.Error("Command timed out", "command", cmd, "timeout", timeout, "output", output)
+	case errors.Is(cause, errStopping):
+		finish(domain.JobInterrupted, nil, cause.Error())
+		a.logger.Error("Command interrupted", "command", cmd, "reason", cause, "output", output)
+	case cause != nil:
+		finish(domain.JobCancelled, nil, cause.Error())
+		a.logger.Error("Command cancelled", "command", cmd, "reason", cause, "output", output)
+	case errors.As(err, &exitErr):
+		exitCode := exitErr.ExitCode()
+		finish(domain.JobFailed, &exitCode, err.Error())
+		a.logger.Error("Command execution failed", "command", cmd, "error", err, "output", output)
+	default:
+		finish(domain.JobFailed, nil, err.Error())
+		a.logger.Error("Command execution failed", "command", cmd, "error", err, "output", output)
+	}
 }
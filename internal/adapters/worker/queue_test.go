@@ -3,11 +3,15 @@ package worker_test
 import (
 	"file-mod-tracker/internal/adapters/worker"
 	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockOsqueryAdapter mocks the OsqueryAdapter interface
@@ -20,6 +24,11 @@ func (m *mockOsqueryAdapter) GetFileStats(directory string) ([]domain.FileInfo,
 	return args.Get(0).([]domain.FileInfo), args.Error(1)
 }
 
+func (m *mockOsqueryAdapter) ScanFiles(directory string, opts domain.ScanOptions) (domain.ScanResult, error) {
+	args := m.Called(directory, opts)
+	return args.Get(0).(domain.ScanResult), args.Error(1)
+}
+
 // MockLogger mocks the Logger interface
 type mockLogger struct {
 	mock.Mock
@@ -41,11 +50,11 @@ func TestEnqueueCommands_Success(t *testing.T) {
 	mockOsquery := new(mockOsqueryAdapter)
 	mockLogger := new(mockLogger)
 
-	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/test", 5)
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/test", 5, allowCommands("echo"))
 
 	mockLogger.On("Info", "Command enqueued", []interface{}{"command", "echo test"}).Once()
 
-	err := workerAdapter.EnqueueCommands([]string{"echo test"})
+	_, err := workerAdapter.EnqueueCommands([]string{"echo test"}, domain.EnqueueOptions{})
 	assert.NoError(t, err)
 	mockLogger.AssertExpectations(t)
 }
@@ -54,18 +63,18 @@ func TestEnqueueCommands_QueueFull(t *testing.T) {
 	mockOsquery := new(mockOsqueryAdapter)
 	mockLogger := new(mockLogger)
 
-	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/test", 5)
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/test", 5, allowCommands("echo"))
 
 	// Fill up the queue
 	for i := 0; i < 100; i++ {
 		mockLogger.On("Info", "Command enqueued", []interface{}{"command", "echo test"}).Once()
-		workerAdapter.EnqueueCommands([]string{"echo test"})
+		workerAdapter.EnqueueCommands([]string{"echo test"}, domain.EnqueueOptions{})
 	}
 
 	// Expect the error log when the queue is full
 	mockLogger.On("Error", "Command queue is full", []interface{}{"command", "echo test"}).Once()
 
-	err := workerAdapter.EnqueueCommands([]string{"echo test"})
+	_, err := workerAdapter.EnqueueCommands([]string{"echo test"}, domain.EnqueueOptions{})
 	assert.EqualError(t, err, "command queue is full")
 	mockLogger.AssertExpectations(t)
 }
@@ -74,16 +83,71 @@ func TestWorkerThread_ExecutesCommandSuccessfully(t *testing.T) {
 	mockOsquery := new(mockOsqueryAdapter)
 	mockLogger := new(mockLogger)
 
-	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/test", 5)
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/test", 5, allowCommands("echo"))
 
 	mockLogger.On("Info", "Command enqueued", []interface{}{"command", "echo test"}).Once()
 	mockLogger.On("Info", "Executing command", []interface{}{"command", "echo test"}).Once()
 	mockLogger.On("Info", "Command executed successfully", []interface{}{"command", "echo test", "output", "test\n"}).Once()
 
-	workerAdapter.EnqueueCommands([]string{"echo test"})
+	workerAdapter.EnqueueCommands([]string{"echo test"}, domain.EnqueueOptions{})
 	go workerAdapter.Start()
 	time.Sleep(500 * time.Millisecond) // Allow some time for the goroutine to execute
 	workerAdapter.Stop()
 
 	mockLogger.AssertExpectations(t)
 }
+
+func TestEnqueueCommands_RejectedByPolicy(t *testing.T) {
+	mockOsquery := new(mockOsqueryAdapter)
+	mockLogger := new(mockLogger)
+
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/test", 5, allowCommands("echo"))
+
+	mockLogger.On("Error", "Command rejected", mock.Anything).Once()
+
+	_, err := workerAdapter.EnqueueCommands([]string{"echo test", "rm -rf /tmp/data"}, domain.EnqueueOptions{})
+
+	var rejected *ports.CommandRejectedError
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, "rm -rf /tmp/data", rejected.Command)
+	assert.Contains(t, rejected.Reason, `"rm" is denied`)
+	mockLogger.AssertExpectations(t)
+}
+
+// revokingPolicy allows a command once and rejects it afterwards.
+type revokingPolicy struct {
+	checks int32
+}
+
+func (p *revokingPolicy) Check(cmd domain.CommandSpec) (string, error) {
+	if atomic.AddInt32(&p.checks, 1) > 1 {
+		return "", &ports.CommandRejectedError{Command: strings.Join(cmd.Argv, " "), Reason: "revoked"}
+	}
+	return cmd.Argv[0], nil
+}
+
+func TestWorkerThread_RechecksPolicyBeforeExecuting(t *testing.T) {
+	mockOsquery := new(mockOsqueryAdapter)
+	mockOsquery.On("ScanFiles", mock.Anything, mock.Anything).Return(domain.ScanResult{}, nil).Maybe()
+	mockLogger := new(mockLogger)
+	commandPolicy := &revokingPolicy{}
+
+	workerAdapter := worker.NewAdapter(mockLogger, mockOsquery, "/test", 60, worker.WithPolicy(commandPolicy))
+
+	mockLogger.On("Info", "Command enqueued", []interface{}{"command", "echo test"}).Once()
+	mockLogger.On("Info", "Executing command", []interface{}{"command", "echo test"}).Once()
+	rejected := make(chan struct{})
+	mockLogger.On("Error", "Command rejected", mock.Anything).Once().Run(func(mock.Arguments) { close(rejected) })
+
+	_, err := workerAdapter.EnqueueCommands([]string{"echo test"}, domain.EnqueueOptions{})
+	require.NoError(t, err)
+	workerAdapter.Start()
+	defer workerAdapter.Stop()
+
+	select {
+	case <-rejected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the command to be rejected at execution time")
+	}
+	mockLogger.AssertNotCalled(t, "Info", "Command executed successfully", mock.Anything)
+}
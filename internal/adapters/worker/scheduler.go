@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"strings"
+	"sync"
+
+	"file-mod-tracker/internal/ports"
+)
+
+// maxPendingJobs caps how many jobs may wait for a worker.
+const maxPendingJobs = 100
+
+// QueueLimit caps how many jobs of matching queues run at once. A Pattern
+// ending in "*" matches every queue name with that prefix, and each matching
+// queue is limited separately, so {"dir:*", 1} allows one job per target
+// directory when jobs are queued as "dir:<path>".
+type QueueLimit struct {
+	Pattern     string
+	Concurrency int
+}
+
+type pendingJob struct {
+	id    string
+	queue string
+}
+
+// scheduler hands queued jobs to the worker pool in order, skipping jobs
+// whose queue is at its concurrency limit until one of its jobs finishes.
+type scheduler struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []pendingJob
+	running map[string]int
+	limits  []QueueLimit
+	closed  bool
+	aborted bool
+	paused  bool
+}
+
+func newScheduler(limits []QueueLimit) *scheduler {
+	s := &scheduler{running: make(map[string]int), limits: limits}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// push queues a job unless the scheduler is full or closed.
+func (s *scheduler) push(id, queue string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ports.ErrWorkerStopped
+	}
+	if len(s.pending) >= maxPendingJobs {
+		return ports.ErrQueueFull
+	}
+	s.pending = append(s.pending, pendingJob{id: id, queue: queue})
+	s.cond.Signal()
+	return nil
+}
+
+// restore queues a job replayed from the job store. Replayed jobs were
+// accepted before, so the pending limit does not apply to them.
+func (s *scheduler) restore(id, queue string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, pendingJob{id: id, queue: queue})
+}
+
+// next blocks until a job may run and returns it. It returns false once the
+// scheduler is closed and drained, or aborted. A closed scheduler that is
+// paused hands out no more jobs.
+func (s *scheduler) next() (pendingJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if s.aborted || (s.closed && s.paused) {
+			return pendingJob{}, false
+		}
+		if s.paused {
+			s.cond.Wait()
+			continue
+		}
+		for i, job := range s.pending {
+			if s.hasRoom(job.queue) {
+				s.pending = append(s.pending[:i], s.pending[i+1:]...)
+				s.running[job.queue]++
+				return job, true
+			}
+		}
+		if s.closed && len(s.pending) == 0 {
+			return pendingJob{}, false
+		}
+		s.cond.Wait()
+	}
+}
+
+// done releases the queue slot taken by job.
+func (s *scheduler) done(job pendingJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[job.queue]--; s.running[job.queue] <= 0 {
+		delete(s.running, job.queue)
+	}
+	s.cond.Broadcast()
+}
+
+// close stops accepting jobs; the pool keeps draining what is pending.
+func (s *scheduler) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.cond.Broadcast()
+}
+
+// abort stops handing out jobs and returns the IDs still pending.
+func (s *scheduler) abort() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.aborted = true
+	s.cond.Broadcast()
+	ids := make([]string, 0, len(s.pending))
+	for _, job := range s.pending {
+		ids = append(ids, job.id)
+	}
+	s.pending = nil
+	return ids
+}
+
+// pause stops handing out jobs until resume is called. Jobs can still be
+// queued.
+func (s *scheduler) pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = true
+}
+
+func (s *scheduler) resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = false
+	s.cond.Broadcast()
+}
+
+// reopen accepts jobs again after close or abort. The queue starts out
+// empty.
+func (s *scheduler) reopen() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = false
+	s.aborted = false
+	s.paused = false
+	s.pending = nil
+}
+
+// hasRoom reports whether another job of queue may start. The caller must
+// hold s.mu.
+func (s *scheduler) hasRoom(queue string) bool {
+	if queue == "" {
+		return true
+	}
+	for _, limit := range s.limits {
+		if matchQueue(limit.Pattern, queue) {
+			return s.running[queue] < limit.Concurrency
+		}
+	}
+	return true
+}
+
+func matchQueue(pattern, queue string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(queue, prefix)
+	}
+	return pattern == queue
+}
@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+// ChangeType identifies what happened to a file between two scans.
+type ChangeType string
+
+const (
+	ChangeCreated     ChangeType = "created"
+	ChangeModified    ChangeType = "modified"
+	ChangeDeleted     ChangeType = "deleted"
+	ChangeSizeChanged ChangeType = "size_changed"
+	// ChangePermissions reports a change of the permission bits (chmod).
+	ChangePermissions ChangeType = "permissions_changed"
+	// ChangeOwnership reports a change of the owning user or group (chown).
+	ChangeOwnership ChangeType = "ownership_changed"
+)
+
+// ChangeEvent records a single detected change. Old is nil for created files
+// and New is nil for deleted files. Seq is assigned by the event store when
+// the event is appended.
+type ChangeEvent struct {
+	Seq uint64 `json:",omitempty"`
+	// Root is the watch root the changed file belongs to.
+	Root       string `json:",omitempty"`
+	Type       ChangeType
+	Path       string
+	Old        *FileInfo `json:",omitempty"`
+	New        *FileInfo `json:",omitempty"`
+	DetectedAt time.Time
+}
+
+// DiffSnapshots compares two scans of the same tree and returns one event per
+// kind of change for every path that differs, ordered by path. A file that
+// was both edited and chmod-ed yields a content event and a permissions
+// event.
+func DiffSnapshots(previous, current []FileInfo, detectedAt time.Time) []ChangeEvent {
+	before := make(map[string]FileInfo, len(previous))
+	for _, info := range previous {
+		before[info.Path] = info
+	}
+
+	var events []ChangeEvent
+	seen := make(map[string]bool, len(current))
+	for _, info := range current {
+		seen[info.Path] = true
+		newInfo := info
+		oldInfo, existed := before[info.Path]
+		if !existed {
+			events = append(events, ChangeEvent{Type: ChangeCreated, Path: info.Path, New: &newInfo, DetectedAt: detectedAt})
+			continue
+		}
+		for _, changeType := range compareFileInfo(oldInfo, newInfo) {
+			events = append(events, ChangeEvent{Type: changeType, Path: info.Path, Old: &oldInfo, New: &newInfo, DetectedAt: detectedAt})
+		}
+	}
+
+	for _, info := range previous {
+		if seen[info.Path] {
+			continue
+		}
+		oldInfo := info
+		events = append(events, ChangeEvent{Type: ChangeDeleted, Path: info.Path, Old: &oldInfo, DetectedAt: detectedAt})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Path < events[j].Path
+	})
+	return events
+}
+
+// compareFileInfo lists the changes between two versions of the same file:
+// at most one content change followed by permission and ownership changes.
+// Content hashes are compared whenever both versions carry the same
+// algorithm, so an edit that keeps the size and restores the modification
+// time is still reported. Access times are ignored since reading a file
+// changes them.
+func compareFileInfo(oldInfo, newInfo FileInfo) []ChangeType {
+	var changes []ChangeType
+	switch {
+	case oldInfo.Size != newInfo.Size:
+		changes = append(changes, ChangeSizeChanged)
+	case contentChanged(oldInfo.Hashes, newInfo.Hashes), !sameInstant(oldInfo.LastModified, newInfo.LastModified):
+		changes = append(changes, ChangeModified)
+	}
+	if oldInfo.Mode != newInfo.Mode {
+		changes = append(changes, ChangePermissions)
+	}
+	if oldInfo.UID != newInfo.UID || oldInfo.GID != newInfo.GID {
+		changes = append(changes, ChangeOwnership)
+	}
+	return changes
+}
+
+// sameInstant compares two timestamps at the precision of the coarser one,
+// so a scan that only reports whole seconds (osquery) does not flag every
+// file as modified against a scan with nanosecond timestamps.
+func sameInstant(a, b time.Time) bool {
+	if a.Nanosecond() == 0 || b.Nanosecond() == 0 {
+		return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+	}
+	return a.Equal(b)
+}
+
+func contentChanged(oldHashes, newHashes map[string]string) bool {
+	for algorithm, oldDigest := range oldHashes {
+		if newDigest, ok := newHashes[algorithm]; ok && newDigest != oldDigest {
+			return true
+		}
+	}
+	return false
+}
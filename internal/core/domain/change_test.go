@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	tenAM    = time.Date(2024, 9, 23, 10, 0, 0, 0, time.UTC)
+	elevenAM = time.Date(2024, 9, 23, 11, 0, 0, 0, time.UTC)
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	now := time.Date(2024, 9, 23, 12, 0, 0, 0, time.UTC)
+	previous := []FileInfo{
+		{Path: "/test/deleted.txt", LastModified: tenAM, Size: 10},
+		{Path: "/test/grown.txt", LastModified: tenAM, Size: 10},
+		{Path: "/test/touched.txt", LastModified: tenAM, Size: 10},
+		{Path: "/test/unchanged.txt", LastModified: tenAM, Size: 10},
+	}
+	current := []FileInfo{
+		{Path: "/test/created.txt", LastModified: elevenAM, Size: 5},
+		{Path: "/test/grown.txt", LastModified: elevenAM, Size: 20},
+		{Path: "/test/touched.txt", LastModified: elevenAM, Size: 10},
+		{Path: "/test/unchanged.txt", LastModified: tenAM, Size: 10},
+	}
+
+	events := DiffSnapshots(previous, current, now)
+
+	assert.Len(t, events, 4)
+	assert.Equal(t, ChangeCreated, events[0].Type)
+	assert.Equal(t, "/test/created.txt", events[0].Path)
+	assert.Nil(t, events[0].Old)
+	assert.Equal(t, int64(5), events[0].New.Size)
+
+	assert.Equal(t, ChangeDeleted, events[1].Type)
+	assert.Equal(t, "/test/deleted.txt", events[1].Path)
+	assert.Nil(t, events[1].New)
+
+	assert.Equal(t, ChangeSizeChanged, events[2].Type)
+	assert.Equal(t, int64(10), events[2].Old.Size)
+	assert.Equal(t, int64(20), events[2].New.Size)
+
+	assert.Equal(t, ChangeModified, events[3].Type)
+	assert.Equal(t, "/test/touched.txt", events[3].Path)
+	assert.Equal(t, now, events[3].DetectedAt)
+}
+
+func TestDiffSnapshots_NoChanges(t *testing.T) {
+	snapshot := []FileInfo{
+		{Path: "/test/file1.txt", LastModified: tenAM, Size: 10},
+	}
+
+	assert.Empty(t, DiffSnapshots(snapshot, snapshot, time.Now()))
+}
+
+func TestDiffSnapshots_DetectsContentChange(t *testing.T) {
+	previous := []FileInfo{
+		{Path: "/test/file1.txt", LastModified: tenAM, Size: 10, Hashes: map[string]string{HashSHA256: "aaa"}},
+		{Path: "/test/file2.txt", LastModified: tenAM, Size: 10, Hashes: map[string]string{HashSHA256: "bbb"}},
+	}
+	current := []FileInfo{
+		{Path: "/test/file1.txt", LastModified: tenAM, Size: 10, Hashes: map[string]string{HashSHA256: "ccc"}},
+		{Path: "/test/file2.txt", LastModified: tenAM, Size: 10, Hashes: map[string]string{HashXXHash: "ddd"}},
+	}
+
+	events := DiffSnapshots(previous, current, time.Now())
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, ChangeModified, events[0].Type)
+	assert.Equal(t, "/test/file1.txt", events[0].Path)
+}
+
+func TestDiffSnapshots_ReportsPermissionAndOwnershipChanges(t *testing.T) {
+	previous := []FileInfo{
+		{Path: "/etc/sudoers", LastModified: tenAM, Size: 10, Mode: "0440", UID: 0, GID: 0},
+		{Path: "/etc/passwd", LastModified: tenAM, Size: 10, Mode: "0644", UID: 0, GID: 0},
+	}
+	current := []FileInfo{
+		{Path: "/etc/sudoers", LastModified: tenAM, Size: 10, Mode: "0666", UID: 0, GID: 0, AccessTime: elevenAM},
+		{Path: "/etc/passwd", LastModified: elevenAM, Size: 12, Mode: "0644", UID: 1000, GID: 0},
+	}
+
+	events := DiffSnapshots(previous, current, time.Now())
+
+	assert.Len(t, events, 3)
+	assert.Equal(t, "/etc/passwd", events[0].Path)
+	assert.Equal(t, ChangeSizeChanged, events[0].Type)
+	assert.Equal(t, "/etc/passwd", events[1].Path)
+	assert.Equal(t, ChangeOwnership, events[1].Type)
+	assert.Equal(t, "/etc/sudoers", events[2].Path)
+	assert.Equal(t, ChangePermissions, events[2].Type)
+	assert.Equal(t, "0440", events[2].Old.Mode)
+	assert.Equal(t, "0666", events[2].New.Mode)
+}
+
+func TestDiffSnapshots_ComparesAtCoarsestPrecision(t *testing.T) {
+	previous := []FileInfo{
+		{Path: "/test/file1.txt", LastModified: tenAM.Add(250 * time.Millisecond), Size: 10},
+		{Path: "/test/file2.txt", LastModified: tenAM.Add(250 * time.Millisecond), Size: 10},
+	}
+	current := []FileInfo{
+		{Path: "/test/file1.txt", LastModified: tenAM, Size: 10},
+		{Path: "/test/file2.txt", LastModified: tenAM.Add(750 * time.Millisecond), Size: 10},
+	}
+
+	events := DiffSnapshots(previous, current, time.Now())
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, "/test/file2.txt", events[0].Path)
+}
@@ -0,0 +1,16 @@
+package domain
+
+// EventFilter narrows the events returned by an event store query.
+type EventFilter struct {
+	// AfterSeq skips every event whose sequence number is not greater than it.
+	AfterSeq uint64
+	// Limit caps the number of returned events; zero means no limit.
+	Limit int
+	// TimeRange keeps only events detected inside the range.
+	TimeRange
+}
+
+// Matches reports whether the event passes the filter, ignoring Limit.
+func (f EventFilter) Matches(event ChangeEvent) bool {
+	return event.Seq > f.AfterSeq && f.Contains(event.DetectedAt)
+}
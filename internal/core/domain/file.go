@@ -1,7 +1,85 @@
 package domain
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// Keys of FileInfo.Hashes, one per supported content hash algorithm.
+const (
+	HashSHA256  = "sha256"
+	HashBLAKE2b = "blake2b"
+	HashXXHash  = "xxhash"
+)
+
 type FileInfo struct {
 	Path         string
-	LastModified string
+	LastModified time.Time
 	Size         int64
+	// Mode holds the permission bits, including setuid, setgid and sticky,
+	// as four octal digits such as "0644".
+	Mode  string `json:",omitempty"`
+	UID   uint32
+	GID   uint32
+	Owner string `json:",omitempty"`
+	Group string `json:",omitempty"`
+	Inode uint64 `json:",omitempty"`
+	// Device identifies the filesystem the inode belongs to.
+	Device        uint64 `json:",omitempty"`
+	LinkCount     uint64 `json:",omitempty"`
+	ChangeTime    time.Time
+	AccessTime    time.Time
+	SymlinkTarget string `json:",omitempty"`
+	// Hashes maps a hash algorithm to the hex digest of the file content.
+	Hashes map[string]string `json:",omitempty"`
+}
+
+// fileInfoJSON is the wire format of FileInfo, kept from when its times were
+// strings: RFC 3339 to the second, with ChangeTime and AccessTime left out
+// when unknown.
+type fileInfoJSON struct {
+	Path          string
+	LastModified  string
+	Size          int64
+	Mode          string `json:",omitempty"`
+	UID           uint32
+	GID           uint32
+	Owner         string            `json:",omitempty"`
+	Group         string            `json:",omitempty"`
+	Inode         uint64            `json:",omitempty"`
+	Device        uint64            `json:",omitempty"`
+	LinkCount     uint64            `json:",omitempty"`
+	ChangeTime    string            `json:",omitempty"`
+	AccessTime    string            `json:",omitempty"`
+	SymlinkTarget string            `json:",omitempty"`
+	Hashes        map[string]string `json:",omitempty"`
+}
+
+// MarshalJSON encodes f in the format of fileInfoJSON. The default decoding
+// reads it back, as RFC 3339 is what time.Time expects.
+func (f FileInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fileInfoJSON{
+		Path:          f.Path,
+		LastModified:  f.LastModified.Format(time.RFC3339),
+		Size:          f.Size,
+		Mode:          f.Mode,
+		UID:           f.UID,
+		GID:           f.GID,
+		Owner:         f.Owner,
+		Group:         f.Group,
+		Inode:         f.Inode,
+		Device:        f.Device,
+		LinkCount:     f.LinkCount,
+		ChangeTime:    formatOptionalTime(f.ChangeTime),
+		AccessTime:    formatOptionalTime(f.AccessTime),
+		SymlinkTarget: f.SymlinkTarget,
+		Hashes:        f.Hashes,
+	})
+}
+
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
 }
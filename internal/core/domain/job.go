@@ -0,0 +1,81 @@
+package domain
+
+import "time"
+
+// JobState is where a job is in its lifecycle.
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+	// JobInterrupted is a job whose process was lost to a crash or killed
+	// by shutdown. Depending on the requeue policy it is queued again when
+	// the worker restarts.
+	JobInterrupted JobState = "interrupted"
+)
+
+// Finished reports whether the job will not change state again.
+func (s JobState) Finished() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled || s == JobInterrupted
+}
+
+// CommandSpec is a command to run, already split into arguments.
+type CommandSpec struct {
+	Argv []string
+	// Env is added to the worker's environment.
+	Env map[string]string `json:",omitempty"`
+	// Cwd is the absolute working directory; empty means the worker's.
+	Cwd string `json:",omitempty"`
+	// Stdin is written to the command's standard input. It often carries
+	// secrets, so it is never encoded: neither API responses nor job stores
+	// hold it.
+	Stdin string `json:"-"`
+}
+
+// Job is a command submitted to the worker queue.
+type Job struct {
+	ID string
+	// Command is the command line as submitted, or Argv quoted for a shell
+	// when the job was submitted as a CommandSpec.
+	Command string
+	CommandSpec
+	State JobState
+	// ExitCode is set once the process has exited.
+	ExitCode *int `json:",omitempty"`
+	// Error explains failures that are not a non-zero exit, such as an
+	// executable that could not be started.
+	Error string `json:",omitempty"`
+	// TimeoutMillis is how long the job may run before its process group
+	// is killed.
+	TimeoutMillis int64
+	// Queue names the concurrency limit the job counts against, if any.
+	Queue string `json:",omitempty"`
+	// StdinBytes is the length of Stdin. A job restored from a job store
+	// has lost its standard input and is not run again.
+	StdinBytes int `json:",omitempty"`
+	// Attempts counts how often the job was started.
+	Attempts   int
+	EnqueuedAt time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// EnqueueOptions applies to every command of one EnqueueCommands call.
+type EnqueueOptions struct {
+	// Timeout overrides the worker's default command timeout when positive.
+	Timeout time.Duration
+	// Queue groups jobs that share a concurrency limit, such as
+	// "dir:/srv/app" for jobs working on one directory.
+	Queue string
+}
+
+// JobOutput is what a job wrote to stdout and stderr. Each stream keeps at
+// most a fixed number of bytes; Truncated is set when more was written.
+type JobOutput struct {
+	Stdout    string
+	Stderr    string
+	Truncated bool
+}
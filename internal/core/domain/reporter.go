@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// ReporterStatus describes how delivery of change events to the configured
+// api_endpoint is going. Event counts cover the lifetime of the process.
+type ReporterStatus struct {
+	Endpoint string
+	// Delivered counts events the endpoint accepted.
+	Delivered uint64
+	// Rejected counts events the endpoint refused with a client error;
+	// they are not retried.
+	Rejected uint64
+	// Dropped counts events discarded because the spool was full.
+	Dropped uint64
+	// Pending counts events waiting for the next batch.
+	Pending int
+	// SpooledBatches and SpooledBytes describe batches waiting for a retry.
+	SpooledBatches      int
+	SpooledBytes        int64
+	ConsecutiveFailures int
+	LastDelivery        time.Time
+	LastError           string `json:",omitempty"`
+	LastErrorAt         time.Time
+	// NextAttempt is set while delivery is backing off after a failure.
+	NextAttempt time.Time
+}
@@ -0,0 +1,65 @@
+package domain
+
+import "time"
+
+// Kinds of per-path failures a scan tolerates.
+const (
+	ScanErrorPermission  = "permission_denied"
+	ScanErrorVanished    = "vanished"
+	ScanErrorNameTooLong = "name_too_long"
+	ScanErrorOther       = "other"
+)
+
+// MaxScanErrors caps how many errors a ScanReport lists. ErrorCount keeps
+// counting past it.
+const MaxScanErrors = 100
+
+// ScanError is a path a scan had to skip.
+type ScanError struct {
+	Path    string
+	Kind    string
+	Message string
+}
+
+// ScanReport describes how complete a scan was.
+type ScanReport struct {
+	Root           string
+	StartedAt      time.Time
+	DurationMillis int64
+	FilesScanned   int
+	ErrorCount     int
+	Errors         []ScanError `json:",omitempty"`
+}
+
+// AddError records a skipped path, keeping at most MaxScanErrors of them.
+func (r *ScanReport) AddError(scanErr ScanError) {
+	r.ErrorCount++
+	if len(r.Errors) < MaxScanErrors {
+		r.Errors = append(r.Errors, scanErr)
+	}
+}
+
+// Partial reports whether some paths could not be read.
+func (r ScanReport) Partial() bool {
+	return r.ErrorCount > 0
+}
+
+// ScanResult is the files a scan found together with its report.
+type ScanResult struct {
+	Files  []FileInfo
+	Report ScanReport
+	// Unreadable lists every path the scan could not read, without the cap
+	// of Report.Errors, so that the files below them keep their previous
+	// state instead of being reported as deleted. Paths that vanished
+	// during the scan are left out.
+	Unreadable []string `json:"-"`
+}
+
+// AddError records a skipped path in the report and, unless it vanished,
+// in Unreadable.
+func (r *ScanResult) AddError(scanErr ScanError) {
+	r.Report.AddError(scanErr)
+	if scanErr.Path != "" && scanErr.Kind != ScanErrorVanished {
+		r.Unreadable = append(r.Unreadable, scanErr.Path)
+	}
+}
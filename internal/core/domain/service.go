@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// ServiceState is the lifecycle state of the monitoring service.
+type ServiceState string
+
+const (
+	ServiceStopped ServiceState = "stopped"
+	ServiceRunning ServiceState = "running"
+	// ServicePaused neither scans nor starts commands, but keeps its
+	// snapshots and accepts commands.
+	ServicePaused ServiceState = "paused"
+	// ServiceStopping lets queued commands drain before ServiceStopped.
+	ServiceStopping ServiceState = "stopping"
+)
+
+// ServiceStatus reports the lifecycle state of the monitoring service.
+type ServiceStatus struct {
+	State ServiceState
+	// Since is when the service entered State.
+	Since time.Time
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// TimeRange is an inclusive time interval. A zero Since or Until leaves that
+// side of the range open.
+type TimeRange struct {
+	Since time.Time
+	Until time.Time
+}
+
+// Contains reports whether t falls inside the range.
+func (r TimeRange) Contains(t time.Time) bool {
+	if !r.Since.IsZero() && t.Before(r.Since) {
+		return false
+	}
+	if !r.Until.IsZero() && t.After(r.Until) {
+		return false
+	}
+	return true
+}
+
+// FilterByModTime returns the files whose LastModified falls inside r.
+func FilterByModTime(files []FileInfo, r TimeRange) []FileInfo {
+	if r.Since.IsZero() && r.Until.IsZero() {
+		return files
+	}
+	filtered := make([]FileInfo, 0, len(files))
+	for _, file := range files {
+		if r.Contains(file.LastModified) {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeRange_Contains(t *testing.T) {
+	r := TimeRange{Since: tenAM, Until: elevenAM}
+
+	assert.True(t, r.Contains(tenAM))
+	assert.True(t, r.Contains(elevenAM))
+	assert.False(t, r.Contains(tenAM.Add(-time.Nanosecond)))
+	assert.False(t, r.Contains(elevenAM.Add(time.Nanosecond)))
+	assert.True(t, TimeRange{}.Contains(time.Time{}))
+}
+
+func TestFilterByModTime(t *testing.T) {
+	files := []FileInfo{
+		{Path: "/test/old.txt", LastModified: tenAM},
+		{Path: "/test/new.txt", LastModified: elevenAM},
+	}
+
+	filtered := FilterByModTime(files, TimeRange{Since: tenAM.Add(time.Minute)})
+
+	assert.Equal(t, []FileInfo{files[1]}, filtered)
+}
+
+func TestFileInfo_JSONKeepsWireFormat(t *testing.T) {
+	info := FileInfo{Path: "/test/file1.txt", LastModified: time.Date(2024, 9, 23, 10, 0, 0, 123456789, time.UTC), Size: 10}
+
+	data, err := json.Marshal(info)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"Path":"/test/file1.txt","LastModified":"2024-09-23T10:00:00Z","Size":10,"UID":0,"GID":0}`, string(data))
+
+	info.ChangeTime = tenAM
+	info.AccessTime = elevenAM.In(time.FixedZone("", 3600))
+	data, err = json.Marshal(info)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"ChangeTime":"2024-09-23T10:00:00Z","AccessTime":"2024-09-23T12:00:00+01:00"`)
+
+	var decoded FileInfo
+	require.NoError(t, json.Unmarshal([]byte(`{"Path":"/test/file1.txt","LastModified":"2024-09-23T11:00:00+01:00","Size":10}`), &decoded))
+	assert.True(t, decoded.LastModified.Equal(tenAM))
+}
@@ -0,0 +1,19 @@
+package domain
+
+// WatchOp is the kind of filesystem notification a watcher received.
+type WatchOp string
+
+const (
+	WatchCreate WatchOp = "create"
+	WatchWrite  WatchOp = "write"
+	WatchRemove WatchOp = "remove"
+	WatchRename WatchOp = "rename"
+	WatchChmod  WatchOp = "chmod"
+)
+
+// WatchEvent tells the change pipeline that Path may have changed. The
+// pipeline always re-reads the path, so the operation is only a hint.
+type WatchEvent struct {
+	Path string
+	Op   WatchOp
+}
@@ -0,0 +1,57 @@
+package domain
+
+import "time"
+
+// Watch modes a root can be monitored with.
+const (
+	WatchModePoll     = "poll"
+	WatchModeFsnotify = "fsnotify"
+)
+
+// WatchRoot is one monitored directory tree together with how it is scanned.
+type WatchRoot struct {
+	Path           string
+	CheckFrequency time.Duration
+	WatchMode      string
+	// MaxDepth limits how many directory levels below Path are scanned.
+	// Files directly in Path have depth 1; zero means unlimited.
+	MaxDepth int
+	// HashAlgorithms lists the content hashes computed for files in this
+	// root. Empty disables hashing.
+	HashAlgorithms []string
+	Include        []string
+	Exclude        []string
+}
+
+func (r WatchRoot) ScanOptions() ScanOptions {
+	return ScanOptions{MaxDepth: r.MaxDepth, Include: r.Include, Exclude: r.Exclude, HashAlgorithms: r.HashAlgorithms}
+}
+
+// ScanOptions limits which part of a tree a scan reports. MaxDepth counts
+// directory levels below the root, with files directly in the root at depth
+// 1. Include holds doublestar globs a file must match; Exclude holds
+// gitignore-style rules, including negation with a leading "!".
+// HashAlgorithms lists the content hashes the scan may fill in itself.
+type ScanOptions struct {
+	MaxDepth       int
+	Include        []string
+	Exclude        []string
+	HashAlgorithms []string
+}
+
+// RootStatus reports how monitoring of a single watch root is going.
+type RootStatus struct {
+	Path           string
+	WatchMode      string
+	CheckFrequency int
+	LastScan       time.Time
+	// LastScanMillis is how long the last full scan took.
+	LastScanMillis int64
+	NextScan       time.Time
+	FilesTracked   int
+	EventsRecorded uint64
+	LastError      string `json:",omitempty"`
+	LastErrorAt    time.Time
+	// LastReport lists the paths the last full scan could not read.
+	LastReport *ScanReport `json:",omitempty"`
+}
@@ -0,0 +1,362 @@
+// Package policy decides which commands the worker queue may execute.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+	"file-mod-tracker/pkg/shellwords"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// DefaultDeniedExecutables can never run, whatever the configuration says.
+var DefaultDeniedExecutables = []string{
+	"rm", "del", "unlink", "rmdir", "erase", "destroy",
+	"mv", "move", "dd", "truncate", "shred", "wipe", "mkfs", "format",
+}
+
+// DefaultDeniedWrappers run a command or script passed in their arguments,
+// which no other rule would see: "sh -c 'rm -rf /'" or "env rm -rf /". They
+// are refused unless AllowedExecutables lists them by name.
+var DefaultDeniedWrappers = []string{
+	"sh", "bash", "dash", "zsh", "ksh", "csh", "tcsh", "fish", "ash",
+	"cmd", "powershell", "pwsh",
+	"env", "xargs", "busybox", "toybox", "nohup", "nice", "ionice", "timeout",
+	"stdbuf", "setsid", "chroot", "sudo", "su", "doas", "runuser", "watch",
+	"parallel",
+}
+
+// DefaultArgumentRules always apply, in front of the configured ones.
+var DefaultArgumentRules = []ArgumentRule{
+	// find deletes files and runs commands of its own.
+	{Executable: "find", Deny: []string{`^-(delete|exec|execdir|ok|okdir|fprint|fprint0|fprintf|fls)$`}},
+}
+
+// DeniedEnvPrefixes are environment variables a command may not set,
+// because they make the dynamic loader run code of the caller's choosing.
+var DeniedEnvPrefixes = []string{"LD_", "DYLD_"}
+
+// Rules is the configurable part of a Policy.
+type Rules struct {
+	// AllowedExecutables lists the only executables that may run, as names
+	// looked up in PATH or as absolute paths. Empty allows none: a denylist
+	// cannot keep up with interpreters and tools that overwrite files, so
+	// every executable has to be allowed explicitly.
+	AllowedExecutables []string
+	// DeniedExecutables is added to DefaultDeniedExecutables. Entries are
+	// compared with the base name of the executable.
+	DeniedExecutables []string
+	ArgumentRules     []ArgumentRule
+	// DeniedPaths lists doublestar globs of absolute paths no argument may
+	// name, directly or through a parent directory.
+	DeniedPaths []string
+}
+
+// ArgumentRule restricts the arguments of one executable. Patterns are
+// regular expressions matched against each argument separately.
+type ArgumentRule struct {
+	// Executable is a base name, an absolute path or "*" for every
+	// executable.
+	Executable string
+	// Allow, when not empty, lists the patterns every argument must match.
+	Allow []string
+	// Deny lists patterns no argument may match.
+	Deny []string
+}
+
+// Policy evaluates commands against Rules. It is safe for concurrent use.
+type Policy struct {
+	allowed     map[string]bool
+	denied      map[string]bool
+	argRules    []argumentRule
+	deniedPaths []string
+	lookPath    func(file string) (string, error)
+}
+
+type argumentRule struct {
+	executable string
+	allow      []*regexp.Regexp
+	deny       []*regexp.Regexp
+}
+
+// Option customises a Policy at construction time.
+type Option func(*Policy)
+
+// WithLookPath replaces exec.LookPath for resolving executables.
+func WithLookPath(lookPath func(file string) (string, error)) Option {
+	return func(p *Policy) {
+		p.lookPath = lookPath
+	}
+}
+
+// Default returns the policy used when none is configured, which refuses
+// every command since no executable is allowed.
+func Default() *Policy {
+	p, _ := New(Rules{})
+	return p
+}
+
+// New compiles rules. Allowed executables are resolved to absolute paths
+// once, so a later change to PATH cannot substitute another binary.
+func New(rules Rules, opts ...Option) (*Policy, error) {
+	p := &Policy{
+		denied:   make(map[string]bool),
+		lookPath: exec.LookPath,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	var errs []error
+	for _, name := range rules.AllowedExecutables {
+		resolved, err := p.resolve(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("allowed executable %q: %w", name, err))
+			continue
+		}
+		if p.allowed == nil {
+			p.allowed = make(map[string]bool)
+		}
+		p.allowed[resolved] = true
+	}
+	explicit := make(map[string]bool)
+	for _, name := range rules.AllowedExecutables {
+		explicit[executableName(name)] = true
+	}
+	for resolved := range p.allowed {
+		explicit[executableName(resolved)] = true
+	}
+	for _, name := range DefaultDeniedWrappers {
+		if !explicit[executableName(name)] {
+			p.denied[executableName(name)] = true
+		}
+	}
+	for _, name := range append(DefaultDeniedExecutables, rules.DeniedExecutables...) {
+		p.denied[executableName(name)] = true
+	}
+	for _, rule := range append(DefaultArgumentRules, rules.ArgumentRules...) {
+		compiled := argumentRule{executable: rule.Executable}
+		if filepath.IsAbs(rule.Executable) {
+			if real, err := filepath.EvalSymlinks(rule.Executable); err == nil {
+				compiled.executable = real
+			}
+		}
+		compiled.allow, errs = compilePatterns(rule.Allow, errs)
+		compiled.deny, errs = compilePatterns(rule.Deny, errs)
+		p.argRules = append(p.argRules, compiled)
+	}
+	for _, pattern := range rules.DeniedPaths {
+		if !filepath.IsAbs(pattern) || !doublestar.ValidatePattern(filepath.ToSlash(pattern)) {
+			errs = append(errs, fmt.Errorf("invalid denied path %q", pattern))
+			continue
+		}
+		p.deniedPaths = append(p.deniedPaths, filepath.ToSlash(filepath.Clean(pattern)))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func compilePatterns(patterns []string, errs []error) ([]*regexp.Regexp, []error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid argument pattern %q: %w", pattern, err))
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, errs
+}
+
+// Check implements ports.CommandPolicy. Relative paths in cmd are resolved
+// against cmd.Cwd, which must itself be absolute and not denied.
+func (p *Policy) Check(cmd domain.CommandSpec) (string, error) {
+	argv := cmd.Argv
+	if len(argv) == 0 {
+		return "", p.reject(argv, "empty command")
+	}
+	if cmd.Cwd != "" {
+		if !filepath.IsAbs(cmd.Cwd) {
+			return "", p.reject(argv, fmt.Sprintf("working directory %q is not absolute", cmd.Cwd))
+		}
+		if pattern := p.deniedPath(cmd.Cwd, ""); pattern != "" {
+			return "", p.reject(argv, fmt.Sprintf("working directory %q names denied path %q", cmd.Cwd, pattern))
+		}
+	}
+	for name := range cmd.Env {
+		for _, prefix := range DeniedEnvPrefixes {
+			if strings.HasPrefix(strings.ToUpper(name), prefix) {
+				return "", p.reject(argv, fmt.Sprintf("environment variable %q is denied", name))
+			}
+		}
+	}
+
+	executable := argv[0]
+	if cmd.Cwd != "" && !filepath.IsAbs(executable) && strings.ContainsRune(executable, filepath.Separator) {
+		executable = filepath.Join(cmd.Cwd, executable)
+	}
+	resolved, err := p.resolve(executable)
+	if err != nil {
+		return "", p.reject(argv, fmt.Sprintf("executable %q not found", argv[0]))
+	}
+	for _, name := range []string{argv[0], resolved} {
+		if p.denied[executableName(name)] {
+			return "", p.reject(argv, fmt.Sprintf("executable %q is denied", executableName(name)))
+		}
+	}
+	if p.allowed == nil {
+		return "", p.reject(argv, "no executables are allowed; list them in allowed_executables")
+	}
+	if !p.allowed[resolved] {
+		return "", p.reject(argv, fmt.Sprintf("executable %q is not in the allowlist", resolved))
+	}
+
+	for _, rule := range p.argRules {
+		if !rule.appliesTo(argv[0], resolved) {
+			continue
+		}
+		for _, arg := range argv[1:] {
+			if reason := rule.check(arg); reason != "" {
+				return "", p.reject(argv, reason)
+			}
+		}
+	}
+
+	for _, arg := range argv[1:] {
+		if pattern := p.deniedPath(arg, cmd.Cwd); pattern != "" {
+			return "", p.reject(argv, fmt.Sprintf("argument %q names denied path %q", arg, pattern))
+		}
+	}
+	return resolved, nil
+}
+
+func (p *Policy) reject(argv []string, reason string) error {
+	return &ports.CommandRejectedError{Command: shellwords.Join(argv), Reason: reason}
+}
+
+// resolve returns the absolute, symlink-free path of an executable.
+func (p *Policy) resolve(name string) (string, error) {
+	path, err := p.lookPath(name)
+	if err != nil {
+		return "", err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	return path, nil
+}
+
+// deniedPath returns the denied pattern arg, or the path given in the option
+// arg, falls under, if any. Relative paths are resolved against dir, or the
+// current directory when dir is empty.
+func (p *Policy) deniedPath(arg, dir string) string {
+	arg = optionPath(arg)
+	if arg == "" {
+		return ""
+	}
+	if dir != "" && !filepath.IsAbs(arg) {
+		arg = filepath.Join(dir, arg)
+	}
+	path, err := filepath.Abs(arg)
+	if err != nil {
+		return ""
+	}
+	candidates := []string{path}
+	if real, err := filepath.EvalSymlinks(path); err == nil && real != path {
+		candidates = append(candidates, real)
+	}
+	for _, candidate := range candidates {
+		for dir := candidate; ; dir = filepath.Dir(dir) {
+			for _, pattern := range p.deniedPaths {
+				if ok, _ := doublestar.Match(pattern, filepath.ToSlash(dir)); ok {
+					return pattern
+				}
+			}
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+	return ""
+}
+
+// optionPath returns the path arg may name, or "" for none. An argument that
+// is not an option is a path itself. Long options name one in --name=value.
+// Short options may have one glued on, after the option letter or a cluster
+// of them: -f/etc/shadow, -xzf/etc/shadow or -ofoo/bar. A relative glued
+// value is only recognised when it follows a single letter and holds a path
+// separator, so -ofile is not checked. An option given its value as the next
+// argument, such as --output /etc/cron.d/job, is checked through that
+// argument.
+func optionPath(arg string) string {
+	switch {
+	case !strings.HasPrefix(arg, "-"):
+		return arg
+	case strings.HasPrefix(arg, "--"):
+		if i := strings.IndexByte(arg, '='); i >= 0 {
+			return arg[i+1:]
+		}
+		return ""
+	}
+	for i := 2; i < len(arg); i++ {
+		if filepath.IsAbs(arg[i:]) {
+			return arg[i:]
+		}
+	}
+	if value := strings.TrimPrefix(arg[min(2, len(arg)):], "="); strings.ContainsRune(value, filepath.Separator) {
+		return value
+	}
+	return ""
+}
+
+func (r argumentRule) appliesTo(name, resolved string) bool {
+	switch r.executable {
+	case "*":
+		return true
+	case resolved:
+		return true
+	}
+	return !filepath.IsAbs(r.executable) && executableName(r.executable) == executableName(name)
+}
+
+// check returns why arg breaks the rule, or "" when it does not.
+func (r argumentRule) check(arg string) string {
+	for _, re := range r.deny {
+		if re.MatchString(arg) {
+			return fmt.Sprintf("argument %q matches denied pattern %q", arg, re.String())
+		}
+	}
+	if len(r.allow) == 0 {
+		return ""
+	}
+	for _, re := range r.allow {
+		if re.MatchString(arg) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("argument %q matches no allowed pattern for %s", arg, r.executable)
+}
+
+// executableName is the base name used to compare executables, without the
+// extension on Windows.
+func executableName(path string) string {
+	name := filepath.Base(path)
+	if runtime.GOOS == "windows" {
+		name = strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+	}
+	return name
+}
@@ -0,0 +1,200 @@
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLookPath resolves names to /usr/bin without touching the filesystem.
+func fakeLookPath(file string) (string, error) {
+	if filepath.IsAbs(file) {
+		return file, nil
+	}
+	if file == "missing" {
+		return "", fmt.Errorf("exec: %q: executable file not found in $PATH", file)
+	}
+	return "/usr/bin/" + file, nil
+}
+
+func assertRejected(t *testing.T, err error, reason string) {
+	t.Helper()
+	var rejected *ports.CommandRejectedError
+	require.ErrorAs(t, err, &rejected)
+	assert.Contains(t, rejected.Reason, reason)
+}
+
+func TestDefault_RefusesEveryCommand(t *testing.T) {
+	p, err := New(Rules{}, WithLookPath(fakeLookPath))
+	require.NoError(t, err)
+
+	for _, argv := range [][]string{{"ls", "-l"}, {"python3", "-c", "print()"}, {"cp", "/tmp/a", "/etc/cron.d/a"}} {
+		_, err = p.Check(domain.CommandSpec{Argv: argv})
+		assertRejected(t, err, "no executables are allowed")
+	}
+	_, err = Default().Check(domain.CommandSpec{Argv: []string{"ls"}})
+	assertRejected(t, err, "no executables are allowed")
+}
+
+func TestDefault_DeniesDangerousExecutables(t *testing.T) {
+	// Denied executables stay denied even when allowed.
+	p, err := New(Rules{AllowedExecutables: []string{"ls", "rm", "/bin/unlink"}}, WithLookPath(fakeLookPath))
+	require.NoError(t, err)
+
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"rm", "-rf", "/tmp/data"}})
+	assertRejected(t, err, `executable "rm" is denied`)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"/bin/unlink", "file"}})
+	assertRejected(t, err, `executable "unlink" is denied`)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"missing"}})
+	assertRejected(t, err, "not found")
+	_, err = p.Check(domain.CommandSpec{})
+	assertRejected(t, err, "empty command")
+
+	resolved, err := p.Check(domain.CommandSpec{Argv: []string{"ls", "-l"}})
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/bin/ls", resolved)
+}
+
+func TestDefault_DeniesWrappersAndBypasses(t *testing.T) {
+	p, err := New(Rules{AllowedExecutables: []string{"find", "mv", "dd", "truncate"}}, WithLookPath(fakeLookPath))
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		argv   []string
+		reason string
+	}{
+		{[]string{"sh", "-c", "rm -rf /"}, `executable "sh" is denied`},
+		{[]string{"/bin/bash", "-c", "rm -rf /"}, `executable "bash" is denied`},
+		{[]string{"env", "rm", "-rf", "/"}, `executable "env" is denied`},
+		{[]string{"xargs", "rm"}, `executable "xargs" is denied`},
+		{[]string{"busybox", "rm", "-rf", "/"}, `executable "busybox" is denied`},
+		{[]string{"find", "/", "-delete"}, `argument "-delete" matches denied pattern`},
+		{[]string{"find", "/", "-exec", "rm", "{}", ";"}, `argument "-exec" matches denied pattern`},
+		{[]string{"find", "/", "-execdir", "rm", "{}", "+"}, `argument "-execdir" matches denied pattern`},
+		{[]string{"mv", "/etc/hosts", "/dev/null"}, `executable "mv" is denied`},
+		{[]string{"dd", "if=/dev/zero", "of=/dev/sda"}, `executable "dd" is denied`},
+		{[]string{"truncate", "-s", "0", "/var/lib/app.db"}, `executable "truncate" is denied`},
+	} {
+		_, err := p.Check(domain.CommandSpec{Argv: tc.argv})
+		assertRejected(t, err, tc.reason)
+	}
+
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"find", "/srv", "-name", "*.log"}})
+	assert.NoError(t, err)
+}
+
+func TestDefault_WrappersCanBeAllowedExplicitly(t *testing.T) {
+	p, err := New(Rules{AllowedExecutables: []string{"sh", "find"}}, WithLookPath(fakeLookPath))
+	require.NoError(t, err)
+
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"sh", "/srv/app/deploy.sh"}})
+	assert.NoError(t, err)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"find", "/srv", "-delete"}})
+	assertRejected(t, err, `argument "-delete" matches denied pattern`)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"env", "ls"}})
+	assertRejected(t, err, `executable "env" is denied`)
+}
+
+func TestAllowlist(t *testing.T) {
+	p, err := New(Rules{AllowedExecutables: []string{"ls", "/opt/deploy/bin/deploy"}}, WithLookPath(fakeLookPath))
+	require.NoError(t, err)
+
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"ls"}})
+	assert.NoError(t, err)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"/opt/deploy/bin/deploy", "v2"}})
+	assert.NoError(t, err)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"cat", "/etc/hosts"}})
+	assertRejected(t, err, `"/usr/bin/cat" is not in the allowlist`)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"/tmp/ls"}})
+	assertRejected(t, err, "not in the allowlist")
+
+	_, err = New(Rules{AllowedExecutables: []string{"missing"}}, WithLookPath(fakeLookPath))
+	assert.ErrorContains(t, err, `allowed executable "missing"`)
+}
+
+func TestArgumentRules(t *testing.T) {
+	p, err := New(Rules{AllowedExecutables: []string{"ls", "find"}, ArgumentRules: []ArgumentRule{
+		{Executable: "ls", Allow: []string{`^-[lah]+$`, `^/srv/`}},
+		{Executable: "*", Deny: []string{`^--exec`}},
+	}}, WithLookPath(fakeLookPath))
+	require.NoError(t, err)
+
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"ls", "-la", "/srv/app"}})
+	assert.NoError(t, err)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"ls", "-R", "/srv/app"}})
+	assertRejected(t, err, `argument "-R" matches no allowed pattern for ls`)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"find", ".", "--exec=sh"}})
+	assertRejected(t, err, `matches denied pattern "^--exec"`)
+
+	_, err = New(Rules{ArgumentRules: []ArgumentRule{{Executable: "ls", Deny: []string{"("}}}})
+	assert.ErrorContains(t, err, "invalid argument pattern")
+}
+
+func TestDeniedPaths(t *testing.T) {
+	p, err := New(Rules{
+		AllowedExecutables: []string{"cat", "cp", "tar", "sort"},
+		DeniedPaths:        []string{"/etc/shadow", "/etc/cron.d", "/root", "/**/.ssh"},
+	}, WithLookPath(fakeLookPath))
+	require.NoError(t, err)
+
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"cat", "/etc/shadow"}})
+	assertRejected(t, err, `names denied path "/etc/shadow"`)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"cat", "/root/notes/../.bashrc"}})
+	assertRejected(t, err, `denied path "/root"`)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"cp", "--target-directory=/home/app/.ssh/keys", "key"}})
+	assertRejected(t, err, `denied path "/**/.ssh"`)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"cat", "/etc/hosts"}})
+	assert.NoError(t, err)
+
+	// Paths glued to short options, or given after an option as the next
+	// argument, are checked too.
+	for _, tc := range []struct {
+		argv    []string
+		pattern string
+	}{
+		{[]string{"tar", "-f/etc/shadow"}, "/etc/shadow"},
+		{[]string{"tar", "-xzf/etc/shadow"}, "/etc/shadow"},
+		{[]string{"sort", "-o/etc/cron.d/job", "jobs"}, "/etc/cron.d"},
+		{[]string{"sort", "-o", "/etc/cron.d/job", "jobs"}, "/etc/cron.d"},
+		{[]string{"sort", "--output", "/etc/cron.d/job", "jobs"}, "/etc/cron.d"},
+		{[]string{"sort", "--output=/etc/cron.d/job", "jobs"}, "/etc/cron.d"},
+		{[]string{"cp", "-t/root/bin", "tool"}, "/root"},
+	} {
+		_, err = p.Check(domain.CommandSpec{Argv: tc.argv})
+		assertRejected(t, err, fmt.Sprintf("names denied path %q", tc.pattern))
+	}
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"tar", "-xzf/srv/backup.tgz"}})
+	assert.NoError(t, err)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"sort", "-ofile", "-k2"}})
+	assert.NoError(t, err)
+
+	_, err = New(Rules{DeniedPaths: []string{"relative/path"}})
+	assert.ErrorContains(t, err, "invalid denied path")
+}
+
+func TestWorkingDirectoryAndEnv(t *testing.T) {
+	p, err := New(Rules{
+		AllowedExecutables: []string{"cat", "ls", "/srv/app/bin/deploy"},
+		DeniedPaths:        []string{"/etc/shadow", "/root"},
+	}, WithLookPath(fakeLookPath))
+	require.NoError(t, err)
+
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"cat", "shadow"}, Cwd: "/etc"})
+	assertRejected(t, err, `argument "shadow" names denied path "/etc/shadow"`)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"ls"}, Cwd: "/root/projects"})
+	assertRejected(t, err, `working directory "/root/projects" names denied path "/root"`)
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"ls"}, Cwd: "srv"})
+	assertRejected(t, err, "is not absolute")
+	_, err = p.Check(domain.CommandSpec{Argv: []string{"ls"}, Env: map[string]string{"LD_PRELOAD": "/tmp/evil.so"}})
+	assertRejected(t, err, `environment variable "LD_PRELOAD" is denied`)
+
+	resolved, err := p.Check(domain.CommandSpec{Argv: []string{"./bin/deploy", "hosts"}, Cwd: "/srv/app", Env: map[string]string{"STAGE": "prod"}})
+	require.NoError(t, err)
+	assert.Equal(t, "/srv/app/bin/deploy", resolved)
+}
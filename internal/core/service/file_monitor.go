@@ -1,6 +1,10 @@
 package service
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"file-mod-tracker/internal/core/domain"
 	"file-mod-tracker/internal/ports"
 	"file-mod-tracker/pkg/logger"
@@ -10,6 +14,12 @@ type fileMonitorService struct {
 	osqueryAdapter ports.OsqueryAdapter
 	workerAdapter  ports.WorkerAdapter
 	logger         logger.Logger
+
+	// lifecycle serialises state transitions; mu guards status, so it can
+	// be read while a transition such as Stop is under way.
+	lifecycle sync.Mutex
+	mu        sync.Mutex
+	status    domain.ServiceStatus
 }
 
 func NewFileMonitorService(osqueryAdapter ports.OsqueryAdapter, workerAdapter ports.WorkerAdapter, logger logger.Logger) *fileMonitorService {
@@ -17,6 +27,7 @@ func NewFileMonitorService(osqueryAdapter ports.OsqueryAdapter, workerAdapter po
 		osqueryAdapter: osqueryAdapter,
 		workerAdapter:  workerAdapter,
 		logger:         logger,
+		status:         domain.ServiceStatus{State: domain.ServiceStopped, Since: time.Now()},
 	}
 }
 
@@ -24,6 +35,80 @@ func (s *fileMonitorService) GetFileStats(directory string) ([]domain.FileInfo,
 	return s.osqueryAdapter.GetFileStats(directory)
 }
 
-func (s *fileMonitorService) EnqueueCommands(commands []string) error {
-	return s.workerAdapter.EnqueueCommands(commands)
+func (s *fileMonitorService) ScanDirectory(directory string) (domain.ScanResult, error) {
+	return s.osqueryAdapter.ScanFiles(directory, domain.ScanOptions{})
+}
+
+func (s *fileMonitorService) EnqueueCommands(commands []string, opts domain.EnqueueOptions) ([]domain.Job, error) {
+	return s.workerAdapter.EnqueueCommands(commands, opts)
+}
+
+func (s *fileMonitorService) EnqueueJobs(specs []domain.CommandSpec, opts domain.EnqueueOptions) ([]domain.Job, error) {
+	return s.workerAdapter.EnqueueJobs(specs, opts)
+}
+
+// Start starts monitoring and running commands.
+func (s *fileMonitorService) Start() error {
+	return s.transition("start", []domain.ServiceState{domain.ServiceStopped}, func() domain.ServiceState {
+		s.workerAdapter.Start()
+		return domain.ServiceRunning
+	})
+}
+
+// Stop drains the queued commands and stops monitoring. The service can be
+// started again.
+func (s *fileMonitorService) Stop() error {
+	return s.transition("stop", []domain.ServiceState{domain.ServiceRunning, domain.ServicePaused}, func() domain.ServiceState {
+		s.setState(domain.ServiceStopping)
+		s.workerAdapter.Stop()
+		return domain.ServiceStopped
+	})
+}
+
+// Pause stops scanning and starting commands without losing any state.
+func (s *fileMonitorService) Pause() error {
+	return s.transition("pause", []domain.ServiceState{domain.ServiceRunning}, func() domain.ServiceState {
+		s.workerAdapter.Pause()
+		return domain.ServicePaused
+	})
+}
+
+// Resume undoes Pause.
+func (s *fileMonitorService) Resume() error {
+	return s.transition("resume", []domain.ServiceState{domain.ServicePaused}, func() domain.ServiceState {
+		s.workerAdapter.Resume()
+		return domain.ServiceRunning
+	})
+}
+
+func (s *fileMonitorService) Status() domain.ServiceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// transition runs apply if the service is in one of the from states and
+// moves it to the state apply returns.
+func (s *fileMonitorService) transition(action string, from []domain.ServiceState, apply func() domain.ServiceState) error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	current := s.Status().State
+	allowed := false
+	for _, state := range from {
+		allowed = allowed || state == current
+	}
+	if !allowed {
+		return fmt.Errorf("%w: cannot %s a %s service", ports.ErrInvalidTransition, action, current)
+	}
+	state := apply()
+	s.setState(state)
+	s.logger.Info("Service state changed", "action", action, "from", current, "state", state)
+	return nil
+}
+
+func (s *fileMonitorService) setState(state domain.ServiceState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = domain.ServiceStatus{State: state, Since: time.Now()}
 }
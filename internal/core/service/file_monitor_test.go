@@ -3,7 +3,9 @@ package service
 import (
 	"errors"
 	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -19,19 +21,46 @@ func (m *mockOsqueryAdapter) GetFileStats(directory string) ([]domain.FileInfo,
 	return args.Get(0).([]domain.FileInfo), args.Error(1)
 }
 
+func (m *mockOsqueryAdapter) ScanFiles(directory string, opts domain.ScanOptions) (domain.ScanResult, error) {
+	args := m.Called(directory, opts)
+	return args.Get(0).(domain.ScanResult), args.Error(1)
+}
+
 // Mock for WorkerAdapter
 type mockWorkerAdapter struct {
 	mock.Mock
 }
 
-func (m *mockWorkerAdapter) EnqueueCommands(commands []string) error {
-	args := m.Called(commands)
-	return args.Error(0)
+func (m *mockWorkerAdapter) EnqueueCommands(commands []string, opts domain.EnqueueOptions) ([]domain.Job, error) {
+	args := m.Called(commands, opts)
+	return args.Get(0).([]domain.Job), args.Error(1)
+}
+
+func (m *mockWorkerAdapter) EnqueueJobs(specs []domain.CommandSpec, opts domain.EnqueueOptions) ([]domain.Job, error) {
+	args := m.Called(specs, opts)
+	return args.Get(0).([]domain.Job), args.Error(1)
 }
 
-func (m *mockWorkerAdapter) Start() {}
-func (m *mockWorkerAdapter) Stop()  {}
-func (m *mockWorkerAdapter) GetFileChanges() []domain.FileInfo {
+func (m *mockWorkerAdapter) Start()  { m.Called() }
+func (m *mockWorkerAdapter) Stop()   { m.Called() }
+func (m *mockWorkerAdapter) Pause()  { m.Called() }
+func (m *mockWorkerAdapter) Resume() { m.Called() }
+func (m *mockWorkerAdapter) GetFileChanges(filter domain.EventFilter) []domain.ChangeEvent {
+	return nil
+}
+func (m *mockWorkerAdapter) GetRootStatuses() []domain.RootStatus {
+	return nil
+}
+func (m *mockWorkerAdapter) GetJobs() []domain.Job {
+	return nil
+}
+func (m *mockWorkerAdapter) GetJob(id string) (domain.Job, error) {
+	return domain.Job{}, nil
+}
+func (m *mockWorkerAdapter) GetJobOutput(id string) (domain.JobOutput, error) {
+	return domain.JobOutput{}, nil
+}
+func (m *mockWorkerAdapter) CancelJob(id string) error {
 	return nil
 }
 
@@ -60,7 +89,7 @@ func TestFileMonitorService_GetFileStats(t *testing.T) {
 	fileMonitor := NewFileMonitorService(mockOsquery, mockWorker, mockLogger)
 
 	expectedFileStats := []domain.FileInfo{
-		{Path: "/test/file1.txt", LastModified: "2024-09-23T12:00:00Z", Size: 1234},
+		{Path: "/test/file1.txt", LastModified: time.Date(2024, 9, 23, 12, 0, 0, 0, time.UTC), Size: 1234},
 	}
 
 	mockOsquery.On("GetFileStats", "/test").Return(expectedFileStats, nil)
@@ -93,6 +122,25 @@ func TestFileMonitorService_GetFileStats_Error(t *testing.T) {
 	mockOsquery.AssertExpectations(t)
 }
 
+func TestFileMonitorService_ScanDirectory(t *testing.T) {
+	mockOsquery := new(mockOsqueryAdapter)
+	mockWorker := new(mockWorkerAdapter)
+	mockLogger := new(mockLogger)
+	fileMonitor := NewFileMonitorService(mockOsquery, mockWorker, mockLogger)
+
+	expected := domain.ScanResult{
+		Files:  []domain.FileInfo{{Path: "/test/file1.txt", Size: 1234}},
+		Report: domain.ScanReport{Root: "/test", FilesScanned: 1, ErrorCount: 1, Errors: []domain.ScanError{{Path: "/test/private", Kind: domain.ScanErrorPermission}}},
+	}
+	mockOsquery.On("ScanFiles", "/test", domain.ScanOptions{}).Return(expected, nil)
+
+	result, err := fileMonitor.ScanDirectory("/test")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+	mockOsquery.AssertExpectations(t)
+}
+
 func TestFileMonitorService_EnqueueCommands(t *testing.T) {
 	// Arrange
 	mockOsquery := new(mockOsqueryAdapter)
@@ -102,13 +150,16 @@ func TestFileMonitorService_EnqueueCommands(t *testing.T) {
 
 	commands := []string{"command1", "command2"}
 
-	mockWorker.On("EnqueueCommands", commands).Return(nil)
+	expectedJobs := []domain.Job{{ID: "1", Command: "command1", State: domain.JobQueued}, {ID: "2", Command: "command2", State: domain.JobQueued}}
+	opts := domain.EnqueueOptions{Timeout: time.Minute}
+	mockWorker.On("EnqueueCommands", commands, opts).Return(expectedJobs, nil)
 
 	// Act
-	err := fileMonitor.EnqueueCommands(commands)
+	jobs, err := fileMonitor.EnqueueCommands(commands, opts)
 
 	// Assert
 	assert.NoError(t, err)
+	assert.Equal(t, expectedJobs, jobs)
 	mockWorker.AssertExpectations(t)
 }
 
@@ -121,12 +172,47 @@ func TestFileMonitorService_EnqueueCommands_Error(t *testing.T) {
 
 	commands := []string{"command1", "command2"}
 
-	mockWorker.On("EnqueueCommands", commands).Return(errors.New("queue error"))
+	mockWorker.On("EnqueueCommands", commands, domain.EnqueueOptions{}).Return([]domain.Job(nil), errors.New("queue error"))
 
 	// Act
-	err := fileMonitor.EnqueueCommands(commands)
+	_, err := fileMonitor.EnqueueCommands(commands, domain.EnqueueOptions{})
 
 	// Assert
 	assert.EqualError(t, err, "queue error")
 	mockWorker.AssertExpectations(t)
 }
+
+func TestFileMonitorService_Lifecycle(t *testing.T) {
+	mockOsquery := new(mockOsqueryAdapter)
+	mockWorker := new(mockWorkerAdapter)
+	mockLogger := new(mockLogger)
+	mockLogger.On("Info", "Service state changed", mock.Anything)
+	fileMonitor := NewFileMonitorService(mockOsquery, mockWorker, mockLogger)
+	for _, method := range []string{"Start", "Stop", "Pause", "Resume"} {
+		mockWorker.On(method)
+	}
+
+	assert.Equal(t, domain.ServiceStopped, fileMonitor.Status().State)
+	assert.ErrorIs(t, fileMonitor.Pause(), ports.ErrInvalidTransition)
+	assert.ErrorIs(t, fileMonitor.Stop(), ports.ErrInvalidTransition)
+
+	assert.NoError(t, fileMonitor.Start())
+	assert.Equal(t, domain.ServiceRunning, fileMonitor.Status().State)
+	assert.EqualError(t, fileMonitor.Start(), "invalid service state transition: cannot start a running service")
+
+	assert.NoError(t, fileMonitor.Pause())
+	assert.Equal(t, domain.ServicePaused, fileMonitor.Status().State)
+	assert.ErrorIs(t, fileMonitor.Start(), ports.ErrInvalidTransition)
+	assert.NoError(t, fileMonitor.Resume())
+	assert.Equal(t, domain.ServiceRunning, fileMonitor.Status().State)
+
+	assert.NoError(t, fileMonitor.Pause())
+	assert.NoError(t, fileMonitor.Stop())
+	assert.Equal(t, domain.ServiceStopped, fileMonitor.Status().State)
+	assert.NoError(t, fileMonitor.Start())
+
+	mockWorker.AssertNumberOfCalls(t, "Start", 2)
+	mockWorker.AssertNumberOfCalls(t, "Pause", 2)
+	mockWorker.AssertNumberOfCalls(t, "Resume", 1)
+	mockWorker.AssertNumberOfCalls(t, "Stop", 1)
+}
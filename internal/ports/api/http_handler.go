@@ -1,14 +1,75 @@
 package api
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
+// HTTPHandler serves version 1 of the HTTP API below /api/v1. Every
+// response is a Response envelope.
 type HTTPHandler interface {
 	GetFileStats(w http.ResponseWriter, r *http.Request)
 	EnqueueCommands(w http.ResponseWriter, r *http.Request)
 	HealthCheck(w http.ResponseWriter, r *http.Request)
 	GetLogs(w http.ResponseWriter, r *http.Request)
+	GetRoots(w http.ResponseWriter, r *http.Request)
+	GetJobs(w http.ResponseWriter, r *http.Request)
+	// Job serves GET and DELETE of a single job.
+	Job(w http.ResponseWriter, r *http.Request)
+	GetJobOutput(w http.ResponseWriter, r *http.Request)
+	GetService(w http.ResponseWriter, r *http.Request)
+	ServiceAction(w http.ResponseWriter, r *http.Request)
+	// NotFound answers requests for paths the API does not have.
+	NotFound(w http.ResponseWriter, r *http.Request)
 }
 
 type HTTPServer interface {
-	Start()
+	Start(port string) error
+	Shutdown(ctx context.Context) error
 }
+
+// Response is the envelope of every API response. Data holds the result
+// and is usually absent when Status is StatusError, except where an
+// endpoint documents a partial result.
+type Response struct {
+	Status    string      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     *Error      `json:"error,omitempty"`
+	RequestID string      `json:"request_id"`
+}
+
+// Values of Response.Status.
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// Error explains why a request failed. Clients should branch on Code;
+// Message is meant for people and may change.
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// ErrorCode identifies the kind of failure.
+type ErrorCode string
+
+const (
+	// CodeInvalidRequest is a missing or malformed parameter or body.
+	CodeInvalidRequest   ErrorCode = "invalid_request"
+	CodeNotFound         ErrorCode = "not_found"
+	CodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	CodeJobNotFound      ErrorCode = "job_not_found"
+	CodeJobFinished      ErrorCode = "job_finished"
+	// CodeCommandRejected is a command refused by the command policy.
+	CodeCommandRejected ErrorCode = "command_rejected"
+	// CodeQueueFull comes with the jobs queued before the queue filled up.
+	CodeQueueFull         ErrorCode = "queue_full"
+	CodeWorkerStopped     ErrorCode = "worker_stopped"
+	CodeInvalidTransition ErrorCode = "invalid_transition"
+	CodeInternal          ErrorCode = "internal_error"
+)
+
+// RequestIDHeader carries the ID of a request. A client may choose the ID;
+// otherwise the server assigns one. Either way the response repeats it.
+const RequestIDHeader = "X-Request-ID"
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"time"
+
+	"file-mod-tracker/internal/core/domain"
+)
+
+// The types below are the request and response payloads of the API. They
+// mirror the domain types with snake_case keys, like the envelope, so that
+// the domain can change without changing the API.
+
+// RedactedValue stands in for the value of every environment variable of a
+// job in a response, since callers pass secrets in them.
+const RedactedValue = "[redacted]"
+
+// CommandSpec is a structured command in a request body.
+type CommandSpec struct {
+	Argv  []string          `json:"argv"`
+	Env   map[string]string `json:"env,omitempty"`
+	Cwd   string            `json:"cwd,omitempty"`
+	Stdin string            `json:"stdin,omitempty"`
+}
+
+type Health struct {
+	Status  string        `json:"status"`
+	Service ServiceStatus `json:"service"`
+	// Reporter is set when the instance reports changes to an endpoint.
+	Reporter *ReporterStatus `json:"reporter,omitempty"`
+}
+
+type ServiceStatus struct {
+	State domain.ServiceState `json:"state"`
+	Since time.Time           `json:"since"`
+}
+
+func NewServiceStatus(status domain.ServiceStatus) ServiceStatus {
+	return ServiceStatus{State: status.State, Since: status.Since}
+}
+
+type ReporterStatus struct {
+	Endpoint            string    `json:"endpoint"`
+	Delivered           uint64    `json:"delivered"`
+	Rejected            uint64    `json:"rejected"`
+	Dropped             uint64    `json:"dropped"`
+	Pending             int       `json:"pending"`
+	SpooledBatches      int       `json:"spooled_batches"`
+	SpooledBytes        int64     `json:"spooled_bytes"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastDelivery        time.Time `json:"last_delivery"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastErrorAt         time.Time `json:"last_error_at"`
+	NextAttempt         time.Time `json:"next_attempt"`
+}
+
+func NewReporterStatus(status domain.ReporterStatus) ReporterStatus {
+	return ReporterStatus{
+		Endpoint:            status.Endpoint,
+		Delivered:           status.Delivered,
+		Rejected:            status.Rejected,
+		Dropped:             status.Dropped,
+		Pending:             status.Pending,
+		SpooledBatches:      status.SpooledBatches,
+		SpooledBytes:        status.SpooledBytes,
+		ConsecutiveFailures: status.ConsecutiveFailures,
+		LastDelivery:        status.LastDelivery,
+		LastError:           status.LastError,
+		LastErrorAt:         status.LastErrorAt,
+		NextAttempt:         status.NextAttempt,
+	}
+}
+
+type FileInfo struct {
+	Path          string            `json:"path"`
+	LastModified  time.Time         `json:"last_modified"`
+	Size          int64             `json:"size"`
+	Mode          string            `json:"mode,omitempty"`
+	UID           uint32            `json:"uid"`
+	GID           uint32            `json:"gid"`
+	Owner         string            `json:"owner,omitempty"`
+	Group         string            `json:"group,omitempty"`
+	Inode         uint64            `json:"inode,omitempty"`
+	Device        uint64            `json:"device,omitempty"`
+	LinkCount     uint64            `json:"link_count,omitempty"`
+	ChangeTime    time.Time         `json:"change_time"`
+	AccessTime    time.Time         `json:"access_time"`
+	SymlinkTarget string            `json:"symlink_target,omitempty"`
+	Hashes        map[string]string `json:"hashes,omitempty"`
+}
+
+func NewFileInfo(file domain.FileInfo) FileInfo {
+	return FileInfo{
+		Path:          file.Path,
+		LastModified:  file.LastModified,
+		Size:          file.Size,
+		Mode:          file.Mode,
+		UID:           file.UID,
+		GID:           file.GID,
+		Owner:         file.Owner,
+		Group:         file.Group,
+		Inode:         file.Inode,
+		Device:        file.Device,
+		LinkCount:     file.LinkCount,
+		ChangeTime:    file.ChangeTime,
+		AccessTime:    file.AccessTime,
+		SymlinkTarget: file.SymlinkTarget,
+		Hashes:        file.Hashes,
+	}
+}
+
+func newFileInfoPtr(file *domain.FileInfo) *FileInfo {
+	if file == nil {
+		return nil
+	}
+	converted := NewFileInfo(*file)
+	return &converted
+}
+
+type ScanError struct {
+	Path    string `json:"path"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+type ScanReport struct {
+	Root           string      `json:"root"`
+	StartedAt      time.Time   `json:"started_at"`
+	DurationMillis int64       `json:"duration_millis"`
+	FilesScanned   int         `json:"files_scanned"`
+	ErrorCount     int         `json:"error_count"`
+	Errors         []ScanError `json:"errors,omitempty"`
+}
+
+func NewScanReport(report domain.ScanReport) ScanReport {
+	converted := ScanReport{
+		Root:           report.Root,
+		StartedAt:      report.StartedAt,
+		DurationMillis: report.DurationMillis,
+		FilesScanned:   report.FilesScanned,
+		ErrorCount:     report.ErrorCount,
+	}
+	for _, scanErr := range report.Errors {
+		converted.Errors = append(converted.Errors, ScanError(scanErr))
+	}
+	return converted
+}
+
+type ScanResult struct {
+	Files  []FileInfo `json:"files"`
+	Report ScanReport `json:"report"`
+}
+
+func NewScanResult(result domain.ScanResult) ScanResult {
+	files := make([]FileInfo, len(result.Files))
+	for i, file := range result.Files {
+		files[i] = NewFileInfo(file)
+	}
+	return ScanResult{Files: files, Report: NewScanReport(result.Report)}
+}
+
+type ChangeEvent struct {
+	Seq        uint64            `json:"seq,omitempty"`
+	Root       string            `json:"root,omitempty"`
+	Type       domain.ChangeType `json:"type"`
+	Path       string            `json:"path"`
+	Old        *FileInfo         `json:"old,omitempty"`
+	New        *FileInfo         `json:"new,omitempty"`
+	DetectedAt time.Time         `json:"detected_at"`
+}
+
+func NewChangeEvents(events []domain.ChangeEvent) []ChangeEvent {
+	converted := make([]ChangeEvent, len(events))
+	for i, event := range events {
+		converted[i] = ChangeEvent{
+			Seq:        event.Seq,
+			Root:       event.Root,
+			Type:       event.Type,
+			Path:       event.Path,
+			Old:        newFileInfoPtr(event.Old),
+			New:        newFileInfoPtr(event.New),
+			DetectedAt: event.DetectedAt,
+		}
+	}
+	return converted
+}
+
+type RootStatus struct {
+	Path           string      `json:"path"`
+	WatchMode      string      `json:"watch_mode"`
+	CheckFrequency int         `json:"check_frequency"`
+	LastScan       time.Time   `json:"last_scan"`
+	LastScanMillis int64       `json:"last_scan_millis"`
+	NextScan       time.Time   `json:"next_scan"`
+	FilesTracked   int         `json:"files_tracked"`
+	EventsRecorded uint64      `json:"events_recorded"`
+	LastError      string      `json:"last_error,omitempty"`
+	LastErrorAt    time.Time   `json:"last_error_at"`
+	LastReport     *ScanReport `json:"last_report,omitempty"`
+}
+
+func NewRootStatuses(roots []domain.RootStatus) []RootStatus {
+	converted := make([]RootStatus, len(roots))
+	for i, root := range roots {
+		converted[i] = RootStatus{
+			Path:           root.Path,
+			WatchMode:      root.WatchMode,
+			CheckFrequency: root.CheckFrequency,
+			LastScan:       root.LastScan,
+			LastScanMillis: root.LastScanMillis,
+			NextScan:       root.NextScan,
+			FilesTracked:   root.FilesTracked,
+			EventsRecorded: root.EventsRecorded,
+			LastError:      root.LastError,
+			LastErrorAt:    root.LastErrorAt,
+		}
+		if root.LastReport != nil {
+			report := NewScanReport(*root.LastReport)
+			converted[i].LastReport = &report
+		}
+	}
+	return converted
+}
+
+// Job is a job as the API returns it: the values of its environment are
+// replaced with RedactedValue and its standard input is left out.
+type Job struct {
+	ID            string            `json:"id"`
+	Command       string            `json:"command"`
+	Argv          []string          `json:"argv"`
+	Env           map[string]string `json:"env,omitempty"`
+	Cwd           string            `json:"cwd,omitempty"`
+	StdinBytes    int               `json:"stdin_bytes,omitempty"`
+	State         domain.JobState   `json:"state"`
+	ExitCode      *int              `json:"exit_code,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	TimeoutMillis int64             `json:"timeout_millis"`
+	Queue         string            `json:"queue,omitempty"`
+	Attempts      int               `json:"attempts"`
+	EnqueuedAt    time.Time         `json:"enqueued_at"`
+	StartedAt     time.Time         `json:"started_at"`
+	FinishedAt    time.Time         `json:"finished_at"`
+}
+
+func NewJob(job domain.Job) Job {
+	converted := Job{
+		ID:            job.ID,
+		Command:       job.Command,
+		Argv:          job.Argv,
+		Cwd:           job.Cwd,
+		StdinBytes:    job.StdinBytes,
+		State:         job.State,
+		ExitCode:      job.ExitCode,
+		Error:         job.Error,
+		TimeoutMillis: job.TimeoutMillis,
+		Queue:         job.Queue,
+		Attempts:      job.Attempts,
+		EnqueuedAt:    job.EnqueuedAt,
+		StartedAt:     job.StartedAt,
+		FinishedAt:    job.FinishedAt,
+	}
+	if len(job.Env) > 0 {
+		converted.Env = make(map[string]string, len(job.Env))
+		for name := range job.Env {
+			converted.Env[name] = RedactedValue
+		}
+	}
+	return converted
+}
+
+func NewJobs(jobs []domain.Job) []Job {
+	converted := make([]Job, len(jobs))
+	for i, job := range jobs {
+		converted[i] = NewJob(job)
+	}
+	return converted
+}
+
+type JobOutput struct {
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Truncated bool   `json:"truncated"`
+}
+
+func NewJobOutput(output domain.JobOutput) JobOutput {
+	return JobOutput(output)
+}
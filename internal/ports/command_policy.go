@@ -0,0 +1,24 @@
+package ports
+
+import (
+	"fmt"
+
+	"file-mod-tracker/internal/core/domain"
+)
+
+// CommandPolicy decides which commands the worker may run.
+type CommandPolicy interface {
+	// Check returns the absolute path of the executable to run for cmd, or
+	// a *CommandRejectedError explaining why cmd may not run.
+	Check(cmd domain.CommandSpec) (string, error)
+}
+
+// CommandRejectedError is returned when a CommandPolicy refuses a command.
+type CommandRejectedError struct {
+	Command string
+	Reason  string
+}
+
+func (e *CommandRejectedError) Error() string {
+	return fmt.Sprintf("command %q rejected: %s", e.Command, e.Reason)
+}
@@ -1,19 +1,118 @@
 package ports
 
-import "file-mod-tracker/internal/core/domain"
+import (
+	"errors"
+
+	"file-mod-tracker/internal/core/domain"
+)
+
+// ErrWatchLimit is returned by a FileWatcher when the operating system refuses
+// to watch more directories. Callers should fall back to polling.
+var ErrWatchLimit = errors.New("filesystem watch limit reached")
+
+// ErrQueueFull is returned when the worker cannot accept more commands.
+var ErrQueueFull = errors.New("command queue is full")
+
+// ErrWorkerStopped is returned when commands are enqueued after Stop.
+var ErrWorkerStopped = errors.New("worker is stopped")
+
+// ErrJobNotFound is returned for job IDs the worker does not know.
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobFinished is returned when cancelling a job that already finished.
+var ErrJobFinished = errors.New("job already finished")
+
+// ErrInvalidTransition is returned when the service cannot move from its
+// current lifecycle state as asked, for example when pausing a stopped
+// service.
+var ErrInvalidTransition = errors.New("invalid service state transition")
 
 type FileMonitorService interface {
 	GetFileStats(directory string) ([]domain.FileInfo, error)
-	EnqueueCommands(commands []string) error
+	// ScanDirectory is GetFileStats together with a report of the paths
+	// that could not be read.
+	ScanDirectory(directory string) (domain.ScanResult, error)
+	EnqueueCommands(commands []string, opts domain.EnqueueOptions) ([]domain.Job, error)
+	EnqueueJobs(specs []domain.CommandSpec, opts domain.EnqueueOptions) ([]domain.Job, error)
+	// Start, Stop, Pause and Resume move the monitoring service through its
+	// lifecycle. They return ErrInvalidTransition when the current state
+	// does not allow the move. Stop blocks until queued commands drained.
+	Start() error
+	Stop() error
+	Pause() error
+	Resume() error
+	Status() domain.ServiceStatus
 }
 
 type OsqueryAdapter interface {
 	GetFileStats(directory string) ([]domain.FileInfo, error)
+	// ScanFiles is GetFileStats limited to the part of the tree opts allows.
+	// Paths that cannot be read are skipped and listed in the report.
+	ScanFiles(directory string, opts domain.ScanOptions) (domain.ScanResult, error)
 }
 
 type WorkerAdapter interface {
-	EnqueueCommands(commands []string) error
+	// EnqueueCommands queues command lines, which are split into arguments
+	// with POSIX shell quoting rules but never run through a shell.
+	EnqueueCommands(commands []string, opts domain.EnqueueOptions) ([]domain.Job, error)
+	EnqueueJobs(specs []domain.CommandSpec, opts domain.EnqueueOptions) ([]domain.Job, error)
+	GetJobs() []domain.Job
+	GetJob(id string) (domain.Job, error)
+	GetJobOutput(id string) (domain.JobOutput, error)
+	// CancelJob cancels a queued job or kills a running one.
+	CancelJob(id string) error
 	Start()
 	Stop()
-	GetFileChanges() []domain.FileInfo
+	Pause()
+	Resume()
+	GetFileChanges(filter domain.EventFilter) []domain.ChangeEvent
+	GetRootStatuses() []domain.RootStatus
+}
+
+// Hasher fills in content hashes for scanned files.
+type Hasher interface {
+	HashFiles(files []domain.FileInfo, algorithms []string) []domain.FileInfo
+	// Prune forgets what is known about the files at or below root that are
+	// not in files.
+	Prune(root string, files []domain.FileInfo)
+}
+
+// EventStore is an append-only log of detected change events.
+type EventStore interface {
+	// Append assigns consecutive sequence numbers to the events and stores them.
+	Append(events []domain.ChangeEvent) error
+	Query(filter domain.EventFilter) ([]domain.ChangeEvent, error)
+	Close() error
+}
+
+// JobStore persists the command queue so that accepted jobs survive a
+// restart.
+type JobStore interface {
+	// Jobs returns the last recorded state of every stored job, oldest
+	// first.
+	Jobs() []domain.Job
+	// Save durably records the current state of the jobs before returning.
+	Save(jobs ...domain.Job) error
+	// Forget drops jobs that are no longer needed.
+	Forget(ids ...string) error
+	Close() error
+}
+
+// ChangeReporter forwards detected changes to an external receiver.
+type ChangeReporter interface {
+	// Report hands events over for delivery. It does not wait for the
+	// delivery itself.
+	Report(events []domain.ChangeEvent)
+	Status() domain.ReporterStatus
+}
+
+// FileWatcher delivers filesystem notifications for every directory below the
+// roots it was given, including directories created after Add was called.
+type FileWatcher interface {
+	Add(root string) error
+	Events() <-chan domain.WatchEvent
+	// Errors carries asynchronous watch failures, such as dropped events or
+	// running out of watches for a newly created directory.
+	Errors() <-chan error
+	Close() error
 }
@@ -0,0 +1,294 @@
+// Package client calls version 1 of the HTTP API of a running
+// file-mod-tracker instance.
+//
+//	c, err := client.New("http://localhost:8080", client.WithBearerToken(token))
+//	jobs, err := c.EnqueueCommands(ctx, []string{"make check"}, client.EnqueueOptions{})
+//	job, err := c.WaitJob(ctx, jobs[0].ID, time.Second)
+//
+// Requests that are safe to repeat are retried when the connection fails or
+// the server answers 429, 502, 503 or 504.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"file-mod-tracker/internal/ports/api"
+)
+
+// Retry defaults; see WithRetries.
+const (
+	DefaultRetries    = 2
+	DefaultMinBackoff = 200 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// maxErrorBody caps how much of an error response ends up in Error.Message.
+const maxErrorBody = 4096
+
+// apiPrefix is where the API version the client speaks lives.
+const apiPrefix = "/api/v1"
+
+// Error is returned when the server answers with an error status.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Code is what failed, such as CodeJobNotFound. It is empty when the
+	// answer did not come from the API, for example from a proxy.
+	Code ErrorCode
+	// Message is the server's explanation, such as "Job not found".
+	Message string
+	// RequestID identifies the request in the server's log.
+	RequestID string
+	// Attempts is how often the request was sent; more than one when it
+	// was retried.
+	Attempts int
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf("%s %s: %d %s: %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+	if e.RequestID != "" {
+		message += " (request " + e.RequestID + ")"
+	}
+	return message
+}
+
+// ErrQueueFull is returned by the enqueue methods when the worker accepted
+// only some of the commands. The jobs that were queued are returned with it.
+var ErrQueueFull = errors.New("command queue is full")
+
+// envelope is the api.Response the server wraps every answer in, with the
+// data left for the caller to decode.
+type envelope struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	Error     *api.Error      `json:"error"`
+	RequestID string          `json:"request_id"`
+}
+
+// Client calls the API of one instance. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	header     http.Header
+	retries    int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Option customises a Client at construction time.
+type Option func(*Client)
+
+// WithHTTPClient sends requests through httpClient instead of a client with
+// a 30 second timeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithHeader adds a header to every request, for example an API key that a
+// proxy in front of the instance checks.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithBearerToken sends token in the Authorization header of every request.
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithRetries retries requests that are safe to repeat up to retries times,
+// waiting minBackoff before the first retry and doubling the wait up to
+// maxBackoff. Zero retries turns retrying off.
+func WithRetries(retries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New returns a client for the instance at baseURL, such as
+// "http://localhost:8080".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: must be an http or https URL", baseURL)
+	}
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		header:     make(http.Header),
+		retries:    DefaultRetries,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// do sends a request to the API path below /api/v1 and decodes the data of
+// the response into out. For failed requests it returns an *Error and still
+// decodes any data the server sent along.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	target := c.baseURL + apiPrefix + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	// Every attempt carries the same request ID
+	id := c.header.Get(api.RequestIDHeader)
+	if id == "" {
+		id = newRequestID()
+	}
+	backoff := c.minBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, target, payload, id)
+		retry := attempt < c.retries && idempotent(method) && ctx.Err() == nil
+		if err != nil {
+			if !retry {
+				return err
+			}
+		} else if !retry || !retryable(resp.StatusCode) {
+			defer resp.Body.Close()
+			err := decode(resp, method, path, out)
+			var apiErr *Error
+			if errors.As(err, &apiErr) {
+				apiErr.Attempts = attempt + 1
+			}
+			return err
+		} else {
+			if wait, ok := retryAfter(resp); ok {
+				backoff = wait
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+			resp.Body.Close()
+		}
+
+		if backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target string, payload []byte, requestID string) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	req.Header.Set(api.RequestIDHeader, requestID)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.httpClient.Do(req)
+}
+
+func decode(resp *http.Response, method, path string, out interface{}) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s: read response: %w", method, path, err)
+	}
+	var response envelope
+	if err := json.Unmarshal(body, &response); err != nil || response.Status == "" {
+		if resp.StatusCode >= 300 {
+			// Not an answer of the API, such as an error page of a proxy
+			if len(body) > maxErrorBody {
+				body = body[:maxErrorBody]
+			}
+			return &Error{Method: method, Path: path, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+		}
+		return fmt.Errorf("%s %s: response is not an API envelope", method, path)
+	}
+
+	if out != nil && len(response.Data) > 0 && string(response.Data) != "null" {
+		if err := json.Unmarshal(response.Data, out); err != nil {
+			return fmt.Errorf("%s %s: decode response: %w", method, path, err)
+		}
+	}
+	if response.Status == api.StatusOK && resp.StatusCode < 300 {
+		return nil
+	}
+	apiErr := &Error{Method: method, Path: path, StatusCode: resp.StatusCode, RequestID: response.RequestID}
+	if response.Error != nil {
+		apiErr.Code, apiErr.Message = response.Error.Code, response.Error.Message
+	}
+	return apiErr
+}
+
+// idempotent reports whether a request may be sent again after a failure
+// without the risk of running commands twice.
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodDelete
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter reads a Retry-After header given in seconds.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// newRequestID names a request so that all of its attempts can be found in
+// the server's log.
+func newRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tracker "file-mod-tracker/internal/adapters/http"
+	"file-mod-tracker/internal/adapters/osquery"
+	"file-mod-tracker/internal/adapters/worker"
+	"file-mod-tracker/internal/core/policy"
+	"file-mod-tracker/internal/core/service"
+	"file-mod-tracker/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newInstance serves the real HTTP handlers of a tracker monitoring dir,
+// scanning it every second.
+func newInstance(t *testing.T, dir string) *Client {
+	log := logger.NewConsoleLogger(io.Discard, false)
+	osqueryAdapter := osquery.NewAdapter(log)
+	commandPolicy, err := policy.New(policy.Rules{AllowedExecutables: []string{"echo", "false"}})
+	require.NoError(t, err)
+	workerAdapter := worker.NewAdapter(log, osqueryAdapter, dir, 1, worker.WithPolicy(commandPolicy))
+	fileMonitorService := service.NewFileMonitorService(osqueryAdapter, workerAdapter, log)
+	require.NoError(t, fileMonitorService.Start())
+
+	server := httptest.NewServer(tracker.NewServer(fileMonitorService, log, workerAdapter).Handler())
+	t.Cleanup(func() {
+		server.Close()
+		fileMonitorService.Stop()
+	})
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+	return c
+}
+
+func TestNew_RejectsInvalidBaseURL(t *testing.T) {
+	_, err := New("localhost:8080")
+	assert.Error(t, err)
+}
+
+func TestClient_FileStats(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0o644))
+	c := newInstance(t, dir)
+
+	result, err := c.FileStats(context.Background(), dir, TimeRange{})
+
+	require.NoError(t, err)
+	require.Len(t, result.Files, 1)
+	assert.Equal(t, filepath.Join(dir, "a.txt"), result.Files[0].Path)
+	assert.Equal(t, int64(5), result.Files[0].Size)
+
+	_, err = c.FileStats(context.Background(), "", TimeRange{})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, CodeInvalidRequest, apiErr.Code)
+	assert.Contains(t, apiErr.Message, "directory")
+	assert.NotEmpty(t, apiErr.RequestID)
+}
+
+func TestClient_Jobs(t *testing.T) {
+	c := newInstance(t, t.TempDir())
+	ctx := context.Background()
+
+	jobs, err := c.EnqueueCommands(ctx, []string{"echo hello"}, EnqueueOptions{Timeout: time.Minute})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, time.Minute.Milliseconds(), jobs[0].TimeoutMillis)
+
+	job, err := c.WaitJob(ctx, jobs[0].ID, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, JobSucceeded, job.State)
+	output, err := c.JobOutput(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", output.Stdout)
+
+	jobs, err = c.EnqueueJobs(ctx, []CommandSpec{{Argv: []string{"false"}}}, EnqueueOptions{})
+	require.NoError(t, err)
+	job, err = c.WaitJob(ctx, jobs[0].ID, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.NotNil(t, job.ExitCode)
+	assert.Equal(t, 1, *job.ExitCode)
+
+	failed, err := c.Jobs(ctx, JobFailed)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, job.ID, failed[0].ID)
+
+	_, err = c.CancelJob(ctx, "missing")
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, CodeJobNotFound, apiErr.Code)
+}
+
+func TestClient_FollowChanges(t *testing.T) {
+	dir := t.TempDir()
+	c := newInstance(t, dir)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Wait for the baseline scan before creating the file
+	require.Eventually(t, func() bool {
+		roots, err := c.Roots(ctx)
+		return err == nil && len(roots) == 1 && !roots[0].LastScan.IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("x"), 0o644))
+
+	var got []ChangeEvent
+	done := errors.New("done")
+	err := c.FollowChanges(ctx, 0, 20*time.Millisecond, func(event ChangeEvent) error {
+		got = append(got, event)
+		return done
+	})
+
+	require.ErrorIs(t, err, done)
+	require.Len(t, got, 1)
+	assert.Equal(t, ChangeCreated, got[0].Type)
+	assert.Equal(t, filepath.Join(dir, "new.txt"), got[0].Path)
+
+	later, err := c.Changes(ctx, ChangeQuery{AfterSeq: got[0].Seq})
+	require.NoError(t, err)
+	assert.Empty(t, later)
+}
+
+func TestClient_ServiceLifecycle(t *testing.T) {
+	c := newInstance(t, t.TempDir())
+	ctx := context.Background()
+
+	status, err := c.PauseService(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ServicePaused, status.State)
+
+	_, err = c.PauseService(ctx)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	assert.Equal(t, CodeInvalidTransition, apiErr.Code)
+
+	status, err = c.ResumeService(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ServiceRunning, status.State)
+	health, err := c.Health(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "OK", health.Status)
+	assert.Equal(t, ServiceRunning, health.Service.State)
+}
+
+func TestClient_RetriesIdempotentRequestsOnly(t *testing.T) {
+	var requests atomic.Int32
+	requestIDs := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/jobs", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "team-a", r.Header.Get("X-Team"))
+		requestIDs[r.Header.Get("X-Request-ID")] = true
+		if requests.Add(1) < 3 {
+			http.Error(w, "Try again", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":"ok","data":[],"request_id":"abc"}`)
+	}))
+	defer server.Close()
+	c, err := New(server.URL, WithBearerToken("secret"), WithHeader("X-Team", "team-a"), WithRetries(2, time.Millisecond, time.Millisecond))
+	require.NoError(t, err)
+
+	jobs, err := c.Jobs(context.Background(), "")
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+	assert.Equal(t, int32(3), requests.Load())
+	assert.Len(t, requestIDs, 1, "retries reuse the request ID")
+
+	// Retrying an enqueue could run the commands twice
+	requests.Store(0)
+	_, err = c.EnqueueCommands(context.Background(), []string{"true"}, EnqueueOptions{})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Equal(t, "Try again", apiErr.Message)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestClient_RetriedCancelOfCancelledJobSucceeds(t *testing.T) {
+	var deletes atomic.Int32
+	var state atomic.Value
+	state.Store("running")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/jobs/7", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet:
+			fmt.Fprintf(w, `{"status":"ok","data":{"id":"7","state":%q},"request_id":"abc"}`, state.Load())
+		case deletes.Add(1) == 1 && state.Load() == "running":
+			// The job is cancelled, but the answer is lost on the way
+			state.Store("cancelled")
+			http.Error(w, "Bad gateway", http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `{"status":"error","error":{"code":"job_finished","message":"Job already finished"},"request_id":"abc"}`)
+		}
+	}))
+	defer server.Close()
+	c, err := New(server.URL, WithRetries(2, time.Millisecond, time.Millisecond))
+	require.NoError(t, err)
+
+	job, err := c.CancelJob(context.Background(), "7")
+	require.NoError(t, err)
+	assert.Equal(t, JobCancelled, job.State)
+	assert.Equal(t, int32(2), deletes.Load())
+
+	// A job that had finished on its own was not cancelled
+	deletes.Store(0)
+	state.Store("succeeded")
+	_, err = c.CancelJob(context.Background(), "7")
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, CodeJobFinished, apiErr.Code)
+	assert.Equal(t, 1, apiErr.Attempts)
+}
@@ -0,0 +1,215 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Health reports whether the instance is up, with the state of its
+// monitoring service and change reporter.
+func (c *Client) Health(ctx context.Context) (Health, error) {
+	var health Health
+	err := c.do(ctx, http.MethodGet, "/health", nil, nil, &health)
+	return health, err
+}
+
+// FileStats scans directory on the instance. Files last modified outside
+// modified are left out; the zero TimeRange keeps every file.
+func (c *Client) FileStats(ctx context.Context, directory string, modified TimeRange) (ScanResult, error) {
+	query := timeRangeQuery(modified)
+	query.Set("directory", directory)
+	var result ScanResult
+	err := c.do(ctx, http.MethodGet, "/file-stats", query, nil, &result)
+	return result, err
+}
+
+// Changes returns the change events the instance recorded, oldest first.
+func (c *Client) Changes(ctx context.Context, q ChangeQuery) ([]ChangeEvent, error) {
+	query := timeRangeQuery(q.TimeRange)
+	if q.AfterSeq > 0 {
+		query.Set("after", strconv.FormatUint(q.AfterSeq, 10))
+	}
+	var events []ChangeEvent
+	err := c.do(ctx, http.MethodGet, "/logs", query, nil, &events)
+	return events, err
+}
+
+// Roots reports how monitoring of every watch root is going.
+func (c *Client) Roots(ctx context.Context) ([]RootStatus, error) {
+	var roots []RootStatus
+	err := c.do(ctx, http.MethodGet, "/roots", nil, nil, &roots)
+	return roots, err
+}
+
+// EnqueueCommands queues command lines, which the instance splits into
+// arguments with POSIX shell quoting rules. When the queue fills up it
+// returns the jobs queued so far together with ErrQueueFull.
+func (c *Client) EnqueueCommands(ctx context.Context, commands []string, opts EnqueueOptions) ([]Job, error) {
+	return c.enqueue(ctx, commands, opts)
+}
+
+// EnqueueJobs queues commands that are already split into arguments, with
+// their environment, working directory and standard input.
+func (c *Client) EnqueueJobs(ctx context.Context, specs []CommandSpec, opts EnqueueOptions) ([]Job, error) {
+	return c.enqueue(ctx, specs, opts)
+}
+
+func (c *Client) enqueue(ctx context.Context, body interface{}, opts EnqueueOptions) ([]Job, error) {
+	query := url.Values{}
+	if opts.Timeout > 0 {
+		query.Set("timeout", opts.Timeout.String())
+	}
+	if opts.Queue != "" {
+		query.Set("queue", opts.Queue)
+	}
+
+	var jobs []Job
+	err := c.do(ctx, http.MethodPost, "/jobs", query, body, &jobs)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Code == CodeQueueFull {
+		return jobs, fmt.Errorf("%w: %d commands were queued", ErrQueueFull, len(jobs))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Jobs lists the jobs the instance knows, or only those in state when it is
+// not empty.
+func (c *Client) Jobs(ctx context.Context, state JobState) ([]Job, error) {
+	query := url.Values{}
+	if state != "" {
+		query.Set("state", string(state))
+	}
+	var jobs []Job
+	err := c.do(ctx, http.MethodGet, "/jobs", query, nil, &jobs)
+	return jobs, err
+}
+
+// Job returns the current state of one job.
+func (c *Client) Job(ctx context.Context, id string) (Job, error) {
+	var job Job
+	err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), nil, nil, &job)
+	return job, err
+}
+
+// JobOutput returns what the job wrote to stdout and stderr so far.
+func (c *Client) JobOutput(ctx context.Context, id string) (JobOutput, error) {
+	var output JobOutput
+	err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id)+"/output", nil, nil, &output)
+	return output, err
+}
+
+// CancelJob cancels a queued job or kills a running one. A running job is
+// only marked cancelled once its process has exited; use WaitJob to wait
+// for that.
+func (c *Client) CancelJob(ctx context.Context, id string) (Job, error) {
+	var job Job
+	err := c.do(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(id), nil, nil, &job)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Code == CodeJobFinished && apiErr.Attempts > 1 {
+		// An earlier attempt may have cancelled the job before its answer
+		// was lost.
+		if current, getErr := c.Job(ctx, id); getErr == nil && current.State == JobCancelled {
+			return current, nil
+		}
+	}
+	return job, err
+}
+
+// WaitJob polls the job every interval until it has finished or ctx is
+// done.
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (Job, error) {
+	for {
+		job, err := c.Job(ctx, id)
+		if err != nil || job.State.Finished() {
+			return job, err
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return job, err
+		}
+	}
+}
+
+// FollowChanges calls fn with every change event recorded after the one
+// numbered afterSeq, in order, polling the instance every interval. Pass
+// zero to start with the oldest event the instance has. It returns when ctx
+// is done, with ctx's error, or when fn or a request fails.
+func (c *Client) FollowChanges(ctx context.Context, afterSeq uint64, interval time.Duration, fn func(ChangeEvent) error) error {
+	for {
+		events, err := c.Changes(ctx, ChangeQuery{AfterSeq: afterSeq})
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+			afterSeq = event.Seq
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+// Service reports the lifecycle state of the monitoring service.
+func (c *Client) Service(ctx context.Context) (ServiceStatus, error) {
+	var status ServiceStatus
+	err := c.do(ctx, http.MethodGet, "/service", nil, nil, &status)
+	return status, err
+}
+
+// StartService, StopService, PauseService and ResumeService move the
+// monitoring service through its lifecycle and return its new status. The
+// server answers with CodeInvalidTransition when the current state does not
+// allow the move. StopService waits for the queued commands to drain.
+func (c *Client) StartService(ctx context.Context) (ServiceStatus, error) {
+	return c.serviceAction(ctx, "start")
+}
+
+func (c *Client) StopService(ctx context.Context) (ServiceStatus, error) {
+	return c.serviceAction(ctx, "stop")
+}
+
+func (c *Client) PauseService(ctx context.Context) (ServiceStatus, error) {
+	return c.serviceAction(ctx, "pause")
+}
+
+func (c *Client) ResumeService(ctx context.Context) (ServiceStatus, error) {
+	return c.serviceAction(ctx, "resume")
+}
+
+func (c *Client) serviceAction(ctx context.Context, action string) (ServiceStatus, error) {
+	var status ServiceStatus
+	err := c.do(ctx, http.MethodPost, "/service/"+action, nil, nil, &status)
+	return status, err
+}
+
+func timeRangeQuery(r TimeRange) url.Values {
+	query := url.Values{}
+	if !r.Since.IsZero() {
+		query.Set("since", r.Since.Format(time.RFC3339Nano))
+	}
+	if !r.Until.IsZero() {
+		query.Set("until", r.Until.Format(time.RFC3339Nano))
+	}
+	return query
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"file-mod-tracker/internal/core/domain"
+	"file-mod-tracker/internal/ports/api"
+)
+
+// The request and response types are the server's own, so that they cannot
+// drift apart.
+type (
+	Health         = api.Health
+	FileInfo       = api.FileInfo
+	ScanResult     = api.ScanResult
+	ScanReport     = api.ScanReport
+	ScanError      = api.ScanError
+	ChangeEvent    = api.ChangeEvent
+	ChangeType     = domain.ChangeType
+	TimeRange      = domain.TimeRange
+	Job            = api.Job
+	JobState       = domain.JobState
+	JobOutput      = api.JobOutput
+	CommandSpec    = api.CommandSpec
+	EnqueueOptions = domain.EnqueueOptions
+	RootStatus     = api.RootStatus
+	ServiceState   = domain.ServiceState
+	ServiceStatus  = api.ServiceStatus
+	ReporterStatus = api.ReporterStatus
+	ErrorCode      = api.ErrorCode
+)
+
+// Values of Error.Code.
+const (
+	CodeInvalidRequest    = api.CodeInvalidRequest
+	CodeNotFound          = api.CodeNotFound
+	CodeMethodNotAllowed  = api.CodeMethodNotAllowed
+	CodeJobNotFound       = api.CodeJobNotFound
+	CodeJobFinished       = api.CodeJobFinished
+	CodeCommandRejected   = api.CodeCommandRejected
+	CodeQueueFull         = api.CodeQueueFull
+	CodeWorkerStopped     = api.CodeWorkerStopped
+	CodeInvalidTransition = api.CodeInvalidTransition
+	CodeInternal          = api.CodeInternal
+)
+
+const (
+	ChangeCreated     = domain.ChangeCreated
+	ChangeModified    = domain.ChangeModified
+	ChangeDeleted     = domain.ChangeDeleted
+	ChangeSizeChanged = domain.ChangeSizeChanged
+	ChangePermissions = domain.ChangePermissions
+	ChangeOwnership   = domain.ChangeOwnership
+)
+
+const (
+	JobQueued      = domain.JobQueued
+	JobRunning     = domain.JobRunning
+	JobSucceeded   = domain.JobSucceeded
+	JobFailed      = domain.JobFailed
+	JobCancelled   = domain.JobCancelled
+	JobInterrupted = domain.JobInterrupted
+)
+
+const (
+	ServiceStopped  = domain.ServiceStopped
+	ServiceRunning  = domain.ServiceRunning
+	ServicePaused   = domain.ServicePaused
+	ServiceStopping = domain.ServiceStopping
+)
+
+// ChangeQuery selects the change events returned by Changes. The zero value
+// selects every event.
+type ChangeQuery struct {
+	TimeRange
+	// AfterSeq skips the events up to and including this sequence number.
+	AfterSeq uint64
+}
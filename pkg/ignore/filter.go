@@ -0,0 +1,137 @@
+package ignore
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Options mirrors the scan settings of a watch root.
+type Options struct {
+	// MaxDepth limits how many directory levels below the root are
+	// scanned. Files directly in the root have depth 1; zero means
+	// unlimited.
+	MaxDepth int
+	// Include lists doublestar globs a file must match, either with its
+	// path relative to the root or with its base name. Empty includes
+	// every file.
+	Include []string
+	// Exclude lists gitignore-style rules anchored at the root.
+	Exclude []string
+}
+
+// Filter applies Options and the .fmtignore files below root to paths
+// relative to root. Ignore files are read lazily and cached, so a Filter
+// should live for one scan. It is not safe for concurrent use.
+type Filter struct {
+	root     string
+	maxDepth int
+	include  []string
+	exclude  []Rule
+	rules    map[string][]Rule
+	errs     []error
+}
+
+func NewFilter(root string, opts Options) *Filter {
+	// Invalid rules are rejected when the configuration is loaded.
+	exclude, _ := Parse("", opts.Exclude)
+	return &Filter{
+		root:     root,
+		maxDepth: opts.MaxDepth,
+		include:  opts.Include,
+		exclude:  exclude,
+		rules:    make(map[string][]Rule),
+	}
+}
+
+// SkipDir reports whether the directory at rel should not be entered.
+func (f *Filter) SkipDir(rel string) bool {
+	if f.maxDepth > 0 && depth(rel) >= f.maxDepth {
+		return true
+	}
+	return Ignored(f.rulesFor(parent(rel)), rel, true)
+}
+
+// IncludeFile reports whether the file at rel is reported, assuming none of
+// its parent directories was skipped.
+func (f *Filter) IncludeFile(rel string) bool {
+	if f.maxDepth > 0 && depth(rel) > f.maxDepth {
+		return false
+	}
+	if Ignored(f.rulesFor(parent(rel)), rel, false) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, rel)
+}
+
+// Allows reports whether the file at rel is reported, checking its parent
+// directories as well. It is meant for results that did not come from a
+// pruned walk.
+func (f *Filter) Allows(rel string) bool {
+	for dir := parent(rel); dir != ""; dir = parent(dir) {
+		if f.SkipDir(dir) {
+			return false
+		}
+	}
+	return f.IncludeFile(rel)
+}
+
+// Errors reports ignore files that could not be read or parsed, each as an
+// *fs.PathError. The valid rules of a file that failed to parse are applied
+// regardless.
+func (f *Filter) Errors() []error {
+	return f.errs
+}
+
+// rulesFor returns the rules that apply to entries of dir: the configured
+// rules followed by those of every ignore file from the root down to dir.
+func (f *Filter) rulesFor(dir string) []Rule {
+	if rules, ok := f.rules[dir]; ok {
+		return rules
+	}
+	inherited := f.exclude
+	if dir != "" {
+		inherited = f.rulesFor(parent(dir))
+	}
+	own, err := ReadFile(filepath.Join(f.root, filepath.FromSlash(dir), FileName), dir)
+	if err != nil {
+		f.errs = append(f.errs, err)
+	}
+	rules := inherited
+	if len(own) > 0 {
+		rules = append(inherited[:len(inherited):len(inherited)], own...)
+	}
+	f.rules[dir] = rules
+	return rules
+}
+
+// parent returns the directory containing rel, or "" for the root.
+func parent(rel string) string {
+	if rel == "" {
+		return ""
+	}
+	dir := path.Dir(rel)
+	if dir == "." || dir == "/" {
+		return ""
+	}
+	return dir
+}
+
+func depth(rel string) int {
+	return strings.Count(rel, "/") + 1
+}
+
+func matchAny(patterns []string, rel string) bool {
+	base := path.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := doublestar.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
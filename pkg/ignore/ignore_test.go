@@ -0,0 +1,106 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnored_GitignoreSemantics(t *testing.T) {
+	rules, err := Parse("", []string{
+		"# editor files",
+		"*.swp",
+		"build/",
+		"/TODO",
+		"docs/**/*.pdf",
+		"*.log",
+		"!important.log",
+		`\#literal`,
+		"trailing   ",
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		rel     string
+		isDir   bool
+		ignored bool
+	}{
+		{"notes.swp", false, true},
+		{"a/b/notes.swp", false, true},
+		{"build", true, true},
+		{"src/build", true, true},
+		{"build", false, false},
+		{"TODO", false, true},
+		{"src/TODO", false, false},
+		{"docs/a/b/guide.pdf", false, true},
+		{"docs/guide.pdf", false, true},
+		{"other/guide.pdf", false, false},
+		{"debug.log", false, true},
+		{"logs/important.log", false, false},
+		{"#literal", false, true},
+		{"trailing", false, true},
+		{"main.go", false, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.ignored, Ignored(rules, c.rel, c.isDir), c.rel)
+	}
+}
+
+func TestParse_RejectsInvalidPatterns(t *testing.T) {
+	rules, err := Parse("", []string{"[unclosed", "*.tmp"})
+	assert.Error(t, err)
+	assert.Len(t, rules, 1)
+
+	assert.Error(t, Validate(Options{Include: []string{"{a,b"}}))
+	assert.NoError(t, Validate(Options{Include: []string{"**/*.conf"}, Exclude: []string{"!keep"}}))
+}
+
+func TestFilter_Options(t *testing.T) {
+	filter := NewFilter(t.TempDir(), Options{MaxDepth: 2, Include: []string{"*.conf", "**/*.yaml"}, Exclude: []string{"cache", "secret.*"}})
+
+	assert.True(t, filter.IncludeFile("app.conf"))
+	assert.True(t, filter.IncludeFile("nginx/site.conf"))
+	assert.True(t, filter.IncludeFile("k8s/deploy.yaml"))
+	assert.False(t, filter.IncludeFile("nginx/site.log"))
+	assert.False(t, filter.IncludeFile("secret.conf"))
+	assert.False(t, filter.IncludeFile("a/b/deep.conf"))
+
+	assert.False(t, filter.SkipDir("nginx"))
+	assert.True(t, filter.SkipDir("cache"))
+	assert.True(t, filter.SkipDir("nginx/sites"))
+
+	assert.True(t, filter.Allows("nginx/site.conf"))
+	assert.False(t, filter.Allows("cache/site.conf"))
+}
+
+func TestFilter_ZeroOptionsAllowEverything(t *testing.T) {
+	filter := NewFilter(t.TempDir(), Options{})
+
+	assert.False(t, filter.SkipDir("a/b/c/d"))
+	assert.True(t, filter.Allows("a/b/c/d/file.txt"))
+}
+
+func TestFilter_IgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, FileName), []byte("*.tmp\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "app", "cache"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "app", FileName), []byte("!keep.tmp\n/cache/\n[bad\n"), 0o644))
+
+	filter := NewFilter(root, Options{Exclude: []string{"*.bak"}})
+
+	assert.False(t, filter.Allows("scratch.tmp"))
+	assert.False(t, filter.Allows("old.bak"))
+	assert.True(t, filter.Allows("app/keep.tmp"))
+	assert.False(t, filter.Allows("app/other.tmp"))
+	assert.True(t, filter.SkipDir("app/cache"))
+	assert.False(t, filter.Allows("app/cache/data.bin"))
+	// Rules of app/.fmtignore do not reach outside app.
+	assert.False(t, filter.SkipDir("cache"))
+	assert.False(t, filter.Allows("keep.tmp"))
+
+	require.Len(t, filter.Errors(), 1)
+	assert.ErrorContains(t, filter.Errors()[0], "invalid ignore pattern")
+}
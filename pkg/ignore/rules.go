@@ -0,0 +1,159 @@
+// Package ignore decides which paths a scan skips. Exclude rules follow
+// gitignore semantics on top of doublestar globs, and every directory may
+// add rules of its own in a .fmtignore file.
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// FileName is the per-directory ignore file. Its rules apply to everything
+// below the directory it is in.
+const FileName = ".fmtignore"
+
+// Rule is a single parsed ignore line.
+type Rule struct {
+	// pattern is matched against paths relative to base.
+	pattern string
+	// base is the slash-separated directory the rule was defined in,
+	// relative to the scanned root; empty for the root itself.
+	base    string
+	negate  bool
+	dirOnly bool
+}
+
+// Parse turns gitignore-style lines into rules anchored at base. Blank lines
+// and comments are skipped. Invalid patterns are reported in the returned
+// error while the valid rules are still returned.
+func Parse(base string, lines []string) ([]Rule, error) {
+	var rules []Rule
+	var errs []error
+	for _, line := range lines {
+		rule, ok, err := parseLine(base, line)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, errors.Join(errs...)
+}
+
+// ReadFile parses the ignore file at name, anchoring its rules at base. A
+// missing file yields no rules and no error.
+func ReadFile(name, base string) ([]Rule, error) {
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	rules, err := Parse(base, lines)
+	if err != nil {
+		err = &fs.PathError{Op: "parse", Path: name, Err: err}
+	}
+	return rules, err
+}
+
+// Validate reports invalid include globs and exclude rules in opts.
+func Validate(opts Options) error {
+	var errs []error
+	for _, pattern := range opts.Include {
+		if !doublestar.ValidatePattern(pattern) {
+			errs = append(errs, fmt.Errorf("invalid include pattern %q", pattern))
+		}
+	}
+	if _, err := Parse("", opts.Exclude); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func parseLine(base, line string) (Rule, bool, error) {
+	line = trimTrailingSpace(strings.TrimSuffix(line, "\r"))
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Rule{}, false, nil
+	}
+
+	rule := Rule{base: base}
+	switch {
+	case strings.HasPrefix(line, "!"):
+		rule.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return Rule{}, false, nil
+	}
+
+	// A slash anywhere but at the end anchors the pattern to base; other
+	// patterns match at any depth.
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	if !doublestar.ValidatePattern(line) {
+		return Rule{}, false, fmt.Errorf("invalid ignore pattern %q", line)
+	}
+	rule.pattern = line
+	return rule, true, nil
+}
+
+// trimTrailingSpace drops trailing spaces unless they are escaped.
+func trimTrailingSpace(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// Ignored reports whether rel is excluded by rules. Later rules take
+// precedence, so a negated rule re-includes what an earlier one excluded.
+func Ignored(rules []Rule, rel string, isDir bool) bool {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].match(rel, isDir) {
+			return !rules[i].negate
+		}
+	}
+	return false
+}
+
+func (r Rule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	ok, _ := doublestar.Match(r.pattern, rel)
+	return ok
+}
@@ -4,6 +4,7 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -14,25 +15,34 @@ type Logger interface {
 	Fatal(s string, keysAndValues ...interface{})
 }
 
+// File is where NewLogger writes the service log.
+const File = "logs/service.log"
+
 type zapLogger struct {
 	log *zap.SugaredLogger
 }
 
 func NewLogger() (Logger, error) {
-	logDir := "logs"
+	logDir := filepath.Dir(File)
 	if err := os.MkdirAll(logDir, os.ModePerm); err != nil {
 		return nil, err
 	}
 
 	fileLogger := &lumberjack.Logger{
-		Filename:   filepath.Join(logDir, "service.log"),
+		Filename:   File,
 		MaxSize:    10, // this is 10 megabytes.
 		MaxAge:     30, // keeping for 30 days.
 		MaxBackups: 3,
 	}
 
 	consoleEncoder := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{})
-	fileEncoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+	fileEncoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		TimeKey:     "ts",
+		LevelKey:    "level",
+		MessageKey:  "msg",
+		EncodeTime:  zapcore.ISO8601TimeEncoder,
+		EncodeLevel: zapcore.LowercaseLevelEncoder,
+	})
 
 	core := zapcore.NewTee(
 		zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), zapcore.DebugLevel),
@@ -44,6 +54,23 @@ func NewLogger() (Logger, error) {
 	return &zapLogger{log: logger.Sugar()}, nil
 }
 
+// NewConsoleLogger logs to w only, without a log file, for one-shot
+// commands whose standard output is their result. Info messages are dropped
+// unless verbose is set.
+func NewConsoleLogger(w io.Writer, verbose bool) Logger {
+	level := zapcore.ErrorLevel
+	if verbose {
+		level = zapcore.DebugLevel
+	}
+	encoder := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
+		MessageKey:  "msg",
+		LevelKey:    "level",
+		EncodeLevel: zapcore.LowercaseLevelEncoder,
+	})
+	core := zapcore.NewCore(encoder, zapcore.AddSync(w), level)
+	return &zapLogger{log: zap.New(core).Sugar()}
+}
+
 func (l *zapLogger) Info(msg string, keysAndValues ...interface{}) {
 	l.log.Infow(msg, keysAndValues...)
 }
@@ -55,3 +82,16 @@ func (l *zapLogger) Error(msg string, keysAndValues ...interface{}) {
 func (l *zapLogger) Fatal(msg string, keysAndValues ...interface{}) {
 	l.log.Fatalw(msg, keysAndValues...)
 }
+
+// Sync flushes buffered log entries.
+func (l *zapLogger) Sync() error {
+	return l.log.Sync()
+}
+
+// Sync flushes the entries l has buffered, if it buffers any.
+func Sync(l Logger) error {
+	if syncer, ok := l.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
@@ -48,3 +48,17 @@ func TestZapLogger_Error(t *testing.T) {
 	assert.Contains(t, buf.String(), `"level":"error"`)
 	assert.Contains(t, buf.String(), `"key":"value"`)
 }
+
+func TestConsoleLogger_DropsInfoUnlessVerbose(t *testing.T) {
+	var buf bytes.Buffer
+	quiet := NewConsoleLogger(&buf, false)
+	quiet.Info("info message")
+	quiet.Error("error message", "key", "value")
+	assert.NotContains(t, buf.String(), "info message")
+	assert.Contains(t, buf.String(), "error\terror message")
+	assert.Contains(t, buf.String(), `"key": "value"`)
+
+	buf.Reset()
+	NewConsoleLogger(&buf, true).Info("info message")
+	assert.Contains(t, buf.String(), "info\tinfo message")
+}
@@ -0,0 +1,108 @@
+// Package shellwords splits command lines into arguments the way a POSIX
+// shell does, without performing any expansion.
+package shellwords
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrUnterminatedQuote = errors.New("unterminated quote")
+	ErrTrailingBackslash = errors.New("trailing backslash")
+)
+
+// Split breaks line into words. Unquoted blanks separate words, a backslash
+// quotes the next character, single quotes preserve everything up to the
+// closing quote, and inside double quotes a backslash only escapes $, `, ",
+// \ and newline. A backslash-newline pair outside single quotes is removed.
+// Variables, globs, redirections and command substitutions are kept
+// literally; nothing is ever run through a shell.
+func Split(line string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		escaped bool
+		quote   rune
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+			if r == '\n' {
+				continue
+			}
+			if quote == '"' && !strings.ContainsRune("$`\"\\", r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, ErrUnterminatedQuote
+	}
+	if escaped {
+		return nil, ErrTrailingBackslash
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// Join quotes words so that Split returns them unchanged.
+func Join(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = Quote(word)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Quote returns word unchanged when it needs no quoting and wraps it in
+// single quotes otherwise.
+func Quote(word string) string {
+	if word == "" {
+		return "''"
+	}
+	if !strings.ContainsFunc(word, needsQuoting) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+func needsQuoting(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+	return !strings.ContainsRune("-_./=:,+@%", r)
+}
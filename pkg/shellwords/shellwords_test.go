@@ -0,0 +1,49 @@
+package shellwords
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  ls   -l \t", []string{"ls", "-l"}},
+		{"grep 'search term' file", []string{"grep", "search term", "file"}},
+		{`echo "a \"quoted\" $word" \$HOME`, []string{"echo", `a "quoted" $word`, "$HOME"}},
+		{`printf "%s\n" 'it'\''s'`, []string{"printf", `%s\n`, "it's"}},
+		{`touch my\ file ''`, []string{"touch", "my file", ""}},
+		{"echo a\\\nb", []string{"echo", "ab"}},
+		{"echo 'a\\\nb'", []string{"echo", "a\\\nb"}},
+		{`echo $(id) *.go > out`, []string{"echo", "$(id)", "*.go", ">", "out"}},
+	}
+	for _, tt := range tests {
+		got, err := Split(tt.line)
+		require.NoError(t, err, tt.line)
+		assert.Equal(t, tt.want, got, tt.line)
+	}
+}
+
+func TestSplit_Errors(t *testing.T) {
+	_, err := Split(`echo 'open`)
+	assert.ErrorIs(t, err, ErrUnterminatedQuote)
+	_, err = Split(`echo "open`)
+	assert.ErrorIs(t, err, ErrUnterminatedQuote)
+	_, err = Split(`echo \`)
+	assert.ErrorIs(t, err, ErrTrailingBackslash)
+}
+
+func TestJoin_RoundTrips(t *testing.T) {
+	words := []string{"grep", "-e", "search term", "it's", "", "$HOME", `back\slash`, "/tmp/a.txt"}
+	line := Join(words)
+	assert.Equal(t, `grep -e 'search term' 'it'\''s' '' '$HOME' 'back\slash' /tmp/a.txt`, line)
+
+	got, err := Split(line)
+	require.NoError(t, err)
+	assert.Equal(t, words, got)
+}